@@ -3,8 +3,12 @@ module github.com/choveylee/thttp
 go 1.25.0
 
 require (
+	github.com/andybalholm/brotli v1.2.6
 	github.com/choveylee/tlog v0.0.0-20260502054322-af6bbcc65693
 	github.com/choveylee/tmetric v0.0.0-20260502053803-579a8f7530fb
+	github.com/klauspost/compress v1.18.5
+	golang.org/x/net v0.53.0
+	golang.org/x/text v0.36.0
 )
 
 require (
@@ -42,9 +46,7 @@ require (
 	go.opentelemetry.io/otel/trace v1.43.0 // indirect
 	go.opentelemetry.io/proto/otlp v1.10.0 // indirect
 	go.yaml.in/yaml/v2 v2.4.4 // indirect
-	golang.org/x/net v0.53.0 // indirect
 	golang.org/x/sys v0.43.0 // indirect
-	golang.org/x/text v0.36.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20260420184626-e10c466a9529 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20260420184626-e10c466a9529 // indirect
 	google.golang.org/grpc v1.80.0 // indirect
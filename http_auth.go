@@ -0,0 +1,596 @@
+package thttp
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	_url "net/url"
+	"strings"
+	"sync"
+)
+
+// Authenticator adds credentials to outgoing requests. It is installed with [OptTransAuth] and applied by the auth
+// transport to a fresh copy of the request on every attempt, so credentials never reach the request seen by hooks,
+// debug dumps, or the logging transport. Implementations must be safe for concurrent use.
+type Authenticator interface {
+	// Authenticate sets credentials on req, typically the Authorization header.
+	Authenticate(req *http.Request) error
+}
+
+// AuthChallenger is implemented by an [Authenticator] that answers HTTP 401 challenges. After a 401 response,
+// Challenge inspects it (for example its WWW-Authenticate header) and reports whether the request should be sent once
+// more; the retry calls Authenticate again. Requests whose body cannot be replayed ([http.Request.GetBody] is nil)
+// are not retried.
+type AuthChallenger interface {
+	Challenge(req *http.Request, resp *http.Response) (bool, error)
+}
+
+// redactedHeaderValue replaces credentials in logged headers and debug dumps.
+const redactedHeaderValue = "[REDACTED]"
+
+// isSensitiveHeader reports whether values of the header key carry credentials and must not be logged.
+func isSensitiveHeader(key string) bool {
+	switch http.CanonicalHeaderKey(key) {
+	case "Authorization", "Proxy-Authorization":
+		return true
+	default:
+		return false
+	}
+}
+
+// headerLogValue joins the values of the header key for logging, redacting credentials.
+func headerLogValue(key string, vals []string) string {
+	if isSensitiveHeader(key) {
+		return redactedHeaderValue
+	}
+
+	return strings.Join(vals, ";")
+}
+
+// BasicAuth authenticates with HTTP Basic credentials (RFC 7617).
+type BasicAuth struct {
+	username string
+	password string
+}
+
+// NewBasicAuth returns a Basic [Authenticator] for username and password.
+func NewBasicAuth(username string, password string) *BasicAuth {
+	return &BasicAuth{
+		username: username,
+		password: password,
+	}
+}
+
+// Authenticate implements [Authenticator].
+func (p *BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(p.username, p.password)
+
+	return nil
+}
+
+// BearerAuth authenticates with a static bearer token (RFC 6750).
+type BearerAuth struct {
+	token string
+}
+
+// NewBearerAuth returns a Bearer [Authenticator] sending token.
+func NewBearerAuth(token string) *BearerAuth {
+	return &BearerAuth{
+		token: token,
+	}
+}
+
+// Authenticate implements [Authenticator].
+func (p *BearerAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+p.token)
+
+	return nil
+}
+
+// authChallenge is one challenge of a WWW-Authenticate header: a scheme and its parameters (names lowercased).
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseAuthChallenges parses WWW-Authenticate header values, which may each hold several comma-separated challenges.
+func parseAuthChallenges(values []string) []*authChallenge {
+	challenges := make([]*authChallenge, 0)
+
+	for _, value := range values {
+		var current *authChallenge
+
+		pos := 0
+		for pos < len(value) {
+			pos = skipAuthSeparators(value, pos)
+			if pos >= len(value) {
+				break
+			}
+
+			name, next := readAuthToken(value, pos)
+			if name == "" {
+				// skip a character that cannot start a token
+				pos++
+
+				continue
+			}
+
+			afterName := skipAuthSpaces(value, next)
+			if afterName < len(value) && value[afterName] == '=' && current != nil {
+				paramValue, end := readAuthParamValue(value, skipAuthSpaces(value, afterName+1))
+
+				current.params[strings.ToLower(name)] = paramValue
+				pos = end
+
+				continue
+			}
+
+			current = &authChallenge{
+				scheme: strings.ToLower(name),
+				params: make(map[string]string),
+			}
+			challenges = append(challenges, current)
+
+			pos = next
+		}
+	}
+
+	return challenges
+}
+
+func skipAuthSpaces(value string, pos int) int {
+	for pos < len(value) && (value[pos] == ' ' || value[pos] == '\t') {
+		pos++
+	}
+
+	return pos
+}
+
+func skipAuthSeparators(value string, pos int) int {
+	for pos < len(value) && (value[pos] == ' ' || value[pos] == '\t' || value[pos] == ',') {
+		pos++
+	}
+
+	return pos
+}
+
+// readAuthToken reads an RFC 9110 token starting at pos.
+func readAuthToken(value string, pos int) (string, int) {
+	start := pos
+	for pos < len(value) && strings.IndexByte(" \t,=\"", value[pos]) < 0 {
+		pos++
+	}
+
+	return value[start:pos], pos
+}
+
+// readAuthParamValue reads a token or quoted-string parameter value starting at pos.
+func readAuthParamValue(value string, pos int) (string, int) {
+	if pos >= len(value) || value[pos] != '"' {
+		return readAuthToken(value, pos)
+	}
+
+	builder := strings.Builder{}
+
+	pos++
+	for pos < len(value) {
+		switch value[pos] {
+		case '\\':
+			if pos+1 < len(value) {
+				builder.WriteByte(value[pos+1])
+			}
+
+			pos += 2
+		case '"':
+			return builder.String(), pos + 1
+		default:
+			builder.WriteByte(value[pos])
+
+			pos++
+		}
+	}
+
+	return builder.String(), pos
+}
+
+// digestHashes maps the supported RFC 7616 algorithms (without the "-sess" suffix) to their hash constructors, in
+// order of preference.
+var digestHashes = []struct {
+	algorithm string
+	newHash   func() hash.Hash
+}{
+	{"SHA-512-256", sha512.New512_256},
+	{"SHA-256", sha256.New},
+	{"MD5", md5.New},
+}
+
+// DigestAuth authenticates with HTTP Digest (RFC 7616, including SHA-256, SHA-512-256, MD5, their "-sess"
+// variants, qop auth and auth-int, and userhash). The first request is sent without credentials; the 401 challenge
+// is answered by a retry, and later requests reuse the challenge with an increasing nonce count until the server
+// rejects it as stale.
+type DigestAuth struct {
+	username string
+	password string
+
+	challenge  *digestChallenge
+	nonceCount uint32
+
+	sync.Mutex
+}
+
+// digestChallenge is the selected Digest challenge.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+
+	newHash func() hash.Hash
+	session bool
+}
+
+// NewDigestAuth returns a Digest [Authenticator] for username and password.
+func NewDigestAuth(username string, password string) *DigestAuth {
+	return &DigestAuth{
+		username: username,
+		password: password,
+	}
+}
+
+// selectDigestChallenge picks the Digest challenge with the strongest supported algorithm, or nil.
+func selectDigestChallenge(challenges []*authChallenge) *digestChallenge {
+	var selected *digestChallenge
+
+	rank := len(digestHashes)
+
+	for _, challenge := range challenges {
+		if challenge.scheme != "digest" || challenge.params["nonce"] == "" {
+			continue
+		}
+
+		algorithm := challenge.params["algorithm"]
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+
+		baseAlgorithm, session := strings.CutSuffix(strings.ToUpper(algorithm), "-SESS")
+
+		for i, digestHash := range digestHashes {
+			if digestHash.algorithm != baseAlgorithm || i >= rank {
+				continue
+			}
+
+			qop := ""
+			for _, option := range strings.Split(challenge.params["qop"], ",") {
+				option = strings.ToLower(strings.TrimSpace(option))
+				if option == "auth" || (option == "auth-int" && qop == "") {
+					qop = option
+				}
+			}
+
+			// a challenge offering only unknown qop values cannot be answered
+			if challenge.params["qop"] != "" && qop == "" {
+				continue
+			}
+
+			rank = i
+			selected = &digestChallenge{
+				realm:     challenge.params["realm"],
+				nonce:     challenge.params["nonce"],
+				opaque:    challenge.params["opaque"],
+				algorithm: algorithm,
+				qop:       qop,
+				userhash:  strings.EqualFold(challenge.params["userhash"], "true"),
+
+				newHash: digestHash.newHash,
+				session: session,
+			}
+		}
+	}
+
+	return selected
+}
+
+// Challenge implements [AuthChallenger]. It retries when the response carries a Digest challenge with a new nonce
+// or one marked stale; a repeated nonce means the credentials were rejected.
+func (p *DigestAuth) Challenge(req *http.Request, resp *http.Response) (bool, error) {
+	challenges := parseAuthChallenges(resp.Header.Values("WWW-Authenticate"))
+
+	challenge := selectDigestChallenge(challenges)
+	if challenge == nil {
+		return false, nil
+	}
+
+	stale := false
+	for _, authChallenge := range challenges {
+		if authChallenge.scheme == "digest" && strings.EqualFold(authChallenge.params["stale"], "true") {
+			stale = true
+		}
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.challenge != nil && p.challenge.nonce == challenge.nonce && stale == false &&
+		req.Header.Get("Authorization") != "" {
+		return false, nil
+	}
+
+	p.challenge = challenge
+	p.nonceCount = 0
+
+	return true, nil
+}
+
+// Authenticate implements [Authenticator]. Before the first challenge it sends no credentials.
+func (p *DigestAuth) Authenticate(req *http.Request) error {
+	p.Lock()
+
+	challenge := p.challenge
+	if challenge == nil {
+		p.Unlock()
+
+		return nil
+	}
+
+	p.nonceCount++
+	nonceCount := p.nonceCount
+
+	p.Unlock()
+
+	digest := func(data string) string {
+		h := challenge.newHash()
+		_, _ = io.WriteString(h, data)
+
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 16)
+
+	_, err := rand.Read(cnonceBytes)
+	if err != nil {
+		return err
+	}
+
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := fmt.Sprintf("%08x", nonceCount)
+	uri := req.URL.RequestURI()
+
+	ha1 := digest(p.username + ":" + challenge.realm + ":" + p.password)
+	if challenge.session {
+		ha1 = digest(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+
+	ha2 := digest(req.Method + ":" + uri)
+	if challenge.qop == "auth-int" {
+		bodyHash := digest("")
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+
+			h := challenge.newHash()
+			_, err = io.Copy(h, body)
+			_ = body.Close()
+			if err != nil {
+				return err
+			}
+
+			bodyHash = hex.EncodeToString(h.Sum(nil))
+		}
+
+		ha2 = digest(req.Method + ":" + uri + ":" + bodyHash)
+	}
+
+	var response string
+	if challenge.qop == "" {
+		response = digest(ha1 + ":" + challenge.nonce + ":" + ha2)
+	} else {
+		response = digest(ha1 + ":" + challenge.nonce + ":" + nc + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+	}
+
+	username := p.username
+	if challenge.userhash {
+		username = digest(p.username + ":" + challenge.realm)
+	}
+
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	params := []string{
+		fmt.Sprintf(`username="%s"`, quote.Replace(username)),
+		fmt.Sprintf(`realm="%s"`, quote.Replace(challenge.realm)),
+		fmt.Sprintf(`nonce="%s"`, quote.Replace(challenge.nonce)),
+		fmt.Sprintf(`uri="%s"`, quote.Replace(uri)),
+		fmt.Sprintf(`algorithm=%s`, challenge.algorithm),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+
+	if challenge.qop != "" {
+		params = append(params, "qop="+challenge.qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+
+	if challenge.opaque != "" {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, quote.Replace(challenge.opaque)))
+	}
+
+	if challenge.userhash {
+		params = append(params, "userhash=true")
+	}
+
+	req.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+
+	return nil
+}
+
+// credentialScopeKey is the context key of the [credentialScope] of a request chain.
+type credentialScopeKey struct{}
+
+// credentialScope holds the hosts a request chain may send credentials and signatures to: the host of the request
+// passed to [HttpClient.Do], plus redirect targets allowed with [AllowRedirectCredentials].
+type credentialScope struct {
+	hosts map[string]bool
+
+	sync.Mutex
+}
+
+// canonicalHost returns the lowercase "host:port" of u, using the scheme default port when u has none.
+func canonicalHost(u *_url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http", "ws":
+			port = "80"
+		case "https", "wss":
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// withCredentialScope returns ctx with a [credentialScope] limited to the host of u.
+func withCredentialScope(ctx context.Context, u *_url.URL) context.Context {
+	scope := &credentialScope{
+		hosts: map[string]bool{
+			canonicalHost(u): true,
+		},
+	}
+
+	return context.WithValue(ctx, credentialScopeKey{}, scope)
+}
+
+// AllowRedirectCredentials lets the authentication and signing layers of an [HttpClient] send credentials and
+// signatures to the host of req, a redirect target. Call it from a redirect policy ([HttpClient.WithRedirectPolicy])
+// that trusts the target; by default only the host of the original request receives them.
+func AllowRedirectCredentials(req *http.Request) {
+	scope, ok := req.Context().Value(credentialScopeKey{}).(*credentialScope)
+	if ok == false {
+		return
+	}
+
+	scope.Lock()
+	defer scope.Unlock()
+
+	scope.hosts[canonicalHost(req.URL)] = true
+}
+
+// inCredentialScope reports whether credentials and signatures may be sent with req. Requests not started by
+// [HttpClient.Do] carry no scope and are always in scope.
+func inCredentialScope(req *http.Request) bool {
+	scope, ok := req.Context().Value(credentialScopeKey{}).(*credentialScope)
+	if ok == false {
+		return true
+	}
+
+	scope.Lock()
+	defer scope.Unlock()
+
+	return scope.hosts[canonicalHost(req.URL)]
+}
+
+// authTransport applies an [Authenticator] to a copy of each request and answers one 401 challenge. Requests to
+// hosts outside the [credentialScope], such as cross-host redirect targets, are sent without credentials and their
+// challenges are not answered.
+type authTransport struct {
+	transport http.RoundTripper
+
+	authenticator Authenticator
+}
+
+// authenticate returns a copy of req with credentials applied, so the caller's request is never modified.
+func (p *authTransport) authenticate(req *http.Request) (*http.Request, error) {
+	authReq := req.Clone(req.Context())
+
+	err := p.authenticator.Authenticate(authReq)
+	if err != nil {
+		return nil, fmt.Errorf("thttp: authentication failed: %w", err)
+	}
+
+	return authReq, nil
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (p *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if inCredentialScope(req) == false {
+		return p.transport.RoundTrip(req)
+	}
+
+	authReq, err := p.authenticate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.transport.RoundTrip(authReq)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenger, ok := p.authenticator.(AuthChallenger)
+	if ok == false {
+		return resp, nil
+	}
+
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	retry, err := challenger.Challenge(authReq, resp)
+	if err != nil {
+		_ = resp.Body.Close()
+
+		return nil, fmt.Errorf("thttp: authentication challenge failed: %w", err)
+	}
+
+	if retry == false {
+		return resp, nil
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+
+	retryReq := req.Clone(req.Context())
+
+	if hasBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		retryReq.Body = body
+	}
+
+	authReq, err = p.authenticate(retryReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.transport.RoundTrip(authReq)
+}
+
+// wrapAuthTransport returns an authenticating decorator around transport, or [http.DefaultTransport] when transport
+// is nil.
+func wrapAuthTransport(transport http.RoundTripper, authenticator Authenticator) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	authTransport := &authTransport{
+		transport:     transport,
+		authenticator: authenticator,
+	}
+
+	return authTransport
+}
@@ -0,0 +1,299 @@
+package thttp
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// digestParamPattern matches one name=value or name="value" pair of a Digest Authorization header.
+var digestParamPattern = regexp.MustCompile(`(\w+)=(?:"((?:[^"\\]|\\.)*)"|([^,\s]*))`)
+
+// parseDigestAuthorization returns the parameters of a Digest Authorization header, or nil for other schemes.
+func parseDigestAuthorization(header string) map[string]string {
+	params, ok := strings.CutPrefix(header, "Digest ")
+	if ok == false {
+		return nil
+	}
+
+	parsed := make(map[string]string)
+	for _, match := range digestParamPattern.FindAllStringSubmatch(params, -1) {
+		parsed[match[1]] = match[2] + match[3]
+	}
+
+	return parsed
+}
+
+// digestResponse computes the RFC 7616 response for qop "auth", independently of [DigestAuth].
+func digestResponse(newHash func() hash.Hash, username string, realm string, password string, method string,
+	uri string, nonce string, nc string, cnonce string) string {
+	digest := func(data string) string {
+		h := newHash()
+		_, _ = io.WriteString(h, data)
+
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	ha1 := digest(username + ":" + realm + ":" + password)
+	ha2 := digest(method + ":" + uri)
+
+	return digest(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+}
+
+// TestDigestResponseRfc7616Vectors checks the reference computation against the examples of RFC 7616 section 3.9.1.
+func TestDigestResponseRfc7616Vectors(t *testing.T) {
+	tests := map[string]struct {
+		newHash func() hash.Hash
+		want    string
+	}{
+		"MD5":     {newHash: md5.New, want: "8ca523f5e9506fed4657c9700eebdbec"},
+		"SHA-256": {newHash: sha256.New, want: "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+
+	for name, test := range tests {
+		got := digestResponse(test.newHash, "Mufasa", "http-auth@example.org", "Circle of Life", http.MethodGet,
+			"/dir/index.html", "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", "00000001",
+			"f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+		if got != test.want {
+			t.Errorf("%s response = %s, want %s", name, got, test.want)
+		}
+	}
+}
+
+// digestServer is a Digest-protected test endpoint using SHA-256 and qop auth.
+type digestServer struct {
+	*httptest.Server
+
+	realm    string
+	password string
+	opaque   string
+
+	// staleAfter rotates the nonce, marking the old one stale, once this many requests were accepted (0 never).
+	staleAfter int
+
+	nonce    string
+	accepted int
+	requests int
+	lastNc   string
+	bodies   []string
+
+	sync.Mutex
+}
+
+// newDigestServer returns a started [digestServer] accepting user "Mufasa" with password.
+func newDigestServer(t *testing.T, password string) *digestServer {
+	t.Helper()
+
+	server := &digestServer{
+		realm:    "http-auth@example.org",
+		password: password,
+		opaque:   "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+		nonce:    "nonce-1",
+	}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// challenge answers with HTTP 401 and a Digest challenge for the current nonce.
+func (p *digestServer) challenge(w http.ResponseWriter, stale bool) {
+	header := fmt.Sprintf(`Digest realm="%s", qop="auth, auth-int", algorithm=SHA-256, nonce="%s", opaque="%s"`,
+		p.realm, p.nonce, p.opaque)
+	if stale == true {
+		header += ", stale=true"
+	}
+
+	w.Header().Add("WWW-Authenticate", `Basic realm="fallback"`)
+	w.Header().Add("WWW-Authenticate", header)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// serve verifies the Authorization header of r.
+func (p *digestServer) serve(w http.ResponseWriter, r *http.Request) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.requests++
+
+	params := parseDigestAuthorization(r.Header.Get("Authorization"))
+	if params == nil {
+		p.challenge(w, false)
+
+		return
+	}
+
+	if params["nonce"] != p.nonce {
+		p.challenge(w, true)
+
+		return
+	}
+
+	want := digestResponse(sha256.New, params["username"], p.realm, p.password, r.Method, r.URL.RequestURI(),
+		params["nonce"], params["nc"], params["cnonce"])
+
+	if params["username"] != "Mufasa" || params["realm"] != p.realm || params["uri"] != r.URL.RequestURI() ||
+		params["algorithm"] != "SHA-256" || params["qop"] != "auth" || params["opaque"] != p.opaque ||
+		params["response"] != want || params["nc"] <= p.lastNc {
+		p.challenge(w, false)
+
+		return
+	}
+
+	p.lastNc = params["nc"]
+	p.accepted++
+
+	body, _ := io.ReadAll(r.Body)
+	p.bodies = append(p.bodies, string(body))
+
+	if p.staleAfter > 0 && p.accepted%p.staleAfter == 0 {
+		p.nonce = fmt.Sprintf("nonce-%d", p.accepted+1)
+		p.lastNc = ""
+	}
+
+	_, _ = w.Write([]byte("ok"))
+}
+
+// stats returns the request and accepted counts and the last accepted nonce count.
+func (p *digestServer) stats() (int, int, string) {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.requests, p.accepted, p.lastNc
+}
+
+// get sends a GET with client and returns the status code.
+func (p *digestServer) get(t *testing.T, client *HttpClient, path string) int {
+	t.Helper()
+
+	resp, err := client.Get(context.Background(), p.URL+path, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	statusCode, _, err := resp.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes: %v", err)
+	}
+
+	return statusCode
+}
+
+func TestDigestAuthChallengeResponse(t *testing.T) {
+	server := newDigestServer(t, "Circle of Life")
+	client := NewHttpClient().WithDigestAuth("Mufasa", "Circle of Life")
+
+	statusCode := server.get(t, client, "/dir/index.html?page=1")
+	if statusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", statusCode)
+	}
+
+	requests, _, _ := server.stats()
+	if requests != 2 {
+		t.Errorf("requests = %d, want the challenge and the answer", requests)
+	}
+
+	// later requests reuse the challenge with the next nonce count
+	statusCode = server.get(t, client, "/dir/other.html")
+	if statusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", statusCode)
+	}
+
+	requests, _, lastNc := server.stats()
+	if requests != 3 || lastNc != "00000002" {
+		t.Errorf("requests = %d, nc = %s, want 3 and 00000002", requests, lastNc)
+	}
+}
+
+func TestDigestAuthReplaysBody(t *testing.T) {
+	server := newDigestServer(t, "Circle of Life")
+	client := NewHttpClient().WithDigestAuth("Mufasa", "Circle of Life")
+
+	resp, err := client.PostJson(context.Background(), server.URL+"/upload", nil, map[string]string{"name": "simba"})
+	if err != nil {
+		t.Fatalf("PostJson: %v", err)
+	}
+
+	statusCode, _, _ := resp.ToBytes()
+
+	server.Lock()
+	defer server.Unlock()
+
+	if statusCode != http.StatusOK || len(server.bodies) != 1 || server.bodies[0] != `{"name":"simba"}` {
+		t.Errorf("status = %d, bodies = %q, want 200 and the original body", statusCode, server.bodies)
+	}
+}
+
+func TestDigestAuthStaleNonce(t *testing.T) {
+	server := newDigestServer(t, "Circle of Life")
+	server.staleAfter = 1
+
+	client := NewHttpClient().WithDigestAuth("Mufasa", "Circle of Life")
+
+	for i := 0; i < 3; i++ {
+		statusCode := server.get(t, client, "/")
+		if statusCode != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i, statusCode)
+		}
+	}
+
+	// every request after the first is rejected as stale once and answered with the new nonce
+	requests, accepted, _ := server.stats()
+	if accepted != 3 || requests != 6 {
+		t.Errorf("accepted = %d, requests = %d, want 3 and 6", accepted, requests)
+	}
+}
+
+func TestDigestAuthWrongPassword(t *testing.T) {
+	server := newDigestServer(t, "Circle of Life")
+	client := NewHttpClient().WithDigestAuth("Mufasa", "Hakuna Matata")
+
+	statusCode := server.get(t, client, "/")
+	if statusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", statusCode)
+	}
+
+	requests, _, _ := server.stats()
+	if requests != 2 {
+		t.Errorf("requests = %d, want a single retry for a repeated nonce", requests)
+	}
+}
+
+func TestDigestAuthWithheldFromCrossHostRedirect(t *testing.T) {
+	var leaked atomic.Bool
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked.Store(r.Header.Get("Authorization") != "")
+	}))
+	defer other.Close()
+
+	server := newDigestServer(t, "Circle of Life")
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			http.Redirect(w, r, other.URL, http.StatusFound)
+
+			return
+		}
+
+		server.serve(w, r)
+	})
+
+	client := NewHttpClient().WithDigestAuth("Mufasa", "Circle of Life")
+
+	statusCode := server.get(t, client, "/")
+	if statusCode != http.StatusOK || leaked.Load() == true {
+		t.Errorf("status = %d, leaked = %v, want 200 without credentials on the redirect target", statusCode, leaked.Load())
+	}
+}
@@ -0,0 +1,420 @@
+package thttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// bodyTransOption holds the per-request body instrumentation resolved from [OptUploadProgressFunc],
+// [OptDownloadProgressFunc], [OptBandwidthLimit], [OptMaxResponseSize], [OptMaxDecodedResponseSize], and
+// [OptReadIdleTimeout].
+type bodyTransOption struct {
+	uploadProgressFunc   ProgressFunc
+	downloadProgressFunc ProgressFunc
+
+	bandwidthLimiter *BandwidthLimiter
+
+	maxResponseSize        int64
+	maxDecodedResponseSize int64
+
+	readIdleTimeout time.Duration
+}
+
+// ResponseSizeLimitError is returned from reads of a response body that exceeds [OptMaxResponseSize] (bytes on the
+// wire) or [OptMaxDecodedResponseSize] (bytes after decompression). Use [errors.As] to detect it.
+type ResponseSizeLimitError struct {
+	// Limit is the configured maximum number of bytes.
+	Limit int64
+
+	// Decoded is true when the decompressed size limit was exceeded.
+	Decoded bool
+}
+
+// Error implements [error].
+func (p *ResponseSizeLimitError) Error() string {
+	if p.Decoded {
+		return fmt.Sprintf("thttp: response body exceeds the maximum decoded size of %d bytes", p.Limit)
+	}
+
+	return fmt.Sprintf("thttp: response body exceeds the maximum size of %d bytes", p.Limit)
+}
+
+// limitedReadCloser fails with a [*ResponseSizeLimitError] once more than limit bytes have been read.
+type limitedReadCloser struct {
+	reader io.ReadCloser
+
+	remaining int64
+
+	limitErr error
+	err      error
+}
+
+// newLimitedReadCloser wraps reader so reading past limit bytes fails; decoded selects the error flavor.
+func newLimitedReadCloser(reader io.ReadCloser, limit int64, decoded bool) io.ReadCloser {
+	return &limitedReadCloser{
+		reader: reader,
+
+		remaining: limit,
+
+		limitErr: &ResponseSizeLimitError{Limit: limit, Decoded: decoded},
+	}
+}
+
+// Read implements [io.Reader]. One byte beyond the limit is requested so an exact-size body still succeeds.
+func (p *limitedReadCloser) Read(data []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+
+	if int64(len(data)) > p.remaining+1 {
+		data = data[:p.remaining+1]
+	}
+
+	n, err := p.reader.Read(data)
+	if int64(n) > p.remaining {
+		n = int(p.remaining)
+
+		p.remaining = 0
+		p.err = p.limitErr
+
+		return n, p.err
+	}
+
+	p.remaining -= int64(n)
+
+	return n, err
+}
+
+// Close implements [io.Closer].
+func (p *limitedReadCloser) Close() error {
+	return p.reader.Close()
+}
+
+// ReadIdleTimeoutError is returned from reads of a response body when no data arrives within [OptReadIdleTimeout].
+// The body is closed when it occurs. Use [errors.As] to detect it.
+type ReadIdleTimeoutError struct {
+	// IdleTimeout is the configured idle window.
+	IdleTimeout time.Duration
+}
+
+// Error implements [error].
+func (p *ReadIdleTimeoutError) Error() string {
+	return fmt.Sprintf("thttp: no response body data received for %s", p.IdleTimeout)
+}
+
+// Timeout reports true, so checks for an interface{ Timeout() bool } error treat it as a timeout.
+func (p *ReadIdleTimeoutError) Timeout() bool {
+	return true
+}
+
+// idleTimeoutReadCloser closes its reader when a Read blocks for longer than timeout, failing that and later reads
+// with a [*ReadIdleTimeoutError]. Time spent between reads, while the caller processes data, is not counted.
+type idleTimeoutReadCloser struct {
+	reader io.ReadCloser
+
+	timeout time.Duration
+	timer   *time.Timer
+
+	timedOut atomic.Bool
+}
+
+// newIdleTimeoutReadCloser wraps reader with a read idle timeout.
+func newIdleTimeoutReadCloser(reader io.ReadCloser, timeout time.Duration) io.ReadCloser {
+	idleTimeoutReadCloser := &idleTimeoutReadCloser{
+		reader: reader,
+
+		timeout: timeout,
+	}
+
+	idleTimeoutReadCloser.timer = time.AfterFunc(timeout, func() {
+		idleTimeoutReadCloser.timedOut.Store(true)
+
+		_ = reader.Close()
+	})
+	idleTimeoutReadCloser.timer.Stop()
+
+	return idleTimeoutReadCloser
+}
+
+// Read implements [io.Reader].
+func (p *idleTimeoutReadCloser) Read(data []byte) (int, error) {
+	if p.timedOut.Load() == true {
+		return 0, &ReadIdleTimeoutError{IdleTimeout: p.timeout}
+	}
+
+	p.timer.Reset(p.timeout)
+
+	n, err := p.reader.Read(data)
+
+	// a read that delivered data or reached the end succeeded even if the timer fired while it returned; the
+	// timeout is then reported by the next Read
+	if p.timer.Stop() == false && p.timedOut.Load() == true && err != io.EOF {
+		if n > 0 {
+			return n, nil
+		}
+
+		return 0, &ReadIdleTimeoutError{IdleTimeout: p.timeout}
+	}
+
+	return n, err
+}
+
+// Close implements [io.Closer].
+func (p *idleTimeoutReadCloser) Close() error {
+	p.timer.Stop()
+
+	return p.reader.Close()
+}
+
+// prepareReadIdleTimeout returns the [OptReadIdleTimeout] value, or 0 (disabled) when it is unset.
+func prepareReadIdleTimeout(options map[int]interface{}) (time.Duration, error) {
+	srcReadIdleTimeout, ok := options[OptReadIdleTimeout]
+	if ok == false || srcReadIdleTimeout == nil {
+		return 0, nil
+	}
+
+	destReadIdleTimeout, ok := srcReadIdleTimeout.(time.Duration)
+	if ok == false {
+		return 0, fmt.Errorf("thttp: invalid OptReadIdleTimeout value: want time.Duration, got %T", srcReadIdleTimeout)
+	}
+
+	return destReadIdleTimeout, nil
+}
+
+// prepareSizeLimit returns the int64 byte limit stored under key, or 0 (unlimited) when it is unset.
+func prepareSizeLimit(options map[int]interface{}, key int, name string) (int64, error) {
+	srcSizeLimit, ok := options[key]
+	if ok == false || srcSizeLimit == nil {
+		return 0, nil
+	}
+
+	destSizeLimit, ok := srcSizeLimit.(int64)
+	if ok == false {
+		return 0, fmt.Errorf("thttp: invalid %s value: want int64, got %T", name, srcSizeLimit)
+	}
+
+	return destSizeLimit, nil
+}
+
+// prepareBodyTransOption builds a [bodyTransOption] from options, or returns nil when no body instrumentation is configured.
+// Invalid option value types return errors prefixed with "thttp:" and use "invalid <option> value" wording.
+func prepareBodyTransOption(options map[int]interface{}) (*bodyTransOption, error) {
+	option := &bodyTransOption{}
+
+	enabled := false
+
+	srcUploadProgressFunc, ok := options[OptUploadProgressFunc]
+	if ok == true && srcUploadProgressFunc != nil {
+		destUploadProgressFunc, ok := srcUploadProgressFunc.(ProgressFunc)
+		if ok == false {
+			return nil, fmt.Errorf("thttp: invalid OptUploadProgressFunc value: want ProgressFunc (func(int64, int64)), got %T", srcUploadProgressFunc)
+		}
+
+		if destUploadProgressFunc != nil {
+			option.uploadProgressFunc = destUploadProgressFunc
+			enabled = true
+		}
+	}
+
+	srcDownloadProgressFunc, ok := options[OptDownloadProgressFunc]
+	if ok == true && srcDownloadProgressFunc != nil {
+		destDownloadProgressFunc, ok := srcDownloadProgressFunc.(ProgressFunc)
+		if ok == false {
+			return nil, fmt.Errorf("thttp: invalid OptDownloadProgressFunc value: want ProgressFunc (func(int64, int64)), got %T", srcDownloadProgressFunc)
+		}
+
+		if destDownloadProgressFunc != nil {
+			option.downloadProgressFunc = destDownloadProgressFunc
+			enabled = true
+		}
+	}
+
+	srcBandwidthLimit, ok := options[OptBandwidthLimit]
+	if ok == true && srcBandwidthLimit != nil {
+		destBandwidthLimit, ok := srcBandwidthLimit.(*BandwidthLimiter)
+		if ok == false {
+			return nil, fmt.Errorf("thttp: invalid OptBandwidthLimit value: want *BandwidthLimiter, got %T", srcBandwidthLimit)
+		}
+
+		if destBandwidthLimit != nil {
+			option.bandwidthLimiter = destBandwidthLimit
+			enabled = true
+		}
+	}
+
+	maxResponseSize, err := prepareSizeLimit(options, OptMaxResponseSize, "OptMaxResponseSize")
+	if err != nil {
+		return nil, err
+	}
+
+	maxDecodedResponseSize, err := prepareSizeLimit(options, OptMaxDecodedResponseSize, "OptMaxDecodedResponseSize")
+	if err != nil {
+		return nil, err
+	}
+
+	if maxResponseSize > 0 || maxDecodedResponseSize > 0 {
+		option.maxResponseSize = maxResponseSize
+		option.maxDecodedResponseSize = maxDecodedResponseSize
+		enabled = true
+	}
+
+	readIdleTimeout, err := prepareReadIdleTimeout(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if readIdleTimeout > 0 {
+		option.readIdleTimeout = readIdleTimeout
+		enabled = true
+	}
+
+	if enabled == false {
+		return nil, nil
+	}
+
+	return option, nil
+}
+
+// bodyReadCloser counts bytes flowing through a request or response body, reports progress, and applies the
+// optional bandwidth limit.
+type bodyReadCloser struct {
+	reader io.ReadCloser
+
+	ctx context.Context
+
+	progressFunc     ProgressFunc
+	bandwidthLimiter *BandwidthLimiter
+
+	total int64
+	done  int64
+}
+
+// Read implements [io.Reader].
+func (p *bodyReadCloser) Read(data []byte) (int, error) {
+	if p.bandwidthLimiter != nil {
+		chunkSize := p.bandwidthLimiter.chunkSize()
+		if len(data) > chunkSize {
+			data = data[:chunkSize]
+		}
+	}
+
+	n, err := p.reader.Read(data)
+	if n > 0 {
+		p.done += int64(n)
+
+		if p.progressFunc != nil {
+			p.progressFunc(p.done, p.total)
+		}
+
+		if p.bandwidthLimiter != nil {
+			waitErr := p.bandwidthLimiter.WaitN(p.ctx, n)
+			if waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+
+	return n, err
+}
+
+// Close implements [io.Closer].
+func (p *bodyReadCloser) Close() error {
+	return p.reader.Close()
+}
+
+// bodyTransport wraps request and response bodies of a delegate [http.RoundTripper] for progress reporting,
+// bandwidth limiting, response size limits, and the read idle timeout. It sits below the retry and decompression layers, so each attempt
+// observes a fresh request body and response sizes are measured on the wire.
+type bodyTransport struct {
+	transport http.RoundTripper
+
+	bodyTransOption *bodyTransOption
+}
+
+// knownLength converts an [http.Request.ContentLength] or [http.Response.ContentLength] into a progress total.
+func knownLength(contentLength int64) int64 {
+	if contentLength > 0 {
+		return contentLength
+	}
+
+	return -1
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (p *bodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	option := p.bodyTransOption
+
+	if req.Body != nil && req.Body != http.NoBody &&
+		(option.uploadProgressFunc != nil || option.bandwidthLimiter != nil) {
+		outReq := new(http.Request)
+		*outReq = *req
+
+		outReq.Body = &bodyReadCloser{
+			reader: req.Body,
+
+			ctx: req.Context(),
+
+			progressFunc:     option.uploadProgressFunc,
+			bandwidthLimiter: option.bandwidthLimiter,
+
+			total: knownLength(req.ContentLength),
+		}
+
+		req = outReq
+	}
+
+	resp, err := p.transport.RoundTrip(req)
+	if err != nil || resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, err
+	}
+
+	// the idle timer wraps the wire body directly so closing it unblocks a stalled read
+	if option.readIdleTimeout > 0 {
+		resp.Body = newIdleTimeoutReadCloser(resp.Body, option.readIdleTimeout)
+	}
+
+	// an unencoded (or transport-decoded) body is also subject to the decoded size limit
+	limit, decoded := option.maxResponseSize, false
+	if option.maxDecodedResponseSize > 0 && (resp.Uncompressed || len(parseContentEncoding(resp.Header)) == 0) {
+		if limit <= 0 || option.maxDecodedResponseSize < limit {
+			limit, decoded = option.maxDecodedResponseSize, true
+		}
+	}
+
+	if limit > 0 {
+		resp.Body = newLimitedReadCloser(resp.Body, limit, decoded)
+	}
+
+	if option.downloadProgressFunc != nil || option.bandwidthLimiter != nil {
+		resp.Body = &bodyReadCloser{
+			reader: resp.Body,
+
+			ctx: req.Context(),
+
+			progressFunc:     option.downloadProgressFunc,
+			bandwidthLimiter: option.bandwidthLimiter,
+
+			total: knownLength(resp.ContentLength),
+		}
+	}
+
+	return resp, err
+}
+
+// wrapBodyTransport returns a body-instrumenting decorator around transport, or [http.DefaultTransport] when transport is nil.
+func wrapBodyTransport(transport http.RoundTripper, bodyTransOption *bodyTransOption) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	bodyTransport := &bodyTransport{
+		transport:       transport,
+		bodyTransOption: bodyTransOption,
+	}
+
+	return bodyTransport
+}
@@ -0,0 +1,476 @@
+package thttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// progressRecorder collects the calls of a [ProgressFunc].
+type progressRecorder struct {
+	calls int
+	done  int64
+	total int64
+
+	sync.Mutex
+}
+
+// record implements [ProgressFunc].
+func (p *progressRecorder) record(done int64, total int64) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.calls++
+	p.done = done
+	p.total = total
+}
+
+// last returns the number of calls and the last reported progress.
+func (p *progressRecorder) last() (int, int64, int64) {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.calls, p.done, p.total
+}
+
+// newEchoServer returns a server answering each request with its body, announcing the length.
+func newEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestUploadAndDownloadProgress(t *testing.T) {
+	server := newEchoServer(t)
+
+	payload := bytes.Repeat([]byte("0123456789"), 20000)
+
+	upload := &progressRecorder{}
+	download := &progressRecorder{}
+
+	client := NewHttpClient().
+		WithUploadProgressFunc(upload.record).
+		WithDownloadProgressFunc(download.record)
+
+	resp, err := client.Post(context.Background(), server.URL, nil, payload)
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+
+	_, body, err := resp.ToBytes()
+	if err != nil || bytes.Equal(body, payload) == false {
+		t.Fatalf("ToBytes = %d bytes, %v, want the echoed payload", len(body), err)
+	}
+
+	size := int64(len(payload))
+
+	for name, recorder := range map[string]*progressRecorder{"upload": upload, "download": download} {
+		calls, done, total := recorder.last()
+		if calls == 0 || done != size || total != size {
+			t.Errorf("%s progress = %d calls, %d/%d, want %d/%d", name, calls, done, total, size, size)
+		}
+	}
+}
+
+func TestDownloadProgressUnknownLength(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte("chunk"))
+	}))
+	defer server.Close()
+
+	download := &progressRecorder{}
+
+	resp, err := NewHttpClient().WithDownloadProgressFunc(download.record).Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	_, done, total := download.last()
+	if done != 10 || total != -1 {
+		t.Errorf("download progress = %d/%d, want 10/-1", done, total)
+	}
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	server := newEchoServer(t)
+
+	payload := strings.Repeat("x", 1000)
+
+	startAt := time.Now()
+
+	resp, err := NewHttpClient().WithBandwidthLimit(1000).Post(context.Background(), server.URL, nil, []byte(payload))
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+
+	_, body, err := resp.ToBytes()
+	if err != nil || string(body) != payload {
+		t.Fatalf("ToBytes = %d bytes, %v", len(body), err)
+	}
+
+	// the upload spends the initial budget of one second, the echoed download waits for the next one
+	elapsed := time.Since(startAt)
+	if elapsed < 700*time.Millisecond {
+		t.Errorf("transfer took %s, want about 1s at 1000 B/s", elapsed)
+	}
+}
+
+func TestBodyTransOptionInvalid(t *testing.T) {
+	server := newEchoServer(t)
+
+	tests := map[int]string{
+		OptUploadProgressFunc:   "invalid OptUploadProgressFunc value",
+		OptDownloadProgressFunc: "invalid OptDownloadProgressFunc value",
+		OptBandwidthLimit:       "invalid OptBandwidthLimit value",
+	}
+
+	for key, want := range tests {
+		_, err := NewHttpClient().WithOption(key, "invalid").Get(context.Background(), server.URL, nil, nil)
+		if err == nil || strings.Contains(err.Error(), want) == false {
+			t.Errorf("Get with an invalid option %d = %v, want %q", key, err, want)
+		}
+	}
+}
+
+// newSizedServer returns a server answering with body, labeled with contentEncoding when it is not empty.
+func newSizedServer(t *testing.T, contentEncoding string, body []byte) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentEncoding != "" {
+			w.Header().Set("Content-Encoding", contentEncoding)
+		}
+
+		_, _ = w.Write(body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// gzipTestBody returns data compressed with gzip.
+func gzipTestBody(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+
+	writer := gzip.NewWriter(buf)
+
+	_, err := writer.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// checkSizeLimitError fails t unless err is a [*ResponseSizeLimitError] with the given limit and flavor.
+func checkSizeLimitError(t *testing.T, err error, limit int64, decoded bool) {
+	t.Helper()
+
+	var limitErr *ResponseSizeLimitError
+	if errors.As(err, &limitErr) == false {
+		t.Fatalf("err = %v, want *ResponseSizeLimitError", err)
+	}
+
+	if limitErr.Limit != limit || limitErr.Decoded != decoded {
+		t.Errorf("err = %+v, want limit %d, decoded %v", limitErr, limit, decoded)
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	server := newSizedServer(t, "", bytes.Repeat([]byte("a"), 1000))
+
+	resp, err := NewHttpClient().WithMaxResponseSize(100).Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, err = resp.ToBytes()
+	checkSizeLimitError(t, err, 100, false)
+
+	// a body of exactly the limit is accepted
+	resp, err = NewHttpClient().WithMaxResponseSize(1000).Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, body, err := resp.ToBytes()
+	if err != nil || len(body) != 1000 {
+		t.Errorf("ToBytes = %d bytes, %v, want the full body", len(body), err)
+	}
+}
+
+func TestMaxResponseSizeCountsWireBytes(t *testing.T) {
+	payload := bytes.Repeat([]byte("a"), 100000)
+	compressed := gzipTestBody(t, payload)
+
+	server := newSizedServer(t, ContentEncodingGzip, compressed)
+
+	// the limit applies before decompression, so a small compressed body of a large payload passes
+	requestOption := NewRequestOption().WithHeader("Accept-Encoding", ContentEncodingGzip).
+		WithMaxResponseSize(int64(len(compressed)))
+
+	resp, err := NewHttpClient().Get(context.Background(), server.URL, requestOption, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, body, err := resp.ToBytes()
+	if err != nil || len(body) != len(payload) {
+		t.Fatalf("ToBytes = %d bytes, %v, want the decoded payload", len(body), err)
+	}
+
+	requestOption = NewRequestOption().WithHeader("Accept-Encoding", ContentEncodingGzip).
+		WithMaxResponseSize(int64(len(compressed) - 1))
+
+	resp, err = NewHttpClient().Get(context.Background(), server.URL, requestOption, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, err = resp.ToBytes()
+	checkSizeLimitError(t, err, int64(len(compressed)-1), false)
+}
+
+func TestMaxDecodedResponseSize(t *testing.T) {
+	// a small gzip body expanding to 1 MB
+	compressed := gzipTestBody(t, make([]byte, 1<<20))
+
+	tests := map[string]struct {
+		client        *HttpClient
+		requestOption *RequestOption
+	}{
+		"read helper": {
+			client:        NewHttpClient(),
+			requestOption: NewRequestOption().WithHeader("Accept-Encoding", ContentEncodingGzip),
+		},
+		"decompress transport": {
+			client: NewHttpClient().WithDecompressTransOption(NewDecompressTransOption()),
+		},
+		"net/http transparent gzip": {
+			client: NewHttpClient(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newSizedServer(t, ContentEncodingGzip, compressed)
+
+			client := test.client.WithMaxDecodedResponseSize(4096)
+
+			resp, err := client.Get(context.Background(), server.URL, test.requestOption, nil)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+
+			_, body, err := resp.ToBytes()
+			checkSizeLimitError(t, err, 4096, true)
+
+			if len(body) != 0 {
+				t.Errorf("ToBytes returned %d bytes with the error", len(body))
+			}
+		})
+	}
+}
+
+func TestMaxDecodedResponseSizeUnencoded(t *testing.T) {
+	server := newSizedServer(t, "", bytes.Repeat([]byte("a"), 1000))
+
+	resp, err := NewHttpClient().WithMaxDecodedResponseSize(100).Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, err = resp.ToBytes()
+	checkSizeLimitError(t, err, 100, true)
+}
+
+// newTricklingServer returns a server that writes chunks one at a time, flushing each and pausing for pause
+// before the next one.
+func newTricklingServer(t *testing.T, chunks []string, pause time.Duration) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i, chunk := range chunks {
+			if i > 0 {
+				select {
+				case <-time.After(pause):
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			_, _ = w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestReadIdleTimeout(t *testing.T) {
+	stalling := newTricklingServer(t, []string{"a", "b"}, 500*time.Millisecond)
+	trickling := newTricklingServer(t, []string{"a", "b", "c", "d", "e", "f"}, 40*time.Millisecond)
+
+	tests := map[string]struct {
+		server        *httptest.Server
+		clientTimeout time.Duration
+		requestOption *RequestOption
+		want          string
+	}{
+		"stalled":            {server: stalling, clientTimeout: 100 * time.Millisecond},
+		"request option":     {server: stalling, requestOption: NewRequestOption().WithReadIdleTimeout(100 * time.Millisecond)},
+		"disabled":           {server: stalling, clientTimeout: 100 * time.Millisecond, requestOption: NewRequestOption().WithReadIdleTimeout(0), want: "ab"},
+		"progressing stream": {server: trickling, clientTimeout: 200 * time.Millisecond, want: "abcdef"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewHttpClient()
+			if test.clientTimeout > 0 {
+				client.WithReadIdleTimeout(test.clientTimeout)
+			}
+
+			resp, err := client.Get(context.Background(), test.server.URL, test.requestOption, nil)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+
+			_, data, err := resp.ToBytes()
+			if test.want != "" {
+				if err != nil || string(data) != test.want {
+					t.Errorf("ToBytes = %q, %v, want %q", data, err, test.want)
+				}
+
+				return
+			}
+
+			var idleErr *ReadIdleTimeoutError
+			if errors.As(err, &idleErr) == false || idleErr.IdleTimeout != 100*time.Millisecond || idleErr.Timeout() == false {
+				t.Errorf("ToBytes = %q, %v, want *ReadIdleTimeoutError", data, err)
+			}
+		})
+	}
+
+	_, err := NewHttpClient().WithOption(OptReadIdleTimeout, 100).Get(context.Background(), stalling.URL, nil, nil)
+	if err == nil || strings.Contains(err.Error(), "thttp: invalid OptReadIdleTimeout value") == false {
+		t.Errorf("Get with an int idle timeout = %v, want an error", err)
+	}
+}
+
+// slowReadCloser returns data (and then err) from its first Read after delay, and io.EOF afterwards.
+type slowReadCloser struct {
+	delay time.Duration
+	data  string
+	err   error
+
+	done atomic.Bool
+}
+
+func (p *slowReadCloser) Read(data []byte) (int, error) {
+	if p.done.Swap(true) == true {
+		return 0, io.EOF
+	}
+
+	time.Sleep(p.delay)
+
+	return copy(data, p.data), p.err
+}
+
+func (p *slowReadCloser) Close() error {
+	return nil
+}
+
+func TestIdleTimeoutReadCloserLateRead(t *testing.T) {
+	tests := map[string]struct {
+		reader   *slowReadCloser
+		wantN    int
+		wantErr  error
+		wantNext bool
+	}{
+		"late data":  {reader: &slowReadCloser{delay: 50 * time.Millisecond, data: "abc"}, wantN: 3, wantNext: true},
+		"late eof":   {reader: &slowReadCloser{delay: 50 * time.Millisecond, data: "abc", err: io.EOF}, wantN: 3, wantErr: io.EOF},
+		"late empty": {reader: &slowReadCloser{delay: 50 * time.Millisecond}},
+		"in time":    {reader: &slowReadCloser{data: "abc"}, wantN: 3},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := newIdleTimeoutReadCloser(test.reader, 10*time.Millisecond)
+			defer reader.Close()
+
+			n, err := reader.Read(make([]byte, 8))
+
+			var idleErr *ReadIdleTimeoutError
+			if test.wantN == 0 {
+				if n != 0 || errors.As(err, &idleErr) == false {
+					t.Errorf("Read = %d, %v, want *ReadIdleTimeoutError", n, err)
+				}
+
+				return
+			}
+
+			if n != test.wantN || err != test.wantErr {
+				t.Errorf("Read = %d, %v, want %d, %v", n, err, test.wantN, test.wantErr)
+			}
+
+			if test.wantNext == false {
+				return
+			}
+
+			// the timeout that fired during the first Read is reported now
+			n, err = reader.Read(make([]byte, 8))
+			if n != 0 || errors.As(err, &idleErr) == false {
+				t.Errorf("next Read = %d, %v, want *ReadIdleTimeoutError", n, err)
+			}
+		})
+	}
+}
+
+func TestSizeLimitInvalid(t *testing.T) {
+	server := newSizedServer(t, "", []byte("ok"))
+
+	tests := map[int]string{
+		OptMaxResponseSize:        "invalid OptMaxResponseSize value: want int64, got int",
+		OptMaxDecodedResponseSize: "invalid OptMaxDecodedResponseSize value: want int64, got int",
+	}
+
+	for key, want := range tests {
+		_, err := NewHttpClient().WithOption(key, 100).Get(context.Background(), server.URL, nil, nil)
+		if err == nil || strings.Contains(err.Error(), want) == false {
+			t.Errorf("Get with an invalid option %d = %v, want %q", key, err, want)
+		}
+	}
+}
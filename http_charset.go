@@ -0,0 +1,152 @@
+package thttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// CharsetError is returned by [Response.ToText] in strict mode when the body cannot be decoded to UTF-8 cleanly,
+// either because its charset is unknown or because the bytes are invalid for that charset.
+type CharsetError struct {
+	// Charset is the declared or sniffed charset label, or empty when none could be determined.
+	Charset string
+
+	Err error
+}
+
+// Error implements [error].
+func (p *CharsetError) Error() string {
+	if p.Charset == "" {
+		return fmt.Sprintf("thttp: cannot decode response body as text: %v", p.Err)
+	}
+
+	return fmt.Sprintf("thttp: cannot decode response body as %s: %v", p.Charset, p.Err)
+}
+
+// Unwrap returns the underlying cause.
+func (p *CharsetError) Unwrap() error {
+	return p.Err
+}
+
+var (
+	// xmlEncodingReg matches the encoding pseudo-attribute of an XML declaration.
+	xmlEncodingReg = regexp.MustCompile(`^<\?xml[^>]*\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+)
+
+// sniffCharset determines the body charset from a byte order mark, the Content-Type charset parameter, or (for
+// HTML and XML media types) the document's own declaration. It returns an empty label when nothing is declared.
+func sniffCharset(contentType string, body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8"
+	case bytes.HasPrefix(body, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	case bytes.HasPrefix(body, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		label := strings.TrimSpace(params["charset"])
+		if label != "" {
+			return label
+		}
+	}
+
+	// HTML follows the WHATWG prescan, whose windows-1252 fallback is ignored for bodies that are already UTF-8
+	if strings.Contains(mediaType, "html") {
+		_, name, _ := charset.DetermineEncoding(body, contentType)
+		if name != "windows-1252" || utf8.Valid(body) == false {
+			return name
+		}
+	}
+
+	if strings.Contains(mediaType, "xml") {
+		match := xmlEncodingReg.FindSubmatch(body)
+		if match != nil {
+			return string(match[1])
+		}
+	}
+
+	return ""
+}
+
+// decodeText converts body to a UTF-8 string according to contentType. Valid UTF-8 without a declared or sniffed
+// charset is returned unchanged; other undeclared bytes are decoded with the charset detected from the content (an
+// HTML meta tag, else the WHATWG windows-1252 default) in lenient mode, which also replaces bytes that do not decode.
+// Strict mode rejects undeclared invalid UTF-8, unknown charsets, and bytes that do not decode cleanly (output
+// containing U+FFFD).
+func decodeText(contentType string, body []byte, strict bool) (string, error) {
+	label := sniffCharset(contentType, body)
+
+	var textEncoding encoding.Encoding
+	if label != "" {
+		var err error
+
+		textEncoding, err = htmlindex.Get(label)
+		if err != nil && strict {
+			return "", &CharsetError{Charset: label, Err: err}
+		}
+	}
+
+	if textEncoding == nil {
+		if utf8.Valid(body) {
+			return string(body), nil
+		}
+
+		if strict {
+			return "", &CharsetError{Err: errors.New("body is not valid UTF-8 and declares no charset")}
+		}
+
+		// unknown labels are treated as undeclared
+		textEncoding, label, _ = charset.DetermineEncoding(body, "")
+	}
+
+	name, _ := htmlindex.Name(textEncoding)
+
+	// the byte order mark selected the charset and is not part of the text
+	switch name {
+	case "utf-8":
+		body = bytes.TrimPrefix(body, []byte{0xef, 0xbb, 0xbf})
+	case "utf-16be":
+		body = bytes.TrimPrefix(body, []byte{0xfe, 0xff})
+	case "utf-16le":
+		body = bytes.TrimPrefix(body, []byte{0xff, 0xfe})
+	}
+
+	if name == "utf-8" || textEncoding == encoding.Nop {
+		if utf8.Valid(body) {
+			return string(body), nil
+		}
+
+		if strict {
+			return "", &CharsetError{Charset: label, Err: errors.New("invalid UTF-8 byte sequence")}
+		}
+
+		return strings.ToValidUTF8(string(body), string(utf8.RuneError)), nil
+	}
+
+	text, err := textEncoding.NewDecoder().Bytes(body)
+	if err != nil {
+		if strict {
+			return "", &CharsetError{Charset: label, Err: err}
+		}
+
+		return strings.ToValidUTF8(string(body), string(utf8.RuneError)), nil
+	}
+
+	if strict && bytes.ContainsRune(text, utf8.RuneError) {
+		return "", &CharsetError{Charset: label, Err: errors.New("invalid byte sequence")}
+	}
+
+	return string(text), nil
+}
@@ -0,0 +1,227 @@
+package thttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// encodeTestText encodes text with enc.
+func encodeTestText(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+
+	data, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestDecodeText(t *testing.T) {
+	const chinese = "你好，世界"
+	const japaneseText = "こんにちは世界"
+
+	tests := map[string]struct {
+		contentType string
+		body        []byte
+		want        string
+	}{
+		"declared gbk": {
+			contentType: "text/plain; charset=GBK",
+			body:        encodeTestText(t, simplifiedchinese.GBK, chinese),
+			want:        chinese,
+		},
+		"declared shift_jis": {
+			contentType: `application/json; charset="Shift_JIS"`,
+			body:        encodeTestText(t, japanese.ShiftJIS, `{"name":"`+japaneseText+`"}`),
+			want:        `{"name":"` + japaneseText + `"}`,
+		},
+		"declared latin1": {
+			contentType: "text/csv; charset=iso-8859-1",
+			body:        encodeTestText(t, charmap.ISO8859_1, "café"),
+			want:        "café",
+		},
+		"utf-8 bom": {
+			contentType: "text/plain",
+			body:        append([]byte{0xef, 0xbb, 0xbf}, chinese...),
+			want:        chinese,
+		},
+		"bom overrides declared charset": {
+			contentType: "text/plain; charset=iso-8859-1",
+			body:        append([]byte{0xef, 0xbb, 0xbf}, chinese...),
+			want:        chinese,
+		},
+		"html meta": {
+			contentType: "text/html",
+			body:        encodeTestText(t, japanese.ShiftJIS, `<html><head><meta charset="shift_jis"></head><body>`+japaneseText+`</body></html>`),
+			want:        `<html><head><meta charset="shift_jis"></head><body>` + japaneseText + `</body></html>`,
+		},
+		"html utf-8 without meta": {
+			contentType: "text/html",
+			body:        []byte("<p>" + chinese + "</p>"),
+			want:        "<p>" + chinese + "</p>",
+		},
+		"xml declaration": {
+			contentType: "application/xml",
+			body:        encodeTestText(t, simplifiedchinese.GBK, `<?xml version="1.0" encoding="GBK"?><a>`+chinese+`</a>`),
+			want:        `<?xml version="1.0" encoding="GBK"?><a>` + chinese + `</a>`,
+		},
+		"utf-16le bom": {
+			contentType: "text/plain",
+			body:        append([]byte{0xff, 0xfe}, encodeTestText(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), chinese)...),
+			want:        chinese,
+		},
+		"utf-16be bom": {
+			contentType: "application/json",
+			body:        append([]byte{0xfe, 0xff}, encodeTestText(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), chinese)...),
+			want:        chinese,
+		},
+		"declared utf-16le with bom": {
+			contentType: "text/plain; charset=utf-16le",
+			body:        append([]byte{0xff, 0xfe}, encodeTestText(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), chinese)...),
+			want:        chinese,
+		},
+		"undeclared utf-8": {
+			contentType: "text/plain",
+			body:        []byte(chinese),
+			want:        chinese,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, strict := range []bool{false, true} {
+				text, err := decodeText(test.contentType, test.body, strict)
+				if err != nil || text != test.want {
+					t.Errorf("decodeText(strict %v) = %q, %v, want %q", strict, text, err, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeTextStrict(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		body        []byte
+		lenient     string
+	}{
+		"unknown charset": {
+			contentType: "text/plain; charset=x-unknown",
+			body:        []byte("abc"),
+			lenient:     "abc",
+		},
+		"invalid utf-8": {
+			contentType: "text/plain; charset=utf-8",
+			body:        []byte{'a', 0xff, 'b'},
+			lenient:     "a�b",
+		},
+		"invalid shift_jis": {
+			contentType: "text/plain; charset=shift_jis",
+			body:        []byte{'a', 0x81, 0x20},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeText(test.contentType, test.body, true)
+
+			var charsetErr *CharsetError
+			if errors.As(err, &charsetErr) == false {
+				t.Fatalf("strict decodeText = %v, want *CharsetError", err)
+			}
+
+			text, err := decodeText(test.contentType, test.body, false)
+			if err != nil || (test.lenient != "" && text != test.lenient) {
+				t.Errorf("lenient decodeText = %q, %v, want %q", text, err, test.lenient)
+			}
+		})
+	}
+}
+
+func TestDecodeTextUndeclared(t *testing.T) {
+	gbkBody := encodeTestText(t, simplifiedchinese.GBK, "你好，世界")
+	shiftJisBody := encodeTestText(t, japanese.ShiftJIS, "こんにちは世界")
+
+	// windows1252 returns the WHATWG default decoding of data, used when nothing identifies the charset
+	windows1252 := func(data []byte) string {
+		text, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(text)
+	}
+
+	tests := map[string]struct {
+		contentType string
+		body        []byte
+		lenient     string
+	}{
+		"html meta without an html media type": {
+			body:    encodeTestText(t, simplifiedchinese.GBK, `<meta charset="gbk"><p>你好</p>`),
+			lenient: `<meta charset="gbk"><p>你好</p>`,
+		},
+		"undeclared gbk": {
+			contentType: "text/plain",
+			body:        gbkBody,
+			lenient:     windows1252(gbkBody),
+		},
+		"undeclared shift_jis": {
+			contentType: "application/json",
+			body:        shiftJisBody,
+			lenient:     windows1252(shiftJisBody),
+		},
+		"unknown charset": {
+			contentType: "text/plain; charset=x-unknown",
+			body:        []byte{'a', 0xe9},
+			lenient:     "aé",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			text, err := decodeText(test.contentType, test.body, false)
+			if err != nil || text != test.lenient {
+				t.Errorf("lenient decodeText = %q, %v, want %q", text, err, test.lenient)
+			}
+
+			// strict mode does not guess
+			_, err = decodeText(test.contentType, test.body, true)
+
+			var charsetErr *CharsetError
+			if errors.As(err, &charsetErr) == false {
+				t.Errorf("strict decodeText = %v, want *CharsetError", err)
+			}
+		})
+	}
+}
+
+func TestResponseToText(t *testing.T) {
+	body := encodeTestText(t, simplifiedchinese.GBK, "中文")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=gbk")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	resp, err := NewHttpClient().Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	statusCode, text, err := resp.ToString()
+	if err != nil || statusCode != http.StatusOK || text != "中文" {
+		t.Errorf("ToString = %d %q %v, want 200 中文", statusCode, text, err)
+	}
+}
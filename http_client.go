@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +14,7 @@ import (
 	"net/http/cookiejar"
 	"net/http/httputil"
 	_url "net/url"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,7 +42,8 @@ const (
 
 	// OptTransUnsafeTls sets [tls.Config.InsecureSkipVerify] on the transport (bool).
 	OptTransUnsafeTls
-	// OptTransTlsConfig replaces the transport TLS configuration (*[tls.Config]).
+	// OptTransTlsConfig sets the base transport TLS configuration (*[tls.Config]); the other TLS options are applied
+	// on top of a copy of it.
 	OptTransTlsConfig
 
 	// OptTransRetry attaches the retry [RoundTripper] with a [*RetryTransOption].
@@ -60,6 +62,92 @@ const (
 
 	// OptExtraResponseHookFunc is invoked after [http.Client.Do] returns.
 	OptExtraResponseHookFunc
+
+	// OptUploadProgressFunc reports request body progress for each attempt ([ProgressFunc]).
+	OptUploadProgressFunc
+	// OptDownloadProgressFunc reports response body progress as the body is read ([ProgressFunc]).
+	OptDownloadProgressFunc
+	// OptBandwidthLimit caps combined request and response body throughput (*[BandwidthLimiter]).
+	OptBandwidthLimit
+
+	// OptTransDecompress attaches the decompressing [RoundTripper] with a [*DecompressTransOption].
+	OptTransDecompress
+
+	// OptMaxResponseSize limits the response body size on the wire, before decompression (int64 bytes).
+	OptMaxResponseSize
+	// OptMaxDecodedResponseSize limits the response body size after decompression (int64 bytes).
+	OptMaxDecodedResponseSize
+
+	// OptRequestCompress compresses outgoing request bodies with a [*RequestCompressOption].
+	OptRequestCompress
+
+	// OptQueryParams appends query parameters to the request URL ([url.Values] or a struct encoded with [EncodeQuery]).
+	OptQueryParams
+
+	// OptTransAuth attaches the authenticating [RoundTripper] with an [Authenticator].
+	OptTransAuth
+
+	// OptTransSign attaches the signing [RoundTripper] with a [RequestSigner].
+	OptTransSign
+
+	// OptTransClientCert serves the transport client certificate from a [*ClientCertReloader].
+	OptTransClientCert
+
+	// OptTransRootCAs sets the CA certificates trusted for server verification (*[RootCAsOption]).
+	OptTransRootCAs
+	// OptTransPins pins server public keys per host (map[string][]string of host to base64 SPKI SHA-256 pins, merged
+	// into the pinned hosts; nil removes all pins).
+	OptTransPins
+
+	// OptTransTlsProfile applies a TLS policy preset (*[TlsProfile]).
+	OptTransTlsProfile
+	// OptTransKeyLogWriter writes TLS session secrets in NSS key log format for traffic decryption ([io.Writer]).
+	OptTransKeyLogWriter
+
+	// OptTransDialTimeout limits establishing a TCP connection ([time.Duration]; 0 means no limit).
+	OptTransDialTimeout
+	// OptTransKeepAlive sets the TCP keep-alive probe interval ([time.Duration]; negative disables keep-alives).
+	OptTransKeepAlive
+	// OptTransTlsHandshakeTimeout sets [http.Transport.TLSHandshakeTimeout] ([time.Duration]).
+	OptTransTlsHandshakeTimeout
+	// OptTransResponseHeaderTimeout sets [http.Transport.ResponseHeaderTimeout] ([time.Duration]).
+	OptTransResponseHeaderTimeout
+	// OptTransIdleConnTimeout sets [http.Transport.IdleConnTimeout] ([time.Duration]).
+	OptTransIdleConnTimeout
+	// OptTransExpectContinueTimeout sets [http.Transport.ExpectContinueTimeout] ([time.Duration]).
+	OptTransExpectContinueTimeout
+	// OptTransMaxResponseHeaderBytes sets [http.Transport.MaxResponseHeaderBytes] (int64).
+	OptTransMaxResponseHeaderBytes
+
+	// OptReadIdleTimeout fails response body reads that receive no data for this long ([time.Duration]).
+	OptReadIdleTimeout
+
+	// OptTransResolver resolves host names with overrides, custom nameservers, and caching (*[Resolver]).
+	OptTransResolver
+
+	// OptTransUnixSockets dials Unix sockets instead of TCP (map[string]string of "host:port", "host", or "*" to
+	// socket path, merged into the mapping; an empty path removes its host and nil removes all).
+	OptTransUnixSockets
+	// OptTransLocalAddr binds outgoing connections to a local IP address or network interface name (string; empty
+	// unbinds them).
+	OptTransLocalAddr
+	// OptTransIpPreference selects the IP families dialed ([IpPreference]).
+	OptTransIpPreference
+
+	// OptTransDialGuard restricts the destinations, schemes, and ports requests may reach (*[DialGuard]).
+	OptTransDialGuard
+
+	// OptTransProxyFromEnvironment proxies requests according to HTTP_PROXY, HTTPS_PROXY, and NO_PROXY (bool; false
+	// removes the proxy).
+	OptTransProxyFromEnvironment
+	// OptTransNoProxy lists hosts that bypass the proxy, in NO_PROXY syntax (string; empty clears it).
+	OptTransNoProxy
+	// OptTransProxyBasicAuth sets the proxy Basic credentials, replacing any in the proxy URL (*[net/url.Userinfo];
+	// nil removes them).
+	OptTransProxyBasicAuth
+	// OptTransProxyConnectHeader sets headers sent with proxy CONNECT requests ([http.Header], merged into the
+	// headers; an empty value removes its key and nil removes all).
+	OptTransProxyConnectHeader
 )
 
 // OptTransports lists option keys that update the shared [http.Transport] via [HttpClient.WithOption].
@@ -73,18 +161,42 @@ var (
 		OptTransMaxIdleConnsPerHost: OptTransMaxIdleConnsPerHost,
 		OptTransMaxConnsPerHost:     OptTransMaxConnsPerHost,
 
-		OptTransUnsafeTls: OptTransUnsafeTls,
-		OptTransTlsConfig: OptTransTlsConfig,
+		OptTransUnsafeTls:  OptTransUnsafeTls,
+		OptTransTlsConfig:  OptTransTlsConfig,
+		OptTransClientCert: OptTransClientCert,
+		OptTransRootCAs:    OptTransRootCAs,
+		OptTransPins:       OptTransPins,
+
+		OptTransTlsProfile:   OptTransTlsProfile,
+		OptTransKeyLogWriter: OptTransKeyLogWriter,
+
+		OptTransDialTimeout:            OptTransDialTimeout,
+		OptTransKeepAlive:              OptTransKeepAlive,
+		OptTransTlsHandshakeTimeout:    OptTransTlsHandshakeTimeout,
+		OptTransResponseHeaderTimeout:  OptTransResponseHeaderTimeout,
+		OptTransIdleConnTimeout:        OptTransIdleConnTimeout,
+		OptTransExpectContinueTimeout:  OptTransExpectContinueTimeout,
+		OptTransMaxResponseHeaderBytes: OptTransMaxResponseHeaderBytes,
+
+		OptTransResolver: OptTransResolver,
+
+		OptTransUnixSockets:  OptTransUnixSockets,
+		OptTransLocalAddr:    OptTransLocalAddr,
+		OptTransIpPreference: OptTransIpPreference,
+
+		OptTransDialGuard: OptTransDialGuard,
+
+		OptTransProxyFromEnvironment: OptTransProxyFromEnvironment,
+		OptTransNoProxy:              OptTransNoProxy,
+		OptTransProxyBasicAuth:       OptTransProxyBasicAuth,
+		OptTransProxyConnectHeader:   OptTransProxyConnectHeader,
 	}
 )
 
 // newDefaultTransport returns an [http.Transport] with dial timeouts, HTTP/2, and idle connection defaults.
 func newDefaultTransport() *http.Transport {
 	return &http.Transport{
-		DialContext: defaultTransportDialContext(&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}),
+		DialContext: newDialSettings().dialContext(),
 
 		ForceAttemptHTTP2: true,
 
@@ -155,6 +267,12 @@ func dumpDebugRequest(req *http.Request, cookieJar http.CookieJar) []byte {
 	debugReq := req.Clone(req.Context())
 	debugReq.Header = req.Header.Clone()
 
+	for key := range debugReq.Header {
+		if isSensitiveHeader(key) {
+			debugReq.Header.Set(key, redactedHeaderValue)
+		}
+	}
+
 	dumpBody := false
 	if req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
 		body, err := req.GetBody()
@@ -189,6 +307,9 @@ type clientSnapshot struct {
 
 	transport *http.Transport
 
+	dialGuard *DialGuard
+	resolver  *Resolver
+
 	cookieJar http.CookieJar
 
 	transportErr error
@@ -201,9 +322,61 @@ func defaultTransportDialContext(dialer *net.Dialer) func(context.Context, strin
 	return dialer.DialContext
 }
 
-// wrapTransport decorates transport with [OptTransLog] first, then [OptTransRetry] (retry is the outermost [http.RoundTripper]).
+// wrapTransport decorates transport with body instrumentation ([OptUploadProgressFunc], [OptDownloadProgressFunc],
+// [OptBandwidthLimit]) first, then [OptTransDecompress], then [OptTransSign], then [OptTransAuth], then
+// [OptTransLog], then [OptTransRetry] (retry is the outermost [http.RoundTripper]).
+// Authentication sits inside logging so credentials are added after the request has been logged, and signing sits
+// inside authentication so signatures cover the final headers of every attempt.
 // Invalid option value types return errors prefixed with "thttp:" and use "invalid <option> value" wording.
 func wrapTransport(transport http.RoundTripper, options map[int]interface{}) (http.RoundTripper, error) {
+	// add body transport
+	bodyTransOption, err := prepareBodyTransOption(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if bodyTransOption != nil {
+		transport = wrapBodyTransport(transport, bodyTransOption)
+	}
+
+	// add decompress transport
+	srcDecompressTransOption, ok := options[OptTransDecompress]
+	if ok == true {
+		destDecompressTransOption, ok := srcDecompressTransOption.(*DecompressTransOption)
+		if ok == true {
+			maxDecodedResponseSize, err := prepareSizeLimit(options, OptMaxDecodedResponseSize, "OptMaxDecodedResponseSize")
+			if err != nil {
+				return nil, err
+			}
+
+			transport = wrapDecompressTransport(transport, destDecompressTransOption, maxDecodedResponseSize)
+		} else {
+			return nil, fmt.Errorf("thttp: invalid OptTransDecompress value: want *DecompressTransOption, got %T", srcDecompressTransOption)
+		}
+	}
+
+	// add sign transport
+	srcRequestSigner, ok := options[OptTransSign]
+	if ok == true && srcRequestSigner != nil {
+		destRequestSigner, ok := srcRequestSigner.(RequestSigner)
+		if ok == true {
+			transport = wrapSignTransport(transport, destRequestSigner)
+		} else {
+			return nil, fmt.Errorf("thttp: invalid OptTransSign value: want RequestSigner, got %T", srcRequestSigner)
+		}
+	}
+
+	// add auth transport
+	srcAuthenticator, ok := options[OptTransAuth]
+	if ok == true && srcAuthenticator != nil {
+		destAuthenticator, ok := srcAuthenticator.(Authenticator)
+		if ok == true {
+			transport = wrapAuthTransport(transport, destAuthenticator)
+		} else {
+			return nil, fmt.Errorf("thttp: invalid OptTransAuth value: want Authenticator, got %T", srcAuthenticator)
+		}
+	}
+
 	// add log transport
 	logTransOption := defaultLogTransOption
 
@@ -272,6 +445,21 @@ func prepareCookieJar(options map[int]interface{}, defaultJar http.CookieJar) (h
 	return jar, nil
 }
 
+// prepareQueryParams appends the parameters stored under [OptQueryParams] to url via [appendParams].
+func prepareQueryParams(url string, options map[int]interface{}) (string, error) {
+	srcQueryParams, ok := options[OptQueryParams]
+	if ok == false || srcQueryParams == nil {
+		return url, nil
+	}
+
+	params, err := EncodeQuery(srcQueryParams)
+	if err != nil {
+		return "", fmt.Errorf("thttp: invalid OptQueryParams value: %w", err)
+	}
+
+	return appendParams(url, params), nil
+}
+
 // prepareRedirect returns the redirect handler from [OptRedirectPolicy] when set, or nil if unset.
 func prepareRedirect(options map[int]interface{}) (func(req *http.Request, via []*http.Request) error, error) {
 	var redirectPolicy func(req *http.Request, via []*http.Request) error
@@ -301,8 +489,14 @@ type HttpClient struct {
 	// transport is the base [http.Transport] mutated by transport-related options.
 	transport *http.Transport
 
-	connectTimeout  time.Duration
-	deadlineTimeout time.Duration
+	// tlsSettings holds the TLS options composed into [http.Transport.TLSClientConfig].
+	tlsSettings tlsSettings
+
+	// proxySettings holds the proxy options composed into [http.Transport.Proxy].
+	proxySettings proxySettings
+
+	// dialSettings holds the connection options composed into [http.Transport.DialContext].
+	dialSettings *dialSettings
 
 	// cookieJar is the optional default jar used when constructing clients if not overridden per request.
 	cookieJar http.CookieJar
@@ -333,12 +527,22 @@ func (p *HttpClient) newClientSnapshot() clientSnapshot {
 		headers[key] = val
 	}
 
+	var dialGuard *DialGuard
+	var resolver *Resolver
+	if p.dialSettings != nil {
+		dialGuard = p.dialSettings.guard
+		resolver = p.dialSettings.resolver
+	}
+
 	return clientSnapshot{
 		options: options,
 		headers: headers,
 
 		transport: p.transport,
 
+		dialGuard: dialGuard,
+		resolver:  resolver,
+
 		cookieJar: p.cookieJar,
 
 		transportErr: p.transportErr,
@@ -347,7 +551,7 @@ func (p *HttpClient) newClientSnapshot() clientSnapshot {
 	}
 }
 
-// NewHttpClient returns an [HttpClient] with a fresh transport, 30-second connect and deadline timeouts,
+// NewHttpClient returns an [HttpClient] with a fresh transport, 30-second dial timeout and keep-alive interval,
 // and a default cookie jar when [cookiejar.New] succeeds.
 // A zero [HttpClient] is usable: the first [HttpClient.Do] or [HttpClient.Transport] runs [HttpClient.lazyInitTransport]
 // to initialize the transport and default cookie jar; transport [HttpClient.WithOption] / [HttpClient.Defaults] use
@@ -359,8 +563,7 @@ func NewHttpClient() *HttpClient {
 
 		transport: newDefaultTransport(),
 
-		connectTimeout:  30 * time.Second,
-		deadlineTimeout: 30 * time.Second,
+		dialSettings: newDialSettings(),
 	}
 
 	cookieJar, err := cookiejar.New(nil)
@@ -371,8 +574,8 @@ func NewHttpClient() *HttpClient {
 	return httpClient
 }
 
-// cloneOptionValue returns a shallow copy for [*LogTransOption] and [*RetryTransOption] so stored config is not
-// aliased to a template the caller may mutate from another goroutine.
+// cloneOptionValue returns a shallow copy for [*LogTransOption], [*RetryTransOption], [*DecompressTransOption], and
+// [*RequestCompressOption] so stored config is not aliased to a template the caller may mutate from another goroutine.
 func cloneOptionValue(key int, val interface{}) interface{} {
 	switch key {
 	case OptTransLog:
@@ -400,6 +603,32 @@ func cloneOptionValue(key int, val interface{}) interface{} {
 
 		copied := *retryTransOption
 
+		return &copied
+	case OptRequestCompress:
+		requestCompressOption, ok := val.(*RequestCompressOption)
+		if !ok {
+			return val
+		}
+
+		if requestCompressOption == nil {
+			return nil
+		}
+
+		copied := *requestCompressOption
+
+		return &copied
+	case OptTransDecompress:
+		decompressTransOption, ok := val.(*DecompressTransOption)
+		if !ok {
+			return val
+		}
+
+		if decompressTransOption == nil {
+			return nil
+		}
+
+		copied := *decompressTransOption
+
 		return &copied
 	default:
 		return val
@@ -510,6 +739,13 @@ func (p *HttpClient) ensureTransportLocked() {
 	}
 }
 
+// ensureDialSettingsLocked sets [HttpClient.dialSettings] to the defaults if nil. Caller must hold [HttpClient.Lock].
+func (p *HttpClient) ensureDialSettingsLocked() {
+	if p.dialSettings == nil {
+		p.dialSettings = newDialSettings()
+	}
+}
+
 // resetTransport applies one [OptTransports] option to [HttpClient.transport]. The caller must hold [HttpClient.Lock].
 // It returns an error if val is not assignable to the documented Go type for that option key.
 func (p *HttpClient) resetTransport(key int, val interface{}) error {
@@ -557,7 +793,9 @@ func (p *HttpClient) resetTransport(key int, val interface{}) error {
 	if key == OptTransProxyFunc {
 		destProxyFunc, ok := val.(ProxyFunc)
 		if ok == true {
-			transport.Proxy = destProxyFunc
+			p.proxySettings.source = destProxyFunc
+
+			transport.Proxy = p.proxySettings.proxyFunc()
 			p.transport = transport
 
 			return nil
@@ -567,39 +805,299 @@ func (p *HttpClient) resetTransport(key int, val interface{}) error {
 	} else if key == OptTransProxyUrl {
 		destProxy, ok := val.(string)
 		if ok == true {
-			proxy := destProxy
+			proxyUrl, err := parseProxyUrl(destProxy)
+			if err != nil {
+				return fmt.Errorf("thttp: invalid OptTransProxyUrl value: %w", err)
+			}
+
+			tlog.I(context.Background()).Msgf("thttp proxy set to %s", proxyUrl.Redacted())
+
+			p.proxySettings.source = http.ProxyURL(proxyUrl)
+
+			transport.Proxy = p.proxySettings.proxyFunc()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransProxyUrl value: want string, got %T", val)
+	} else if key == OptTransProxyFromEnvironment {
+		destProxyFromEnvironment, ok := val.(bool)
+		if ok == true {
+			p.proxySettings.source = nil
+
+			if destProxyFromEnvironment == true {
+				proxyFunc, description := environmentProxyFunc()
+
+				tlog.I(context.Background()).Msgf("thttp proxy set from environment: %s", description)
+
+				p.proxySettings.source = proxyFunc
+			}
+
+			transport.Proxy = p.proxySettings.proxyFunc()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransProxyFromEnvironment value: want bool, got %T", val)
+	} else if key == OptTransNoProxy {
+		destNoProxy, ok := val.(string)
+		if ok == true {
+			p.proxySettings.noProxy = destNoProxy
+
+			transport.Proxy = p.proxySettings.proxyFunc()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransNoProxy value: want string, got %T", val)
+	} else if key == OptTransProxyBasicAuth {
+		destProxyBasicAuth, ok := val.(*_url.Userinfo)
+		if ok == true || val == nil {
+			p.proxySettings.auth = destProxyBasicAuth
+
+			transport.Proxy = p.proxySettings.proxyFunc()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransProxyBasicAuth value: want *url.Userinfo, got %T", val)
+	} else if key == OptTransProxyConnectHeader {
+		destProxyConnectHeader, ok := val.(http.Header)
+		if ok == true || val == nil {
+			connectHeader := http.Header{}
+			if destProxyConnectHeader != nil {
+				connectHeader = transport.ProxyConnectHeader.Clone()
+				if connectHeader == nil {
+					connectHeader = http.Header{}
+				}
+			}
+
+			for headerKey, vals := range destProxyConnectHeader {
+				if len(vals) == 0 || (len(vals) == 1 && vals[0] == "") {
+					connectHeader.Del(headerKey)
+				} else {
+					connectHeader[http.CanonicalHeaderKey(headerKey)] = append([]string(nil), vals...)
+				}
+			}
+
+			if len(connectHeader) == 0 {
+				connectHeader = nil
+			}
+
+			transport.ProxyConnectHeader = connectHeader
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransProxyConnectHeader value: want http.Header, got %T", val)
+	}
+
+	// timeouts
+	if key == OptTransDialTimeout {
+		destDialTimeout, ok := val.(time.Duration)
+		if ok == true {
+			p.ensureDialSettingsLocked()
+
+			p.dialSettings.timeout = destDialTimeout
+
+			transport.DialContext = p.dialSettings.dialContext()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransDialTimeout value: want time.Duration, got %T", val)
+	}
+
+	if key == OptTransKeepAlive {
+		destKeepAlive, ok := val.(time.Duration)
+		if ok == true {
+			p.ensureDialSettingsLocked()
+
+			p.dialSettings.keepAlive = destKeepAlive
 
-			if strings.Contains(proxy, "://") == false {
-				proxy = "http://" + proxy
+			transport.DialContext = p.dialSettings.dialContext()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransKeepAlive value: want time.Duration, got %T", val)
+	}
+
+	if key == OptTransResolver {
+		destResolver, ok := val.(*Resolver)
+		if ok == true || val == nil {
+			if destResolver != nil && destResolver.err != nil {
+				return destResolver.err
+			}
+
+			p.ensureDialSettingsLocked()
+
+			p.dialSettings.resolver = destResolver
+
+			transport.DialContext = p.dialSettings.dialContext()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransResolver value: want *Resolver, got %T", val)
+	}
+
+	if key == OptTransUnixSockets {
+		destUnixSockets, ok := val.(map[string]string)
+		if ok == true || val == nil {
+			p.ensureDialSettingsLocked()
+
+			if destUnixSockets == nil {
+				p.dialSettings.unixSockets = nil
 			}
 
-			proxyUrl, err := _url.Parse(proxy)
+			p.dialSettings.setUnixSockets(destUnixSockets)
+
+			transport.DialContext = p.dialSettings.dialContext()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransUnixSockets value: want map[string]string, got %T", val)
+	}
+
+	if key == OptTransLocalAddr {
+		destLocalAddr, ok := val.(string)
+		if ok == true {
+			p.ensureDialSettingsLocked()
+
+			err := p.dialSettings.setLocalAddr(destLocalAddr)
 			if err != nil {
 				return err
 			}
 
-			transport.Proxy = http.ProxyURL(proxyUrl)
+			transport.DialContext = p.dialSettings.dialContext()
 			p.transport = transport
 
 			return nil
 		}
 
-		return fmt.Errorf("thttp: invalid OptTransProxyUrl value: want string, got %T", val)
+		return fmt.Errorf("thttp: invalid OptTransLocalAddr value: want string, got %T", val)
+	}
+
+	if key == OptTransIpPreference {
+		destIpPreference, ok := val.(IpPreference)
+		if ok == true {
+			if destIpPreference < IpPreferenceAuto || destIpPreference > IpPreferenceIpv6Only {
+				return fmt.Errorf("thttp: invalid OptTransIpPreference value: unknown preference %d", destIpPreference)
+			}
+
+			p.ensureDialSettingsLocked()
+
+			p.dialSettings.ipPreference = destIpPreference
+
+			transport.DialContext = p.dialSettings.dialContext()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransIpPreference value: want IpPreference, got %T", val)
+	}
+
+	if key == OptTransDialGuard {
+		destDialGuard, ok := val.(*DialGuard)
+		if ok == true || val == nil {
+			if destDialGuard != nil && destDialGuard.err != nil {
+				return destDialGuard.err
+			}
+
+			p.ensureDialSettingsLocked()
+
+			p.dialSettings.guard = destDialGuard
+
+			transport.DialContext = p.dialSettings.dialContext()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransDialGuard value: want *DialGuard, got %T", val)
+	}
+
+	if key == OptTransTlsHandshakeTimeout {
+		destTlsHandshakeTimeout, ok := val.(time.Duration)
+		if ok == true {
+			transport.TLSHandshakeTimeout = destTlsHandshakeTimeout
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransTlsHandshakeTimeout value: want time.Duration, got %T", val)
+	}
+
+	if key == OptTransResponseHeaderTimeout {
+		destResponseHeaderTimeout, ok := val.(time.Duration)
+		if ok == true {
+			transport.ResponseHeaderTimeout = destResponseHeaderTimeout
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransResponseHeaderTimeout value: want time.Duration, got %T", val)
+	}
+
+	if key == OptTransIdleConnTimeout {
+		destIdleConnTimeout, ok := val.(time.Duration)
+		if ok == true {
+			transport.IdleConnTimeout = destIdleConnTimeout
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransIdleConnTimeout value: want time.Duration, got %T", val)
+	}
+
+	if key == OptTransExpectContinueTimeout {
+		destExpectContinueTimeout, ok := val.(time.Duration)
+		if ok == true {
+			transport.ExpectContinueTimeout = destExpectContinueTimeout
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransExpectContinueTimeout value: want time.Duration, got %T", val)
+	}
+
+	if key == OptTransMaxResponseHeaderBytes {
+		destMaxResponseHeaderBytes, ok := val.(int64)
+		if ok == true {
+			transport.MaxResponseHeaderBytes = destMaxResponseHeaderBytes
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransMaxResponseHeaderBytes value: want int64, got %T", val)
 	}
 
+	// tls
 	if key == OptTransUnsafeTls {
 		destUnsafeTls, ok := val.(bool)
 		if ok == true {
 			unsafeTls := destUnsafeTls
 
-			tlsConfig := transport.TLSClientConfig
+			p.tlsSettings.unsafe = &unsafeTls
 
-			if tlsConfig == nil {
-				tlsConfig = &tls.Config{}
-				transport.TLSClientConfig = tlsConfig
-			}
-
-			tlsConfig.InsecureSkipVerify = unsafeTls
+			transport.TLSClientConfig = p.tlsSettings.build()
 			p.transport = transport
 
 			return nil
@@ -611,11 +1109,105 @@ func (p *HttpClient) resetTransport(key int, val interface{}) error {
 	if key == OptTransTlsConfig {
 		destTlsConfig, ok := val.(*tls.Config)
 		if ok == true {
-			transport.TLSClientConfig = destTlsConfig
+			p.tlsSettings.base = destTlsConfig
+
+			transport.TLSClientConfig = p.tlsSettings.build()
 			p.transport = transport
-		} else {
-			return fmt.Errorf("thttp: invalid OptTransTlsConfig value: want *tls.Config, got %T", val)
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransTlsConfig value: want *tls.Config, got %T", val)
+	}
+
+	if key == OptTransClientCert {
+		destClientCert, ok := val.(*ClientCertReloader)
+		if ok == true || val == nil {
+			p.tlsSettings.clientCert = destClientCert
+			p.tlsSettings.warnRootCAsMerge()
+
+			transport.TLSClientConfig = p.tlsSettings.build()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransClientCert value: want *ClientCertReloader, got %T", val)
+	}
+
+	if key == OptTransRootCAs {
+		destRootCAs, ok := val.(*RootCAsOption)
+		if ok == true || val == nil {
+			var rootCAs *x509.CertPool
+
+			if destRootCAs != nil {
+				pool, err := destRootCAs.certPool()
+				if err != nil {
+					return err
+				}
+
+				rootCAs = pool
+			}
+
+			p.tlsSettings.rootCAs = rootCAs
+			p.tlsSettings.warnRootCAsMerge()
+
+			transport.TLSClientConfig = p.tlsSettings.build()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransRootCAs value: want *RootCAsOption, got %T", val)
+	}
+
+	if key == OptTransPins {
+		destPins, ok := val.(map[string][]string)
+		if ok == true || val == nil {
+			if destPins == nil {
+				p.tlsSettings.pins = nil
+			}
+
+			err := p.tlsSettings.setPins(destPins)
+			if err != nil {
+				return err
+			}
+
+			transport.TLSClientConfig = p.tlsSettings.build()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransPins value: want map[string][]string, got %T", val)
+	}
+
+	if key == OptTransTlsProfile {
+		destTlsProfile, ok := val.(*TlsProfile)
+		if ok == true || val == nil {
+			p.tlsSettings.setProfile(destTlsProfile)
+
+			transport.TLSClientConfig = p.tlsSettings.build()
+			p.transport = transport
+
+			return nil
 		}
+
+		return fmt.Errorf("thttp: invalid OptTransTlsProfile value: want *TlsProfile, got %T", val)
+	}
+
+	if key == OptTransKeyLogWriter {
+		destKeyLogWriter, ok := val.(io.Writer)
+		if ok == true || val == nil {
+			p.tlsSettings.setKeyLogWriter(destKeyLogWriter)
+
+			transport.TLSClientConfig = p.tlsSettings.build()
+			p.transport = transport
+
+			return nil
+		}
+
+		return fmt.Errorf("thttp: invalid OptTransKeyLogWriter value: want io.Writer, got %T", val)
 	}
 
 	return nil
@@ -623,7 +1215,7 @@ func (p *HttpClient) resetTransport(key int, val interface{}) error {
 
 // WithOption sets a client-wide option. Keys listed in [OptTransports] update the shared [http.Transport];
 // other keys are stored for use when [HttpClient.Do] builds the [http.Client].
-// [*LogTransOption] and [*RetryTransOption] are shallow-copied when stored so callers can reuse the same template safely.
+// Option structs such as [*LogTransOption] and [*RetryTransOption] are shallow-copied when stored so callers can reuse the same template safely.
 // A failed transport update is logged and causes subsequent [HttpClient.Do] calls to return that error until a transport option applies successfully ([HttpClient.Defaults] behaves the same for transport keys).
 func (p *HttpClient) WithOption(key int, val interface{}) *HttpClient {
 	p.Lock()
@@ -656,6 +1248,88 @@ func (p *HttpClient) WithTimeout(timeout time.Duration) *HttpClient {
 	return p.WithOption(OptTimeout, timeout)
 }
 
+// WithDialTimeout limits how long establishing a TCP connection may take ([OptTransDialTimeout], default
+// [DefaultDialTimeout]); 0 means no limit beyond the request context.
+func (p *HttpClient) WithDialTimeout(timeout time.Duration) *HttpClient {
+	return p.WithOption(OptTransDialTimeout, timeout)
+}
+
+// WithKeepAlive sets the TCP keep-alive probe interval ([OptTransKeepAlive], default [DefaultKeepAlive]); a
+// negative value disables keep-alive probes.
+func (p *HttpClient) WithKeepAlive(keepAlive time.Duration) *HttpClient {
+	return p.WithOption(OptTransKeepAlive, keepAlive)
+}
+
+// WithResolver resolves host names through resolver ([OptTransResolver]) for static overrides, custom nameservers,
+// and DNS caching; nil restores the system resolver. Proxied requests resolve only the proxy host.
+func (p *HttpClient) WithResolver(resolver *Resolver) *HttpClient {
+	return p.WithOption(OptTransResolver, resolver)
+}
+
+// WithUnixSocket sends every request over the Unix socket at path ([OptTransUnixSockets]), for example
+// "/var/run/docker.sock"; the URL host still sets the Host header and TLS server name. An empty path removes it.
+func (p *HttpClient) WithUnixSocket(path string) *HttpClient {
+	return p.WithOption(OptTransUnixSockets, map[string]string{"*": path})
+}
+
+// WithUnixSocketFor sends requests for host, given as "host" or "host:port", over the Unix socket at path
+// ([OptTransUnixSockets]). Calls for different hosts accumulate, an exact "host:port" entry wins over "host", and an
+// empty path removes host. With a proxy, the proxy address is the one matched.
+func (p *HttpClient) WithUnixSocketFor(host string, path string) *HttpClient {
+	return p.WithOption(OptTransUnixSockets, map[string]string{host: path})
+}
+
+// WithLocalAddr binds outgoing TCP connections to addr ([OptTransLocalAddr]): an IP address, which restricts
+// dialing to its family, or a network interface name such as "eth1", whose address in the family of each dial is
+// used. An empty addr lets the system choose.
+func (p *HttpClient) WithLocalAddr(addr string) *HttpClient {
+	return p.WithOption(OptTransLocalAddr, addr)
+}
+
+// WithIpPreference selects the IP families dialed ([OptTransIpPreference], default [IpPreferenceAuto]). Preferring
+// a family falls back to the other one only after the preferred family fails, instead of racing both.
+func (p *HttpClient) WithIpPreference(preference IpPreference) *HttpClient {
+	return p.WithOption(OptTransIpPreference, preference)
+}
+
+// WithDialGuard restricts the destinations this client may reach ([OptTransDialGuard]), see [NewDialGuard]; nil
+// removes the restriction. Refused requests fail with [*BlockedDestinationError]. Through a proxy the destination is
+// resolved and checked before sending (see [DialGuard]), and [HttpClient.Transport] used directly skips the scheme,
+// port, and proxied destination checks.
+func (p *HttpClient) WithDialGuard(guard *DialGuard) *HttpClient {
+	return p.WithOption(OptTransDialGuard, guard)
+}
+
+// WithTlsHandshakeTimeout limits the TLS handshake ([OptTransTlsHandshakeTimeout], default 10 seconds); 0 means
+// no limit.
+func (p *HttpClient) WithTlsHandshakeTimeout(timeout time.Duration) *HttpClient {
+	return p.WithOption(OptTransTlsHandshakeTimeout, timeout)
+}
+
+// WithResponseHeaderTimeout limits the wait for response headers after the request has been written
+// ([OptTransResponseHeaderTimeout]); it does not limit reading the body. 0 (the default) means no limit.
+func (p *HttpClient) WithResponseHeaderTimeout(timeout time.Duration) *HttpClient {
+	return p.WithOption(OptTransResponseHeaderTimeout, timeout)
+}
+
+// WithIdleConnTimeout sets how long an idle keep-alive connection stays in the pool ([OptTransIdleConnTimeout],
+// default 90 seconds); 0 means no limit.
+func (p *HttpClient) WithIdleConnTimeout(timeout time.Duration) *HttpClient {
+	return p.WithOption(OptTransIdleConnTimeout, timeout)
+}
+
+// WithExpectContinueTimeout sets how long a request with "Expect: 100-continue" waits for the server's first
+// response headers before sending the body ([OptTransExpectContinueTimeout], default 1 second).
+func (p *HttpClient) WithExpectContinueTimeout(timeout time.Duration) *HttpClient {
+	return p.WithOption(OptTransExpectContinueTimeout, timeout)
+}
+
+// WithMaxResponseHeaderBytes limits the size of response headers ([OptTransMaxResponseHeaderBytes]); 0 uses the
+// net/http default.
+func (p *HttpClient) WithMaxResponseHeaderBytes(maxBytes int64) *HttpClient {
+	return p.WithOption(OptTransMaxResponseHeaderBytes, maxBytes)
+}
+
 // WithProxyUrl configures a static HTTP proxy address (for example "host:port").
 func (p *HttpClient) WithProxyUrl(addr string) *HttpClient {
 	return p.WithOption(OptTransProxyUrl, addr)
@@ -666,11 +1340,126 @@ func (p *HttpClient) WithProxyFunc(option ProxyFunc) *HttpClient {
 	return p.WithOption(OptTransProxyFunc, option)
 }
 
+// WithProxyFromEnvironment proxies requests according to the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment
+// variables or their lowercase forms ([OptTransProxyFromEnvironment]). The variables are read when this is called;
+// localhost and loopback addresses are never proxied.
+func (p *HttpClient) WithProxyFromEnvironment() *HttpClient {
+	return p.WithOption(OptTransProxyFromEnvironment, true)
+}
+
+// WithNoProxy sends requests for hosts directly, bypassing whichever proxy is set ([OptTransNoProxy]). Entries use
+// NO_PROXY syntax: "example.com" also matches subdomains, ".example.com" only subdomains, "host:port" one port, a
+// CIDR a range of IP literals, and "*" every host. Calling with no hosts clears the list.
+func (p *HttpClient) WithNoProxy(hosts ...string) *HttpClient {
+	return p.WithOption(OptTransNoProxy, strings.Join(hosts, ","))
+}
+
+// WithProxyBasicAuth authenticates to the proxy with Basic credentials ([OptTransProxyBasicAuth]), which replace any
+// in the proxy URL. They are stored apart from the proxy URL and never logged.
+func (p *HttpClient) WithProxyBasicAuth(username string, password string) *HttpClient {
+	return p.WithOption(OptTransProxyBasicAuth, _url.UserPassword(username, password))
+}
+
+// WithProxyConnectHeader sends a header with the CONNECT requests that open HTTPS tunnels through the proxy
+// ([OptTransProxyConnectHeader]). Calls for different keys accumulate, and an empty value removes key.
+func (p *HttpClient) WithProxyConnectHeader(key string, value string) *HttpClient {
+	return p.WithOption(OptTransProxyConnectHeader, http.Header{key: []string{value}})
+}
+
 // WithUnsafeTls controls whether TLS certificate verification is skipped for HTTPS requests.
 func (p *HttpClient) WithUnsafeTls(unsafe bool) *HttpClient {
 	return p.WithOption(OptTransUnsafeTls, unsafe)
 }
 
+// WithClientCertFiles serves the client certificate at certPath / keyPath for mutual TLS and, when caPath is not
+// empty, verifies servers against the CA bundle at caPath instead of the system roots. The files are reloaded when
+// they change (see [ClientCertReloader]), so rotation needs no new client. A failed initial load is logged and
+// returned by subsequent [HttpClient.Do] calls, like other transport option errors.
+//
+// With a CA bundle, servers are verified by the client rather than during the handshake, so that the reloaded
+// bundle is used: a server is also accepted when it verifies against [HttpClient.WithRootCAs] or the RootCAs of
+// [OptTransTlsConfig] (a warning is logged when root CAs are set as well), and hosts given as IP addresses always
+// fail verification, as their name is not available to check against the certificate.
+func (p *HttpClient) WithClientCertFiles(certPath string, keyPath string, caPath string) *HttpClient {
+	reloader, err := NewClientCertReloader(certPath, keyPath, caPath)
+	if err != nil {
+		p.Lock()
+		defer p.Unlock()
+
+		p.transportErr = err
+
+		return p
+	}
+
+	return p.WithOption(OptTransClientCert, reloader)
+}
+
+// WithClientCertReloader serves the client certificate (and CA bundle) of reloader ([OptTransClientCert]); nil
+// removes it.
+func (p *HttpClient) WithClientCertReloader(reloader *ClientCertReloader) *HttpClient {
+	return p.WithOption(OptTransClientCert, reloader)
+}
+
+// WithRootCAs trusts the CA certificates of option for server verification, added to the system pool unless
+// [RootCAsOption.WithReplaceSystem] is set ([OptTransRootCAs]); nil restores the default roots. Prefer this to
+// [HttpClient.WithUnsafeTls] for private CAs. It composes with [OptTransTlsConfig], whose RootCAs it overrides, and
+// with the CA bundle of [HttpClient.WithClientCertFiles]: servers verified by either are trusted.
+func (p *HttpClient) WithRootCAs(option *RootCAsOption) *HttpClient {
+	return p.WithOption(OptTransRootCAs, option)
+}
+
+// WithPinnedKeys requires servers for host to present a certificate whose public key matches one of pins
+// ([OptTransPins]), in addition to normal verification; pins are base64 SHA-256 SPKI digests (see [SpkiPin]),
+// optionally prefixed with "sha256/". host may be "*.example.com" for direct subdomains or "*" for every host,
+// including IP literals, which cannot be pinned individually. Calls for different hosts accumulate, and calling with
+// no pins removes host. A mismatch fails the request with [*PinViolationError].
+func (p *HttpClient) WithPinnedKeys(host string, pins ...string) *HttpClient {
+	return p.WithOption(OptTransPins, map[string][]string{host: pins})
+}
+
+// WithTlsProfile applies a TLS policy preset such as [NewModernTlsProfile], [NewIntermediateTlsProfile], or
+// [NewFipsTlsProfile] ([OptTransTlsProfile]); nil removes it. The profile overrides the versions, cipher suites, and
+// curves of [OptTransTlsConfig].
+func (p *HttpClient) WithTlsProfile(profile *TlsProfile) *HttpClient {
+	return p.WithOption(OptTransTlsProfile, profile)
+}
+
+// WithKeyLogWriter writes TLS session secrets to writer in NSS key log format so captured traffic can be decrypted,
+// for example in Wireshark ([OptTransKeyLogWriter]); nil disables it. This defeats TLS for anyone who obtains the
+// output, so enabling it logs a warning; use it for debugging only.
+func (p *HttpClient) WithKeyLogWriter(writer io.Writer) *HttpClient {
+	return p.WithOption(OptTransKeyLogWriter, writer)
+}
+
+// WithKeyLogFile appends TLS session secrets to the file at path, or at $SSLKEYLOGFILE when path is empty (see
+// [HttpClient.WithKeyLogWriter]). The file is created with mode 0600 and closed when key logging is replaced or
+// disabled; an open failure is logged and returned by subsequent [HttpClient.Do] calls.
+func (p *HttpClient) WithKeyLogFile(path string) *HttpClient {
+	if path == "" {
+		path = os.Getenv("SSLKEYLOGFILE")
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if path == "" {
+		err = errors.New("thttp: opening TLS key log file failed: no path given and SSLKEYLOGFILE is not set")
+	} else if err != nil {
+		err = fmt.Errorf("thttp: opening TLS key log file failed: %w", err)
+	}
+
+	if err != nil {
+		tlog.E(context.Background()).Err(err).Msgf("thttp transport option update failed: %v", err)
+
+		p.Lock()
+		defer p.Unlock()
+
+		p.transportErr = err
+
+		return p
+	}
+
+	return p.WithOption(OptTransKeyLogWriter, &keyLogFile{File: file})
+}
+
 // WithRetryTransOption enables the retrying [http.RoundTripper] with the supplied configuration.
 func (p *HttpClient) WithRetryTransOption(option *RetryTransOption) *HttpClient {
 	return p.WithOption(OptTransRetry, option)
@@ -681,6 +1470,79 @@ func (p *HttpClient) WithLogTransOption(option *LogTransOption) *HttpClient {
 	return p.WithOption(OptTransLog, option)
 }
 
+// WithDecompressTransOption enables the decompressing [http.RoundTripper], which advertises Accept-Encoding and
+// transparently decodes gzip, br, zstd, and deflate responses (including stacked encodings).
+func (p *HttpClient) WithDecompressTransOption(option *DecompressTransOption) *HttpClient {
+	return p.WithOption(OptTransDecompress, option)
+}
+
+// WithAuthenticator authenticates every request with authenticator ([OptTransAuth]); credentials are applied per
+// attempt in a transport layer and never logged. Redirects to another host are sent without credentials unless the
+// redirect policy calls [AllowRedirectCredentials]. A nil authenticator removes it.
+func (p *HttpClient) WithAuthenticator(authenticator Authenticator) *HttpClient {
+	return p.WithOption(OptTransAuth, authenticator)
+}
+
+// WithBasicAuth authenticates every request with HTTP Basic credentials. See [NewBasicAuth].
+func (p *HttpClient) WithBasicAuth(username string, password string) *HttpClient {
+	return p.WithAuthenticator(NewBasicAuth(username, password))
+}
+
+// WithBearerToken authenticates every request with a static bearer token. See [NewBearerAuth].
+func (p *HttpClient) WithBearerToken(token string) *HttpClient {
+	return p.WithAuthenticator(NewBearerAuth(token))
+}
+
+// WithDigestAuth authenticates every request with HTTP Digest credentials. See [NewDigestAuth].
+func (p *HttpClient) WithDigestAuth(username string, password string) *HttpClient {
+	return p.WithAuthenticator(NewDigestAuth(username, password))
+}
+
+// WithTokenSource authenticates every request with OAuth2 tokens from source, cached until near expiry and
+// refreshed once on HTTP 401. See [NewTokenAuth].
+func (p *HttpClient) WithTokenSource(source TokenSource) *HttpClient {
+	return p.WithAuthenticator(NewTokenAuth(source))
+}
+
+// WithRequestSigner signs every attempt of every request with signer ([OptTransSign]), such as a [*SigV4Signer].
+// Redirects to another host are sent unsigned unless the redirect policy calls [AllowRedirectCredentials]. A nil
+// signer removes it.
+func (p *HttpClient) WithRequestSigner(signer RequestSigner) *HttpClient {
+	return p.WithOption(OptTransSign, signer)
+}
+
+// WithQuery sets query parameters appended to every request URL ([OptQueryParams]). params may be [url.Values], a
+// map, or a struct encoded with [EncodeQuery]; per-request [RequestOption.WithQuery] replaces it.
+func (p *HttpClient) WithQuery(params interface{}) *HttpClient {
+	return p.WithOption(OptQueryParams, params)
+}
+
+// WithRequestCompressOption compresses request bodies at or above the configured size threshold and sets
+// Content-Encoding ([OptRequestCompress]). It applies to [HttpClient.Do] and every body helper, and retries replay
+// the compressed bytes.
+func (p *HttpClient) WithRequestCompressOption(option *RequestCompressOption) *HttpClient {
+	return p.WithOption(OptRequestCompress, option)
+}
+
+// WithReadIdleTimeout fails a response body read with [*ReadIdleTimeoutError] when no data arrives for timeout
+// ([OptReadIdleTimeout]). Unlike [HttpClient.WithTimeout] it does not bound the whole exchange, so long downloads
+// and streams can run indefinitely as long as they keep making progress. timeout <= 0 disables it.
+func (p *HttpClient) WithReadIdleTimeout(timeout time.Duration) *HttpClient {
+	return p.WithOption(OptReadIdleTimeout, timeout)
+}
+
+// WithMaxResponseSize limits response bodies to maxSize bytes on the wire ([OptMaxResponseSize]); reads beyond the
+// limit fail with [*ResponseSizeLimitError]. maxSize <= 0 removes the limit.
+func (p *HttpClient) WithMaxResponseSize(maxSize int64) *HttpClient {
+	return p.WithOption(OptMaxResponseSize, maxSize)
+}
+
+// WithMaxDecodedResponseSize limits response bodies to maxSize bytes after decompression ([OptMaxDecodedResponseSize]),
+// guarding against decompression bombs. maxSize <= 0 removes the limit.
+func (p *HttpClient) WithMaxDecodedResponseSize(maxSize int64) *HttpClient {
+	return p.WithOption(OptMaxDecodedResponseSize, maxSize)
+}
+
 // WithCookieJar sets the default [http.CookieJar] for this client ([OptCookieJar]).
 func (p *HttpClient) WithCookieJar(jar http.CookieJar) *HttpClient {
 	return p.WithOption(OptCookieJar, jar)
@@ -701,6 +1563,27 @@ func (p *HttpClient) WithResponseHookFunc(option ResponseHookFunc) *HttpClient {
 	return p.WithOption(OptExtraResponseHookFunc, option)
 }
 
+// WithUploadProgressFunc registers a callback reporting request body progress ([OptUploadProgressFunc]).
+func (p *HttpClient) WithUploadProgressFunc(option ProgressFunc) *HttpClient {
+	return p.WithOption(OptUploadProgressFunc, option)
+}
+
+// WithDownloadProgressFunc registers a callback reporting response body progress ([OptDownloadProgressFunc]).
+func (p *HttpClient) WithDownloadProgressFunc(option ProgressFunc) *HttpClient {
+	return p.WithOption(OptDownloadProgressFunc, option)
+}
+
+// WithBandwidthLimit caps request and response body throughput for all requests from this client to bytesPerSec
+// bytes per second ([OptBandwidthLimit]). The budget is shared by concurrent requests; bytesPerSec <= 0 removes the limit.
+func (p *HttpClient) WithBandwidthLimit(bytesPerSec int64) *HttpClient {
+	return p.WithOption(OptBandwidthLimit, NewBandwidthLimiter(bytesPerSec))
+}
+
+// WithBandwidthLimiter installs a [BandwidthLimiter] that may be shared with other clients ([OptBandwidthLimit]).
+func (p *HttpClient) WithBandwidthLimiter(limiter *BandwidthLimiter) *HttpClient {
+	return p.WithOption(OptBandwidthLimit, limiter)
+}
+
 // WithOptions applies multiple options in sequence.
 func (p *HttpClient) WithOptions(options map[int]interface{}) *HttpClient {
 	p.Lock()
@@ -780,9 +1663,12 @@ func (p *HttpClient) WithHeaders(headers map[string]string) *HttpClient {
 	return p
 }
 
-// Do executes an HTTP request: it merges client defaults with requestOption, builds an [http.Client] with
-// wrapped transport (logging, then retry), and returns a [Response]. Request bodies are passed through as-is;
-// replay only happens when [http.Request.GetBody] is already available or the retry layer chooses to buffer.
+// Do executes an HTTP request: it merges client defaults with requestOption, builds an [http.Client] with a
+// wrapped transport, and returns a [Response]. From the base transport outwards the layers are the dial guard, body
+// (progress, bandwidth, and size limits), decompression, signing, authentication, logging, and retry; every layer
+// but logging is present only when its option is set. Retries therefore re-sign and re-authenticate each attempt,
+// and the guard checks every redirect hop. Replayable request bodies at or above the [OptRequestCompress] threshold
+// are compressed once, and [http.Request.GetBody] then replays the compressed payload on retries and redirects.
 func (p *HttpClient) Do(ctx context.Context, method string, url string, requestOption *RequestOption, body io.Reader) (*Response, error) {
 	p.lazyInitTransport()
 
@@ -809,7 +1695,18 @@ func (p *HttpClient) Do(ctx context.Context, method string, url string, requestO
 		cookies = requestSnapshot.cookies
 	}
 
-	transport, err := wrapTransport(snapshot.transport, options)
+	var baseTransport http.RoundTripper = snapshot.transport
+	if snapshot.dialGuard != nil {
+		baseTransport = wrapGuardTransport(snapshot.transport, snapshot.dialGuard, snapshot.resolver)
+	}
+
+	transport, err := wrapTransport(baseTransport, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the read helpers of the response enforce the decoded size limit too
+	maxDecodedResponseSize, err := prepareSizeLimit(options, OptMaxDecodedResponseSize, "OptMaxDecodedResponseSize")
 	if err != nil {
 		return nil, err
 	}
@@ -844,11 +1741,26 @@ func (p *HttpClient) Do(ctx context.Context, method string, url string, requestO
 
 	client.Timeout = timeout
 
+	url, err = prepareQueryParams(url, options)
+	if err != nil {
+		return nil, err
+	}
+
 	request, err := prepareRequest(ctx, method, url, headers, body)
 	if err != nil {
 		return nil, err
 	}
 
+	requestCompressOption, err := prepareRequestCompressOption(options)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err = compressRequestBody(request, requestCompressOption)
+	if err != nil {
+		return nil, err
+	}
+
 	if cookieJar != nil {
 		cookieJar.SetCookies(request.URL, cookies)
 	} else {
@@ -872,6 +1784,9 @@ func (p *HttpClient) Do(ctx context.Context, method string, url string, requestO
 		}
 	}
 
+	// credentials and signatures are only sent to the host of this request, not to redirect targets
+	request = request.WithContext(withCredentialScope(request.Context(), request.URL))
+
 	isAbnormal := false
 
 	response, err := client.Do(request)
@@ -885,13 +1800,18 @@ func (p *HttpClient) Do(ctx context.Context, method string, url string, requestO
 		event := tlog.W(request.Context()).Err(err).Detailf("req.method: %s", request.Method).
 			Detailf("req.host: %s", request.Host).Detailf("req.url: %s", request.URL.String())
 
-		bodyBytes := snapshotRequestBody(request)
-		if bodyBytes != nil {
-			event = event.Detailf("req.body: %s", string(bodyBytes))
+		// a compressed body is binary, so only its sizes are logged
+		if requestCompressStatsFromContext(request.Context()) != nil {
+			event = withCompressDetail(event, request)
+		} else {
+			bodyBytes := snapshotRequestBody(request)
+			if bodyBytes != nil {
+				event = event.Detailf("req.body: %s", string(bodyBytes))
+			}
 		}
 
 		for key, vals := range request.Header {
-			event = event.Detailf("req.header.%s: %s", key, strings.Join(vals, ";"))
+			event = event.Detailf("req.header.%s: %s", key, headerLogValue(key, vals))
 		}
 
 		if response != nil {
@@ -913,11 +1833,20 @@ func (p *HttpClient) Do(ctx context.Context, method string, url string, requestO
 		return nil, err
 	}
 
-	return &Response{response}, err
+	// the OptTransDecompress type was validated by wrapTransport
+	decompressTransOption, _ := options[OptTransDecompress].(*DecompressTransOption)
+
+	return &Response{
+		Response: response,
+
+		maxDecodedResponseSize: maxDecodedResponseSize,
+		decompressTransOption:  decompressTransOption,
+	}, err
 }
 
 // send converts params to an [io.Reader] for the given verb helpers and calls [HttpClient.Do].
-// Supported types for params are listed in the error returned from the default branch.
+// Structs are form-encoded with [EncodeForm]; supported types for params are listed in the error returned from the
+// default branch.
 func (p *HttpClient) send(ctx context.Context, method string, url string, requestOption *RequestOption, params interface{}) (*Response, error) {
 	var body io.Reader
 
@@ -933,7 +1862,12 @@ func (p *HttpClient) send(ctx context.Context, method string, url string, reques
 	case _url.Values:
 		body = strings.NewReader(retParams.Encode())
 	default:
-		return nil, fmt.Errorf("thttp: unsupported request body type %T; supported types: nil, []byte, string, *bytes.Reader, url.Values", retParams)
+		values, err := EncodeForm(retParams)
+		if err != nil {
+			return nil, fmt.Errorf("thttp: unsupported request body type %T; supported types: nil, []byte, string, *bytes.Reader, url.Values, struct with form tags: %w", retParams, err)
+		}
+
+		body = strings.NewReader(values.Encode())
 	}
 
 	return p.Do(ctx, method, url, requestOption, body)
@@ -941,11 +1875,15 @@ func (p *HttpClient) send(ctx context.Context, method string, url string, reques
 
 // sendJson serializes params to JSON when needed and sets Content-Type to [ContentTypeApplicationJson].
 func (p *HttpClient) sendJson(ctx context.Context, method string, url string, requestOption *RequestOption, params interface{}) (*Response, error) {
-	if requestOption == nil {
-		requestOption = NewRequestOption()
-	}
+	return p.sendCodec(ctx, method, url, requestOption, ContentTypeApplicationJson, params)
+}
 
-	requestOption.WithContentType(ContentTypeApplicationJson)
+// sendCodec sets Content-Type to contentType, on a copy of requestOption, and encodes params with the [Codec]
+// registered for it. Raw bodies (nil, []byte, string, *bytes.Reader) are sent as-is without consulting the registry.
+func (p *HttpClient) sendCodec(ctx context.Context, method string, url string, requestOption *RequestOption, contentType string, params interface{}) (*Response, error) {
+	// work on a copy so the caller's option does not keep the Content-Type header
+	requestOption = requestOption.clone()
+	requestOption.WithContentType(contentType)
 
 	var body io.Reader
 
@@ -959,12 +1897,19 @@ func (p *HttpClient) sendJson(ctx context.Context, method string, url string, re
 	case *bytes.Reader:
 		body = retParams
 	default:
-		data, err := json.Marshal(retParams)
+		codec, ok := LookupCodec(contentType)
+		if ok == false {
+			return nil, fmt.Errorf("thttp: no codec registered for content type %q", contentType)
+		}
+
+		data := &bytes.Buffer{}
+
+		err := codec.Encode(data, retParams)
 		if err != nil {
 			return nil, err
 		}
 
-		body = bytes.NewReader(data)
+		body = data
 	}
 
 	return p.Do(ctx, method, url, requestOption, body)
@@ -1020,7 +1965,8 @@ func (p *HttpClient) Post(ctx context.Context, url string, requestOption *Reques
 	return p.send(ctx, "POST", url, requestOption, params)
 }
 
-// PostJson sends an HTTP POST with a JSON-encoded body (structs and maps are marshaled with [encoding/json.Marshal]).
+// PostJson sends an HTTP POST with a JSON-encoded body (structs and maps are marshaled by the codec registered for
+// [ContentTypeApplicationJson], [JsonCodec] by default).
 func (p *HttpClient) PostJson(ctx context.Context, url string, requestOption *RequestOption, params interface{}) (*Response, error) {
 	return p.sendJson(ctx, "POST", url, requestOption, params)
 }
@@ -1061,6 +2007,21 @@ func (p *HttpClient) PostMultipart(ctx context.Context, url string, requestOptio
 	return p.Do(ctx, "POST", url, requestOption, body)
 }
 
+// PostForm sends an application/x-www-form-urlencoded POST. params may be [url.Values], a map, or a struct encoded
+// with [EncodeForm].
+func (p *HttpClient) PostForm(ctx context.Context, url string, requestOption *RequestOption, params interface{}) (*Response, error) {
+	// work on a copy so the caller's option does not keep the Content-Type header
+	requestOption = requestOption.clone()
+	requestOption.WithContentType(ContentTypeApplicationForm)
+
+	values, err := EncodeForm(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.send(ctx, "POST", url, requestOption, values)
+}
+
 // FormData is a single multipart field name and value pair.
 type FormData struct {
 	Key   string
@@ -0,0 +1,101 @@
+package thttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransportTimeoutOptions(t *testing.T) {
+	client := NewHttpClient().
+		WithTlsHandshakeTimeout(2 * time.Second).
+		WithResponseHeaderTimeout(3 * time.Second).
+		WithIdleConnTimeout(4 * time.Second).
+		WithExpectContinueTimeout(5 * time.Second).
+		WithMaxResponseHeaderBytes(6)
+
+	transport := client.Transport().(*http.Transport)
+
+	if transport.TLSHandshakeTimeout != 2*time.Second || transport.ResponseHeaderTimeout != 3*time.Second ||
+		transport.IdleConnTimeout != 4*time.Second || transport.ExpectContinueTimeout != 5*time.Second ||
+		transport.MaxResponseHeaderBytes != 6 {
+		t.Errorf("transport = %v %v %v %v %d", transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout,
+			transport.IdleConnTimeout, transport.ExpectContinueTimeout, transport.MaxResponseHeaderBytes)
+	}
+
+	server := newContentServer(t, http.StatusOK, ContentTypeTextPlain, []byte("ok"))
+
+	for _, key := range []int{OptTransDialTimeout, OptTransKeepAlive, OptTransTlsHandshakeTimeout, OptTransResponseHeaderTimeout,
+		OptTransIdleConnTimeout, OptTransExpectContinueTimeout, OptTransMaxResponseHeaderBytes} {
+		_, err := NewHttpClient().WithOption(key, "1s").Get(context.Background(), server.URL, nil, nil)
+		if err == nil || strings.Contains(err.Error(), "thttp: invalid OptTrans") == false {
+			t.Errorf("option %d with a string = %v, want an error", key, err)
+		}
+	}
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	_, err := NewHttpClient().WithResponseHeaderTimeout(20*time.Millisecond).Get(context.Background(), server.URL, nil, nil)
+	if err == nil || strings.Contains(err.Error(), "timeout awaiting response headers") == false {
+		t.Errorf("Get = %v, want a response header timeout", err)
+	}
+}
+
+func TestMaxResponseHeaderBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Large", strings.Repeat("a", 8<<10))
+	}))
+	defer server.Close()
+
+	_, err := NewHttpClient().WithMaxResponseHeaderBytes(1<<10).Get(context.Background(), server.URL, nil, nil)
+	if err == nil || strings.Contains(err.Error(), "server response headers exceeded") == false {
+		t.Errorf("Get = %v, want a header size error", err)
+	}
+
+	_, err = NewHttpClient().Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Errorf("Get with the default limit = %v", err)
+	}
+}
+
+func TestPostJsonKeepsRequestOption(t *testing.T) {
+	var contentType atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType.Store(r.Header.Get("Content-Type"))
+	}))
+	defer server.Close()
+
+	requestOption := NewRequestOption().WithHeader("X-Request-Id", "1")
+
+	resp, err := PostJson(context.Background(), server.URL, requestOption, map[string]string{"name": "book"})
+	if err != nil {
+		t.Fatalf("PostJson: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if contentType.Load() != ContentTypeApplicationJson {
+		t.Fatalf("PostJson Content-Type = %q", contentType.Load())
+	}
+
+	resp, err = Get(context.Background(), server.URL, requestOption, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if contentType.Load() != "" {
+		t.Errorf("Content-Type = %q on a later request with the same option, want none", contentType.Load())
+	}
+}
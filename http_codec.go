@@ -0,0 +1,180 @@
+package thttp
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Codec encodes request bodies and decodes response bodies for the media types it is registered under with
+// [RegisterCodec]. Implementations must be safe for concurrent use.
+type Codec interface {
+	// Encode writes the encoded form of v to writer.
+	Encode(writer io.Writer, v interface{}) error
+
+	// Decode reads one value from reader into v. option is never nil; codecs may ignore settings that do not apply.
+	Decode(reader io.Reader, v interface{}, option *DecodeOption) error
+}
+
+// JsonCodec is the built-in [encoding/json] codec, registered for application/json, text/json, and "+json" types.
+type JsonCodec struct{}
+
+// Encode implements [Codec]. The output matches [json.Marshal] (no trailing newline).
+func (JsonCodec) Encode(writer io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(data)
+
+	return err
+}
+
+// Decode implements [Codec], honoring [DecodeOption.WithDisallowUnknownFields] and [DecodeOption.WithUseNumber].
+func (JsonCodec) Decode(reader io.Reader, v interface{}, option *DecodeOption) error {
+	decoder := json.NewDecoder(reader)
+
+	if option.disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if option.useNumber {
+		decoder.UseNumber()
+	}
+
+	return decoder.Decode(v)
+}
+
+// XmlCodec is the built-in [encoding/xml] codec, registered for application/xml, text/xml, and "+xml" types.
+type XmlCodec struct{}
+
+// Encode implements [Codec].
+func (XmlCodec) Encode(writer io.Writer, v interface{}) error {
+	return xml.NewEncoder(writer).Encode(v)
+}
+
+// Decode implements [Codec], transcoding documents that declare a non-UTF-8 encoding.
+func (XmlCodec) Decode(reader io.Reader, v interface{}, option *DecodeOption) error {
+	decoder := xml.NewDecoder(reader)
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	return decoder.Decode(v)
+}
+
+// codecRegistry maps lowercase media types (without parameters) to codecs.
+var codecRegistry = struct {
+	codecs map[string]Codec
+
+	sync.RWMutex
+}{
+	codecs: map[string]Codec{
+		ContentTypeApplicationJson: JsonCodec{},
+		"text/json":                JsonCodec{},
+
+		ContentTypeApplicationXml: XmlCodec{},
+		ContentTypeTextXml:        XmlCodec{},
+	},
+}
+
+// parseMediaType returns the lowercase media type of contentType without parameters.
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+
+	return mediaType
+}
+
+// RegisterCodec registers codec for mediaType (for example "application/msgpack", "application/cbor", or
+// "application/x-protobuf"), replacing any previous codec including the built-in ones. A nil codec removes the entry.
+func RegisterCodec(mediaType string, codec Codec) {
+	mediaType = parseMediaType(mediaType)
+
+	codecRegistry.Lock()
+	defer codecRegistry.Unlock()
+
+	if codec == nil {
+		delete(codecRegistry.codecs, mediaType)
+
+		return
+	}
+
+	codecRegistry.codecs[mediaType] = codec
+}
+
+// LookupCodec returns the codec registered for the media type of contentType. Structured syntax suffixes fall back
+// to the base type, so "application/problem+json" resolves to the codec for "application/json".
+func LookupCodec(contentType string) (Codec, bool) {
+	mediaType := parseMediaType(contentType)
+
+	codecRegistry.RLock()
+	defer codecRegistry.RUnlock()
+
+	codec, ok := codecRegistry.codecs[mediaType]
+	if ok {
+		return codec, true
+	}
+
+	index := strings.LastIndex(mediaType, "+")
+	if index >= 0 {
+		codec, ok = codecRegistry.codecs["application/"+mediaType[index+1:]]
+		if ok {
+			return codec, true
+		}
+	}
+
+	return nil, false
+}
+
+// sendAs sets Accept and Content-Type to contentType, encodes params with the codec registered for it, and
+// decodes the response into a new T with the codec matching the response Content-Type (or contentType when the
+// response declares none).
+func sendAs[T any](ctx context.Context, client *HttpClient, method string, url string, requestOption *RequestOption, contentType string, params interface{}) (int, T, error) {
+	var result T
+
+	if client == nil {
+		client = defaultClient
+	}
+
+	// work on a copy so the caller's option does not keep the Accept header
+	requestOption = requestOption.clone()
+	requestOption.WithHeader("accept", contentType)
+
+	resp, err := client.sendCodec(ctx, method, url, requestOption, contentType, params)
+	if err != nil {
+		if resp != nil && resp.Response != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+
+		return 0, result, err
+	}
+
+	statusCode, err := resp.decodeWith(&result, nil, contentType)
+
+	return statusCode, result, err
+}
+
+// PostAs sends a POST whose body is params encoded with the codec registered for contentType, and decodes the
+// response into T. It returns the HTTP status code, the decoded value, and any transport or [*DecodeError].
+// A nil client uses the package default client.
+func PostAs[T any](ctx context.Context, client *HttpClient, url string, requestOption *RequestOption, contentType string, params interface{}) (int, T, error) {
+	return sendAs[T](ctx, client, "POST", url, requestOption, contentType, params)
+}
+
+// PutAs sends a PUT encoded with the codec registered for contentType and decodes the response into T. See [PostAs].
+func PutAs[T any](ctx context.Context, client *HttpClient, url string, requestOption *RequestOption, contentType string, params interface{}) (int, T, error) {
+	return sendAs[T](ctx, client, "PUT", url, requestOption, contentType, params)
+}
+
+// PatchAs sends a PATCH encoded with the codec registered for contentType and decodes the response into T. See [PostAs].
+func PatchAs[T any](ctx context.Context, client *HttpClient, url string, requestOption *RequestOption, contentType string, params interface{}) (int, T, error) {
+	return sendAs[T](ctx, client, "PATCH", url, requestOption, contentType, params)
+}
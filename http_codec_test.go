@@ -0,0 +1,205 @@
+package thttp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// lineCodec encodes a map[string]string as "key=value" lines, standing in for a third-party codec.
+type lineCodec struct{}
+
+// Encode implements [Codec].
+func (lineCodec) Encode(writer io.Writer, v interface{}) error {
+	values, ok := v.(map[string]string)
+	if ok == false {
+		return fmt.Errorf("lineCodec cannot encode %T", v)
+	}
+
+	for key, val := range values {
+		_, err := fmt.Fprintf(writer, "%s=%s\n", key, val)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode implements [Codec].
+func (lineCodec) Decode(reader io.Reader, v interface{}, option *DecodeOption) error {
+	values, ok := v.(*map[string]string)
+	if ok == false {
+		return fmt.Errorf("lineCodec cannot decode into %T", v)
+	}
+
+	*values = make(map[string]string)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), "=")
+		if ok == false {
+			return fmt.Errorf("malformed line %q", scanner.Text())
+		}
+
+		(*values)[key] = val
+	}
+
+	return scanner.Err()
+}
+
+// newCodecEchoServer returns a server echoing the request body, labeled with the request Content-Type unless
+// omitContentType is set, and failing requests whose Accept header differs from their Content-Type.
+func newCodecEchoServer(t *testing.T, omitContentType bool) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != r.Header.Get("Content-Type") {
+			t.Errorf("Accept = %q, Content-Type = %q", r.Header.Get("Accept"), r.Header.Get("Content-Type"))
+		}
+
+		if omitContentType == true {
+			// a nil entry stops net/http from sniffing a Content-Type
+			w.Header()["Content-Type"] = nil
+		} else {
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		}
+
+		_, _ = io.Copy(w, r.Body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestLookupCodec(t *testing.T) {
+	tests := map[string]Codec{
+		"application/json":                   JsonCodec{},
+		"Application/JSON; charset=utf-8":    JsonCodec{},
+		"text/json":                          JsonCodec{},
+		"application/problem+json":           JsonCodec{},
+		"application/vnd.api+json; ext=bulk": JsonCodec{},
+		"text/xml; charset=gbk":              XmlCodec{},
+		"application/atom+xml":               XmlCodec{},
+		"text/plain":                         nil,
+		"application/x-unknown+cbor":         nil,
+	}
+
+	for contentType, want := range tests {
+		codec, ok := LookupCodec(contentType)
+		if codec != want || ok != (want != nil) {
+			t.Errorf("LookupCodec(%q) = %T, %v, want %T", contentType, codec, ok, want)
+		}
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	const mediaType = "application/x-lines"
+
+	RegisterCodec(mediaType+"; charset=utf-8", lineCodec{})
+	defer RegisterCodec(mediaType, nil)
+
+	codec, ok := LookupCodec(mediaType)
+	if ok == false || codec != (lineCodec{}) {
+		t.Fatalf("LookupCodec(%q) = %T, %v, want the registered codec", mediaType, codec, ok)
+	}
+
+	for _, omitContentType := range []bool{false, true} {
+		server := newCodecEchoServer(t, omitContentType)
+
+		// the request media type decodes responses that declare none
+		statusCode, values, err := PostAs[map[string]string](context.Background(), nil, server.URL, nil, mediaType,
+			map[string]string{"name": "book"})
+		if err != nil || statusCode != http.StatusOK || values["name"] != "book" {
+			t.Errorf("PostAs (omit Content-Type %v) = %d, %v, %v", omitContentType, statusCode, values, err)
+		}
+	}
+
+	RegisterCodec(mediaType, nil)
+
+	_, ok = LookupCodec(mediaType)
+	if ok == true {
+		t.Errorf("LookupCodec found a removed codec")
+	}
+}
+
+func TestPostAsJson(t *testing.T) {
+	server := newCodecEchoServer(t, false)
+
+	type item struct {
+		Name string `json:"name"`
+	}
+
+	for _, send := range []func() (int, item, error){
+		func() (int, item, error) {
+			return PostAs[item](context.Background(), NewHttpClient(), server.URL, nil, ContentTypeApplicationJson, item{Name: "post"})
+		},
+		func() (int, item, error) {
+			return PutAs[item](context.Background(), NewHttpClient(), server.URL, nil, ContentTypeApplicationJson, item{Name: "put"})
+		},
+		func() (int, item, error) {
+			return PatchAs[item](context.Background(), NewHttpClient(), server.URL, nil, ContentTypeApplicationJson, `{"name":"raw"}`)
+		},
+	} {
+		statusCode, result, err := send()
+		if err != nil || statusCode != http.StatusOK || result.Name == "" {
+			t.Errorf("send = %d, %+v, %v", statusCode, result, err)
+		}
+	}
+}
+
+func TestPostAsUnregisteredContentType(t *testing.T) {
+	server := newCodecEchoServer(t, false)
+
+	_, _, err := PostAs[map[string]string](context.Background(), nil, server.URL, nil, "application/x-unregistered",
+		map[string]string{"name": "book"})
+	if err == nil || strings.Contains(err.Error(), "no codec registered") == false {
+		t.Errorf("PostAs = %v, want a missing codec error", err)
+	}
+
+	// raw bodies are sent without a codec, but the response still needs one
+	_, _, err = PostAs[map[string]string](context.Background(), nil, server.URL, nil, "application/x-unregistered", []byte("raw"))
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) == false {
+		t.Errorf("PostAs with a raw body = %v, want *DecodeError", err)
+	}
+}
+
+func TestPostAsKeepsRequestOption(t *testing.T) {
+	var accept atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept.Store(r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	requestOption := NewRequestOption().WithHeader("X-Request-Id", "1")
+
+	_, _, err := PostAs[map[string]string](context.Background(), nil, server.URL, requestOption, ContentTypeApplicationJson, []byte("{}"))
+	if err != nil || accept.Load() != ContentTypeApplicationJson {
+		t.Fatalf("PostAs = %v, Accept = %q", err, accept.Load())
+	}
+
+	resp, err := Get(context.Background(), server.URL, requestOption, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if accept.Load() != "" {
+		t.Errorf("Accept = %q on a later request with the same option, want none", accept.Load())
+	}
+}
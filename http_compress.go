@@ -0,0 +1,185 @@
+package thttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// DefaultRequestCompressMinSize is the default body size threshold, in bytes, for request compression.
+	DefaultRequestCompressMinSize = 1024
+)
+
+// RequestCompressOption configures compression of outgoing request bodies applied when [OptRequestCompress] is set.
+type RequestCompressOption struct {
+	encoding string
+	minSize  int64
+	level    int
+}
+
+// NewRequestCompressOption returns defaults: gzip at the codec's default level for bodies of at least
+// [DefaultRequestCompressMinSize] bytes.
+func NewRequestCompressOption() *RequestCompressOption {
+	return &RequestCompressOption{
+		encoding: ContentEncodingGzip,
+		minSize:  DefaultRequestCompressMinSize,
+		level:    -1,
+	}
+}
+
+// WithEncoding selects the Content-Encoding used for request bodies: gzip, zstd, br, or deflate.
+func (p *RequestCompressOption) WithEncoding(encoding string) *RequestCompressOption {
+	p.encoding = encoding
+
+	return p
+}
+
+// WithMinSize sets the smallest body size, in bytes, that is compressed; smaller bodies are sent as-is.
+func (p *RequestCompressOption) WithMinSize(minSize int64) *RequestCompressOption {
+	p.minSize = minSize
+
+	return p
+}
+
+// WithLevel sets the codec-specific compression level; a negative level selects the codec default.
+func (p *RequestCompressOption) WithLevel(level int) *RequestCompressOption {
+	p.level = level
+
+	return p
+}
+
+// newCompressWriter returns a writer compressing into writer with the configured encoding and level.
+func (p *RequestCompressOption) newCompressWriter(writer io.Writer) (io.WriteCloser, error) {
+	switch p.encoding {
+	case ContentEncodingGzip:
+		if p.level < 0 {
+			return gzip.NewWriter(writer), nil
+		}
+
+		return gzip.NewWriterLevel(writer, p.level)
+	case ContentEncodingDeflate:
+		if p.level < 0 {
+			return zlib.NewWriter(writer), nil
+		}
+
+		return zlib.NewWriterLevel(writer, p.level)
+	case ContentEncodingBrotli:
+		if p.level < 0 {
+			return brotli.NewWriter(writer), nil
+		}
+
+		return brotli.NewWriterLevel(writer, p.level), nil
+	case ContentEncodingZstd:
+		if p.level < 0 {
+			return zstd.NewWriter(writer)
+		}
+
+		return zstd.NewWriter(writer, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(p.level)))
+	default:
+		return nil, fmt.Errorf("thttp: unsupported request content encoding %q", p.encoding)
+	}
+}
+
+// requestCompressStats records the outcome of request body compression for logging.
+type requestCompressStats struct {
+	encoding string
+
+	rawSize        int64
+	compressedSize int64
+}
+
+type requestCompressStatsKey struct{}
+
+// requestCompressStatsFromContext returns the compression stats attached by [compressRequestBody], or nil.
+func requestCompressStatsFromContext(ctx context.Context) *requestCompressStats {
+	stats, _ := ctx.Value(requestCompressStatsKey{}).(*requestCompressStats)
+
+	return stats
+}
+
+// prepareRequestCompressOption returns the [*RequestCompressOption] stored under [OptRequestCompress], or nil.
+func prepareRequestCompressOption(options map[int]interface{}) (*RequestCompressOption, error) {
+	srcRequestCompressOption, ok := options[OptRequestCompress]
+	if ok == false || srcRequestCompressOption == nil {
+		return nil, nil
+	}
+
+	destRequestCompressOption, ok := srcRequestCompressOption.(*RequestCompressOption)
+	if ok == false {
+		return nil, fmt.Errorf("thttp: invalid OptRequestCompress value: want *RequestCompressOption, got %T", srcRequestCompressOption)
+	}
+
+	return destRequestCompressOption, nil
+}
+
+// compressRequestBody compresses a replayable request body of at least the configured minimum size, replacing
+// [http.Request.Body], [http.Request.GetBody], and [http.Request.ContentLength] so retries replay the compressed bytes.
+// Bodies of unknown length, bodies that already carry a Content-Encoding, and bodies that do not shrink are sent
+// unchanged. The returned request carries the sizes for the logging transport.
+func compressRequestBody(req *http.Request, option *RequestCompressOption) (*http.Request, error) {
+	if option == nil || req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return req, nil
+	}
+
+	if req.ContentLength <= 0 || req.ContentLength < option.minSize || req.Header.Get("Content-Encoding") != "" {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	compressed := &bytes.Buffer{}
+
+	writer, err := option.newCompressWriter(compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSize, err := io.Copy(writer, body)
+	if err != nil {
+		_ = writer.Close()
+
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(compressed.Len()) >= rawSize {
+		return req, nil
+	}
+
+	_ = req.Body.Close()
+
+	data := compressed.Bytes()
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Encoding", option.encoding)
+
+	stats := &requestCompressStats{
+		encoding: option.encoding,
+
+		rawSize:        rawSize,
+		compressedSize: int64(len(data)),
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), requestCompressStatsKey{}, stats)), nil
+}
@@ -0,0 +1,209 @@
+package thttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// compressedRequest is a request body as received by a [compressServer].
+type compressedRequest struct {
+	contentEncoding string
+	contentLength   int64
+
+	body []byte
+	err  error
+}
+
+// compressServer decodes request bodies by their Content-Encoding and records them.
+type compressServer struct {
+	*httptest.Server
+
+	// failFirst answers the first request with HTTP 503.
+	failFirst bool
+
+	requests []*compressedRequest
+
+	sync.Mutex
+}
+
+// newCompressServer returns a started [compressServer].
+func newCompressServer(t *testing.T, failFirst bool) *compressServer {
+	t.Helper()
+
+	server := &compressServer{failFirst: failFirst}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &compressedRequest{
+			contentEncoding: r.Header.Get("Content-Encoding"),
+			contentLength:   r.ContentLength,
+		}
+
+		var reader io.ReadCloser = r.Body
+		if request.contentEncoding != "" {
+			reader, request.err = newDecodeReader(request.contentEncoding, r.Body)
+		}
+
+		if request.err == nil {
+			request.body, request.err = io.ReadAll(reader)
+		}
+
+		server.Lock()
+		server.requests = append(server.requests, request)
+		count := len(server.requests)
+		server.Unlock()
+
+		if server.failFirst == true && count == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// received returns the recorded requests.
+func (p *compressServer) received() []*compressedRequest {
+	p.Lock()
+	defer p.Unlock()
+
+	return append([]*compressedRequest(nil), p.requests...)
+}
+
+func TestRequestCompress(t *testing.T) {
+	payload := bytes.Repeat([]byte("compress the request body "), 200)
+
+	for _, encoding := range []string{ContentEncodingGzip, ContentEncodingDeflate, ContentEncodingBrotli, ContentEncodingZstd} {
+		t.Run(encoding, func(t *testing.T) {
+			server := newCompressServer(t, false)
+
+			option := NewRequestCompressOption().WithEncoding(encoding)
+			client := NewHttpClient().WithRequestCompressOption(option)
+
+			resp, err := client.Post(context.Background(), server.URL, nil, payload)
+			if err != nil {
+				t.Fatalf("Post: %v", err)
+			}
+
+			_, _, _ = resp.ToBytes()
+
+			requests := server.received()
+			if len(requests) != 1 {
+				t.Fatalf("requests = %d, want 1", len(requests))
+			}
+
+			request := requests[0]
+			if request.err != nil || bytes.Equal(request.body, payload) == false {
+				t.Fatalf("decoded body = %d bytes, %v, want the payload", len(request.body), request.err)
+			}
+
+			if request.contentEncoding != encoding || request.contentLength <= 0 ||
+				request.contentLength >= int64(len(payload)) {
+				t.Errorf("Content-Encoding = %q, Content-Length = %d, want %s and a compressed length",
+					request.contentEncoding, request.contentLength, encoding)
+			}
+		})
+	}
+}
+
+func TestRequestCompressSkipped(t *testing.T) {
+	random := make([]byte, 4096)
+
+	_, err := rand.Read(random)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		body          []byte
+		requestOption *RequestOption
+	}{
+		"below threshold": {
+			body: bytes.Repeat([]byte("a"), DefaultRequestCompressMinSize-1),
+		},
+		"already encoded": {
+			body:          bytes.Repeat([]byte("a"), 4096),
+			requestOption: NewRequestOption().WithHeader("Content-Encoding", "identity"),
+		},
+		"incompressible": {
+			body: random,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newCompressServer(t, false)
+			client := NewHttpClient().WithRequestCompressOption(NewRequestCompressOption())
+
+			resp, err := client.Post(context.Background(), server.URL, test.requestOption, test.body)
+			if err != nil {
+				t.Fatalf("Post: %v", err)
+			}
+
+			_, _, _ = resp.ToBytes()
+
+			requests := server.received()
+			if len(requests) != 1 || requests[0].contentEncoding == ContentEncodingGzip ||
+				requests[0].contentLength != int64(len(test.body)) {
+				t.Errorf("request was compressed: %+v", requests[0])
+			}
+		})
+	}
+}
+
+func TestRequestCompressRetryReplaysCompressedBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("retry me "), 500)
+
+	server := newCompressServer(t, true)
+
+	client := NewHttpClient().
+		WithRequestCompressOption(NewRequestCompressOption().WithMinSize(128)).
+		WithRetryTransOption(NewRetryTransOption().WithMaxCount(2).WithWaitTime(time.Millisecond, time.Millisecond))
+
+	resp, err := client.Post(context.Background(), server.URL, nil, payload)
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+
+	statusCode, _, _ := resp.ToBytes()
+	if statusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204 after a retry", statusCode)
+	}
+
+	requests := server.received()
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(requests))
+	}
+
+	for i, request := range requests {
+		if request.err != nil || request.contentEncoding != ContentEncodingGzip || bytes.Equal(request.body, payload) == false {
+			t.Errorf("attempt %d: Content-Encoding %q, %d bytes, %v, want the compressed payload", i,
+				request.contentEncoding, len(request.body), request.err)
+		}
+
+		if request.contentLength != requests[0].contentLength {
+			t.Errorf("attempt %d Content-Length = %d, want %d", i, request.contentLength, requests[0].contentLength)
+		}
+	}
+}
+
+func TestRequestCompressUnsupportedEncoding(t *testing.T) {
+	server := newCompressServer(t, false)
+	client := NewHttpClient().WithRequestCompressOption(NewRequestCompressOption().WithEncoding("compress").WithMinSize(1))
+
+	_, err := client.Post(context.Background(), server.URL, nil, []byte("payload"))
+	if err == nil {
+		t.Errorf("Post with an unsupported encoding succeeded")
+	}
+}
@@ -6,6 +6,8 @@ const (
 	ContentTypeTextPlain = "text/plain"
 	ContentTypeTextXml   = "text/xml"
 
+	ContentTypeTextEventStream = "text/event-stream"
+
 	ContentTypeImageGif = "image/gif"
 	ContentTypeImageJpg = "image/jpeg"
 	ContentTypeImagePng = "image/png"
@@ -17,4 +19,11 @@ const (
 	ContentTypeApplicationXml        = "application/xml"
 	ContentTypeApplicationJson       = "application/json"
 	ContentTypeApplicationJavascript = "application/javascript"
+
+	ContentTypeApplicationMsgpack  = "application/msgpack"
+	ContentTypeApplicationCbor     = "application/cbor"
+	ContentTypeApplicationProtobuf = "application/x-protobuf"
+
+	ContentTypeApplicationNdjson          = "application/x-ndjson"
+	ContentTypeApplicationGraphqlResponse = "application/graphql-response+json"
 )
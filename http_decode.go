@@ -0,0 +1,183 @@
+package thttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// DefaultDecodePreviewSize is the default number of body bytes included in a [DecodeError] preview.
+	DefaultDecodePreviewSize = 512
+)
+
+// DecodeOption configures [Response.ToJson], [Response.ToXml], and [Response.Decode]. A nil option uses the defaults
+// returned by [NewDecodeOption].
+type DecodeOption struct {
+	disallowUnknownFields bool
+	useNumber             bool
+
+	previewSize int
+}
+
+// NewDecodeOption returns defaults: lenient field matching, float64 numbers, and a [DefaultDecodePreviewSize] preview.
+func NewDecodeOption() *DecodeOption {
+	return &DecodeOption{
+		disallowUnknownFields: false,
+		useNumber:             false,
+
+		previewSize: DefaultDecodePreviewSize,
+	}
+}
+
+// WithDisallowUnknownFields makes JSON decoding fail on object keys that do not match a destination field.
+func (p *DecodeOption) WithDisallowUnknownFields(disallowUnknownFields bool) *DecodeOption {
+	p.disallowUnknownFields = disallowUnknownFields
+
+	return p
+}
+
+// WithUseNumber decodes JSON numbers into interface{} values as [json.Number] instead of float64.
+func (p *DecodeOption) WithUseNumber(useNumber bool) *DecodeOption {
+	p.useNumber = useNumber
+
+	return p
+}
+
+// WithPreviewSize sets how many leading body bytes a [DecodeError] includes; 0 disables the preview.
+func (p *DecodeOption) WithPreviewSize(previewSize int) *DecodeOption {
+	p.previewSize = previewSize
+
+	return p
+}
+
+// DecodeError reports a failure to decode a response body, with enough context to diagnose unexpected payloads
+// such as HTML error pages.
+type DecodeError struct {
+	StatusCode  int
+	ContentType string
+
+	// Preview holds the leading bytes of the body, truncated to the configured preview size. Streaming decoders
+	// such as [StreamNdjson] leave it empty.
+	Preview string
+
+	Err error
+}
+
+// Error implements [error].
+func (p *DecodeError) Error() string {
+	if p.Preview == "" {
+		return fmt.Sprintf("thttp: failed to decode response body (status %d, content type %q): %v",
+			p.StatusCode, p.ContentType, p.Err)
+	}
+
+	return fmt.Sprintf("thttp: failed to decode response body (status %d, content type %q): %v; body preview: %q",
+		p.StatusCode, p.ContentType, p.Err, p.Preview)
+}
+
+// Unwrap returns the underlying decode or read error.
+func (p *DecodeError) Unwrap() error {
+	return p.Err
+}
+
+// previewWriter keeps the first limit bytes written to it.
+type previewWriter struct {
+	data  []byte
+	limit int
+}
+
+// Write implements [io.Writer]; it never fails so it can sit behind an [io.TeeReader].
+func (p *previewWriter) Write(data []byte) (int, error) {
+	remaining := p.limit - len(p.data)
+	if remaining > 0 {
+		if len(data) > remaining {
+			p.data = append(p.data, data[:remaining]...)
+		} else {
+			p.data = append(p.data, data...)
+		}
+	}
+
+	return len(data), nil
+}
+
+// decodeBody streams the (decompressed) body through codec into v, wrapping failures in a [*DecodeError].
+// The body is closed on return.
+func (p *Response) decodeBody(v interface{}, option *DecodeOption, codec Codec) (int, error) {
+	if p == nil || p.Response == nil {
+		return 0, errors.New("thttp: response object is unavailable")
+	}
+
+	if option == nil {
+		option = NewDecodeOption()
+	}
+
+	statusCode := p.StatusCode
+	contentType := p.Header.Get("Content-Type")
+
+	reader, err := p.openBody()
+	if err != nil {
+		if p.Body != nil {
+			_ = p.Body.Close()
+		}
+
+		return statusCode, &DecodeError{StatusCode: statusCode, ContentType: contentType, Err: err}
+	}
+
+	defer reader.Close()
+
+	preview := &previewWriter{limit: option.previewSize}
+
+	err = codec.Decode(io.TeeReader(reader, preview), v, option)
+	if err != nil {
+		return statusCode, &DecodeError{StatusCode: statusCode, ContentType: contentType, Preview: string(preview.data), Err: err}
+	}
+
+	return statusCode, nil
+}
+
+// ToJson streams the response body into v with [encoding/json], decompressing it as [Response.ToBytes] does.
+// It returns the HTTP status code and a [*DecodeError] on failure. A nil option uses [NewDecodeOption].
+func (p *Response) ToJson(v interface{}, option *DecodeOption) (int, error) {
+	return p.decodeBody(v, option, JsonCodec{})
+}
+
+// ToXml streams the response body into v with [encoding/xml], decompressing it as [Response.ToBytes] does.
+// It returns the HTTP status code and a [*DecodeError] on failure. A nil option uses [NewDecodeOption].
+func (p *Response) ToXml(v interface{}, option *DecodeOption) (int, error) {
+	return p.decodeBody(v, option, XmlCodec{})
+}
+
+// Decode picks the [Codec] registered for the response Content-Type (see [RegisterCodec] and [LookupCodec]; JSON and
+// XML are built in) and streams the body into v. Content types without a codec return a [*DecodeError] without
+// reading the body.
+func (p *Response) Decode(v interface{}, option *DecodeOption) (int, error) {
+	return p.decodeWith(v, option, "")
+}
+
+// decodeWith behaves like [Response.Decode] but falls back to fallbackContentType when the response declares no
+// Content-Type.
+func (p *Response) decodeWith(v interface{}, option *DecodeOption, fallbackContentType string) (int, error) {
+	if p == nil || p.Response == nil {
+		return 0, errors.New("thttp: response object is unavailable")
+	}
+
+	contentType := p.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = fallbackContentType
+	}
+
+	codec, ok := LookupCodec(contentType)
+	if ok {
+		return p.decodeBody(v, option, codec)
+	}
+
+	if p.Body != nil {
+		_ = p.Body.Close()
+	}
+
+	return p.StatusCode, &DecodeError{
+		StatusCode:  p.StatusCode,
+		ContentType: contentType,
+		Err:         fmt.Errorf("no codec registered for content type %q", parseMediaType(contentType)),
+	}
+}
@@ -0,0 +1,176 @@
+package thttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// newContentServer returns a server answering with status, contentType, and body.
+func newContentServer(t *testing.T, status int, contentType string, body []byte) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// getContent sends a GET to server and returns the response.
+func getContent(t *testing.T, server *httptest.Server) *Response {
+	t.Helper()
+
+	resp, err := NewHttpClient().Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	return resp
+}
+
+type decodeTestItem struct {
+	Name  string      `json:"name" xml:"name"`
+	Count int         `json:"count" xml:"count"`
+	Extra interface{} `json:"extra,omitempty" xml:"-"`
+}
+
+func TestResponseToJson(t *testing.T) {
+	server := newContentServer(t, http.StatusOK, ContentTypeApplicationJson, []byte(`{"name":"book","count":3,"extra":12345678901234567890}`))
+
+	var item decodeTestItem
+
+	statusCode, err := getContent(t, server).ToJson(&item, nil)
+	if err != nil || statusCode != http.StatusOK || item.Name != "book" || item.Count != 3 {
+		t.Fatalf("ToJson = %d, %+v, %v", statusCode, item, err)
+	}
+
+	if _, ok := item.Extra.(float64); ok == false {
+		t.Errorf("extra = %T, want float64 by default", item.Extra)
+	}
+
+	statusCode, err = getContent(t, server).ToJson(&item, NewDecodeOption().WithUseNumber(true))
+	if err != nil || item.Extra != json.Number("12345678901234567890") {
+		t.Errorf("ToJson with UseNumber = %d, %#v, %v", statusCode, item.Extra, err)
+	}
+}
+
+func TestResponseToJsonDecodesContentEncoding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+		w.Header().Set("Content-Encoding", ContentEncodingGzip)
+		_, _ = w.Write(encodeTestBody(t, ContentEncodingGzip, []byte(`{"name":"zipped"}`)))
+	}))
+	defer server.Close()
+
+	requestOption := NewRequestOption().WithHeader("Accept-Encoding", ContentEncodingGzip)
+
+	resp, err := NewHttpClient().Get(context.Background(), server.URL, requestOption, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	var item decodeTestItem
+
+	_, err = resp.ToJson(&item, nil)
+	if err != nil || item.Name != "zipped" {
+		t.Errorf("ToJson = %+v, %v, want the decompressed document", item, err)
+	}
+}
+
+func TestResponseToJsonDisallowUnknownFields(t *testing.T) {
+	server := newContentServer(t, http.StatusOK, ContentTypeApplicationJson, []byte(`{"name":"book","color":"red"}`))
+
+	var item decodeTestItem
+
+	_, err := getContent(t, server).ToJson(&item, NewDecodeOption().WithDisallowUnknownFields(true))
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) == false || strings.Contains(decodeErr.Err.Error(), "color") == false {
+		t.Errorf("ToJson = %v, want a *DecodeError naming the unknown field", err)
+	}
+}
+
+func TestResponseDecodeErrorPreview(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("Bad Gateway ", 100) + "</body></html>"
+
+	server := newContentServer(t, http.StatusBadGateway, "text/html", []byte(page))
+
+	var item decodeTestItem
+
+	statusCode, err := getContent(t, server).ToJson(&item, NewDecodeOption().WithPreviewSize(16))
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) == false {
+		t.Fatalf("ToJson = %v, want *DecodeError", err)
+	}
+
+	if statusCode != http.StatusBadGateway || decodeErr.StatusCode != http.StatusBadGateway ||
+		decodeErr.ContentType != "text/html" || decodeErr.Preview != page[:16] {
+		t.Errorf("DecodeError = %+v", decodeErr)
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) == false {
+		t.Errorf("DecodeError does not unwrap to the JSON error: %v", decodeErr.Err)
+	}
+}
+
+func TestResponseToXmlCharset(t *testing.T) {
+	body := encodeTestText(t, simplifiedchinese.GBK, `<?xml version="1.0" encoding="GBK"?><item><name>书</name><count>2</count></item>`)
+
+	server := newContentServer(t, http.StatusOK, ContentTypeApplicationXml, body)
+
+	var item decodeTestItem
+
+	_, err := getContent(t, server).ToXml(&item, nil)
+	if err != nil || item.Name != "书" || item.Count != 2 {
+		t.Errorf("ToXml = %+v, %v, want the transcoded document", item, err)
+	}
+}
+
+func TestResponseDecode(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		"json":         {contentType: "application/json; charset=utf-8", body: `{"name":"a","count":1}`},
+		"problem json": {contentType: "application/problem+json", body: `{"name":"a","count":1}`},
+		"xml":          {contentType: "text/xml", body: `<item><name>a</name><count>1</count></item>`},
+		"atom xml":     {contentType: "application/atom+xml", body: `<item><name>a</name><count>1</count></item>`},
+		"html":         {contentType: "text/html", body: `<p>a</p>`, wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newContentServer(t, http.StatusOK, test.contentType, []byte(test.body))
+
+			var item decodeTestItem
+
+			_, err := getContent(t, server).Decode(&item, nil)
+			if test.wantErr == true {
+				var decodeErr *DecodeError
+				if errors.As(err, &decodeErr) == false {
+					t.Errorf("Decode = %v, want *DecodeError", err)
+				}
+
+				return
+			}
+
+			if err != nil || item.Name != "a" || item.Count != 1 {
+				t.Errorf("Decode = %+v, %v", item, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package thttp
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content-Encoding tokens understood by the decompression layer and [Response.ToBytes].
+const (
+	ContentEncodingGzip    = "gzip"
+	ContentEncodingDeflate = "deflate"
+	ContentEncodingBrotli  = "br"
+	ContentEncodingZstd    = "zstd"
+)
+
+// DecompressTransOption configures the decompressing [http.RoundTripper] wrapper applied when [OptTransDecompress] is set.
+// Each codec can be switched off independently; disabled codecs are neither advertised nor decoded.
+type DecompressTransOption struct {
+	enableGzip    bool
+	enableDeflate bool
+	enableBrotli  bool
+	enableZstd    bool
+}
+
+// NewDecompressTransOption returns defaults: gzip, br, zstd, and deflate are all advertised and decoded.
+func NewDecompressTransOption() *DecompressTransOption {
+	return &DecompressTransOption{
+		enableGzip:    true,
+		enableDeflate: true,
+		enableBrotli:  true,
+		enableZstd:    true,
+	}
+}
+
+// WithGzip enables or disables the gzip codec.
+func (p *DecompressTransOption) WithGzip(enableGzip bool) *DecompressTransOption {
+	p.enableGzip = enableGzip
+
+	return p
+}
+
+// WithDeflate enables or disables the deflate codec (zlib-wrapped or raw DEFLATE streams).
+func (p *DecompressTransOption) WithDeflate(enableDeflate bool) *DecompressTransOption {
+	p.enableDeflate = enableDeflate
+
+	return p
+}
+
+// WithBrotli enables or disables the br (Brotli) codec.
+func (p *DecompressTransOption) WithBrotli(enableBrotli bool) *DecompressTransOption {
+	p.enableBrotli = enableBrotli
+
+	return p
+}
+
+// WithZstd enables or disables the zstd codec.
+func (p *DecompressTransOption) WithZstd(enableZstd bool) *DecompressTransOption {
+	p.enableZstd = enableZstd
+
+	return p
+}
+
+// supports reports whether encoding is enabled in this option.
+func (p *DecompressTransOption) supports(encoding string) bool {
+	switch encoding {
+	case ContentEncodingGzip, "x-gzip":
+		return p.enableGzip
+	case ContentEncodingDeflate:
+		return p.enableDeflate
+	case ContentEncodingBrotli:
+		return p.enableBrotli
+	case ContentEncodingZstd:
+		return p.enableZstd
+	default:
+		return false
+	}
+}
+
+// supportsAll reports whether every coding in encodings is enabled in this option.
+func (p *DecompressTransOption) supportsAll(encodings []string) bool {
+	for _, encoding := range encodings {
+		if p.supports(encoding) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// acceptEncoding returns the Accept-Encoding value advertising the enabled codecs in preference order.
+func (p *DecompressTransOption) acceptEncoding() string {
+	encodings := make([]string, 0, 4)
+
+	for _, encoding := range []string{ContentEncodingGzip, ContentEncodingBrotli, ContentEncodingZstd, ContentEncodingDeflate} {
+		if p.supports(encoding) {
+			encodings = append(encodings, encoding)
+		}
+	}
+
+	return strings.Join(encodings, ", ")
+}
+
+// parseContentEncoding splits a Content-Encoding header into lowercase codings in the order they were applied,
+// dropping "identity".
+func parseContentEncoding(header http.Header) []string {
+	encodings := make([]string, 0)
+
+	for _, val := range header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(val, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding == "" || encoding == "identity" {
+				continue
+			}
+
+			encodings = append(encodings, encoding)
+		}
+	}
+
+	return encodings
+}
+
+// zstdReadCloser adapts a [zstd.Decoder] so Close releases the decoder and the underlying stream.
+type zstdReadCloser struct {
+	*zstd.Decoder
+
+	closer io.Closer
+}
+
+// Close implements [io.Closer].
+func (p *zstdReadCloser) Close() error {
+	p.Decoder.Close()
+
+	return p.closer.Close()
+}
+
+// newDeflateReader decodes zlib-wrapped DEFLATE (RFC 1950, as HTTP specifies) and falls back to raw DEFLATE
+// (RFC 1951) for servers that omit the zlib header.
+func newDeflateReader(reader io.Reader) (io.ReadCloser, error) {
+	bufReader := bufio.NewReader(reader)
+
+	header, err := bufReader.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(bufReader)
+	}
+
+	return flate.NewReader(bufReader), nil
+}
+
+// newDecodeReader returns a reader decoding one Content-Encoding coding from reader. Closing the returned reader
+// releases decoder resources and closes reader when it is an [io.Closer].
+func newDecodeReader(encoding string, reader io.Reader) (io.ReadCloser, error) {
+	closer, ok := reader.(io.Closer)
+	if ok == false {
+		closer = io.NopCloser(nil)
+	}
+
+	var decoder io.ReadCloser
+
+	switch encoding {
+	case ContentEncodingGzip, "x-gzip":
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder = gzipReader
+	case ContentEncodingDeflate:
+		deflateReader, err := newDeflateReader(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder = deflateReader
+	case ContentEncodingBrotli:
+		decoder = io.NopCloser(brotli.NewReader(reader))
+	case ContentEncodingZstd:
+		// RFC 9659 caps the zstd window at 8 MiB for HTTP, which also bounds decoder memory for hostile frames
+		zstdDecoder, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(8<<20))
+		if err != nil {
+			return nil, err
+		}
+
+		return &zstdReadCloser{Decoder: zstdDecoder, closer: closer}, nil
+	default:
+		return nil, fmt.Errorf("thttp: unsupported content encoding %q", encoding)
+	}
+
+	return &chainReadCloser{reader: decoder, closers: []io.Closer{decoder, closer}}, nil
+}
+
+// chainReadCloser reads from reader and closes every closer in order on Close.
+type chainReadCloser struct {
+	reader io.Reader
+
+	closers []io.Closer
+}
+
+// Read implements [io.Reader].
+func (p *chainReadCloser) Read(data []byte) (int, error) {
+	return p.reader.Read(data)
+}
+
+// Close implements [io.Closer]. The first error encountered is returned.
+func (p *chainReadCloser) Close() error {
+	var closeErr error
+
+	for _, closer := range p.closers {
+		err := closer.Close()
+		if err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+
+	return closeErr
+}
+
+// newDecodeChain decodes encodings from body in reverse order of application (the last listed coding is removed
+// first). On failure the decoders already created are closed, which also closes body.
+func newDecodeChain(body io.ReadCloser, encodings []string) (io.ReadCloser, error) {
+	reader := body
+
+	for i := len(encodings) - 1; i >= 0; i-- {
+		decoder, err := newDecodeReader(encodings[i], reader)
+		if err != nil {
+			_ = reader.Close()
+
+			return nil, err
+		}
+
+		reader = decoder
+	}
+
+	return reader, nil
+}
+
+// decodedBody lazily builds the decoder chain for stacked encodings on first Read, so empty bodies and decoder
+// header errors surface from Read rather than from [http.RoundTripper.RoundTrip].
+type decodedBody struct {
+	body io.ReadCloser
+
+	encodings []string
+
+	reader  io.ReadCloser
+	initErr error
+}
+
+// init decodes encodings in reverse order of application (the last listed coding is removed first).
+func (p *decodedBody) init() {
+	reader, err := newDecodeChain(p.body, p.encodings)
+	if err != nil {
+		p.initErr = err
+
+		return
+	}
+
+	p.reader = reader
+}
+
+// Read implements [io.Reader].
+func (p *decodedBody) Read(data []byte) (int, error) {
+	if p.reader == nil && p.initErr == nil {
+		p.init()
+	}
+
+	if p.initErr != nil {
+		return 0, p.initErr
+	}
+
+	return p.reader.Read(data)
+}
+
+// Close implements [io.Closer].
+func (p *decodedBody) Close() error {
+	if p.reader != nil {
+		return p.reader.Close()
+	}
+
+	return p.body.Close()
+}
+
+// decompressTransport advertises Accept-Encoding and transparently decodes compressed responses from a delegate
+// [http.RoundTripper], so streaming consumers of [http.Response.Body] receive decoded bytes.
+type decompressTransport struct {
+	transport http.RoundTripper
+
+	decompressTransOption *DecompressTransOption
+
+	// maxDecodedResponseSize bounds the decoded body ([OptMaxDecodedResponseSize]); 0 means unlimited.
+	maxDecodedResponseSize int64
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (p *decompressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	option := p.decompressTransOption
+
+	// respect caller-supplied Accept-Encoding, and do not ask for compressed ranges
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		acceptEncoding := option.acceptEncoding()
+		if acceptEncoding != "" {
+			outReq := new(http.Request)
+			*outReq = *req
+
+			outReq.Header = req.Header.Clone()
+			outReq.Header.Set("Accept-Encoding", acceptEncoding)
+
+			req = outReq
+		}
+	}
+
+	resp, err := p.transport.RoundTrip(req)
+	if err != nil || resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, err
+	}
+
+	encodings := parseContentEncoding(resp.Header)
+	if len(encodings) == 0 {
+		return resp, err
+	}
+
+	// leave the body untouched when any coding in the stack cannot be decoded
+	if option.supportsAll(encodings) == false {
+		return resp, err
+	}
+
+	resp.Body = &decodedBody{
+		body:      resp.Body,
+		encodings: encodings,
+	}
+
+	if p.maxDecodedResponseSize > 0 {
+		resp.Body = newLimitedReadCloser(resp.Body, p.maxDecodedResponseSize, true)
+	}
+
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return resp, err
+}
+
+// wrapDecompressTransport returns a decompressing decorator around transport, or [http.DefaultTransport] when transport is nil.
+// maxDecodedResponseSize limits decoded bodies when positive.
+func wrapDecompressTransport(transport http.RoundTripper, decompressTransOption *DecompressTransOption, maxDecodedResponseSize int64) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if decompressTransOption == nil {
+		decompressTransOption = NewDecompressTransOption()
+	}
+
+	decompressTransport := &decompressTransport{
+		transport:             transport,
+		decompressTransOption: decompressTransOption,
+
+		maxDecodedResponseSize: maxDecodedResponseSize,
+	}
+
+	return decompressTransport
+}
@@ -0,0 +1,244 @@
+package thttp
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encodeTestBody applies the Content-Encoding codings of contentEncoding to data in the listed order.
+func encodeTestBody(t *testing.T, contentEncoding string, data []byte) []byte {
+	t.Helper()
+
+	for _, encoding := range strings.Split(contentEncoding, ",") {
+		buf := &bytes.Buffer{}
+
+		var writer io.WriteCloser
+
+		switch strings.TrimSpace(encoding) {
+		case ContentEncodingGzip, "x-gzip":
+			writer = gzip.NewWriter(buf)
+		case ContentEncodingDeflate:
+			writer = zlib.NewWriter(buf)
+		case "raw-deflate":
+			writer, _ = flate.NewWriter(buf, flate.DefaultCompression)
+		case ContentEncodingBrotli:
+			writer = brotli.NewWriter(buf)
+		case ContentEncodingZstd:
+			zstdWriter, err := zstd.NewWriter(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writer = zstdWriter
+		default:
+			t.Fatalf("unknown test encoding %q", encoding)
+		}
+
+		_, err := writer.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data = buf.Bytes()
+	}
+
+	return data
+}
+
+// newEncodingServer returns a server answering with payload encoded as contentEncoding and storing the
+// Accept-Encoding request header into acceptEncoding.
+func newEncodingServer(t *testing.T, contentEncoding string, payload []byte, acceptEncoding *atomic.Value) *httptest.Server {
+	t.Helper()
+
+	body := encodeTestBody(t, contentEncoding, payload)
+
+	// raw DEFLATE streams are labeled deflate, as misbehaving servers do
+	contentEncoding = strings.ReplaceAll(contentEncoding, "raw-deflate", ContentEncodingDeflate)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if acceptEncoding != nil {
+			acceptEncoding.Store(r.Header.Get("Accept-Encoding"))
+		}
+
+		w.Header().Set("Content-Encoding", contentEncoding)
+		_, _ = w.Write(body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDecompressTransport(t *testing.T) {
+	payload := bytes.Repeat([]byte("decompress me "), 1000)
+
+	for _, contentEncoding := range []string{"gzip", "x-gzip", "deflate", "raw-deflate", "br", "zstd", "gzip, br", "zstd, deflate, gzip"} {
+		t.Run(contentEncoding, func(t *testing.T) {
+			var acceptEncoding atomic.Value
+
+			server := newEncodingServer(t, contentEncoding, payload, &acceptEncoding)
+			client := NewHttpClient().WithDecompressTransOption(NewDecompressTransOption())
+
+			resp, err := client.Get(context.Background(), server.URL, nil, nil)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			defer resp.Body.Close()
+
+			// the body is decoded for streaming consumers, not only for the read helpers
+			body, err := io.ReadAll(resp.Body)
+			if err != nil || bytes.Equal(body, payload) == false {
+				t.Fatalf("body = %d bytes, %v, want the decoded payload", len(body), err)
+			}
+
+			if acceptEncoding.Load() != "gzip, br, zstd, deflate" {
+				t.Errorf("Accept-Encoding = %q", acceptEncoding.Load())
+			}
+
+			if resp.Header.Get("Content-Encoding") != "" || resp.ContentLength != -1 || resp.Uncompressed == false {
+				t.Errorf("decoded response keeps Content-Encoding %q, length %d, uncompressed %v",
+					resp.Header.Get("Content-Encoding"), resp.ContentLength, resp.Uncompressed)
+			}
+		})
+	}
+}
+
+func TestDecompressTransportDisabledCodec(t *testing.T) {
+	payload := []byte("brotli payload")
+
+	var acceptEncoding atomic.Value
+
+	server := newEncodingServer(t, "br", payload, &acceptEncoding)
+	client := NewHttpClient().WithDecompressTransOption(NewDecompressTransOption().WithBrotli(false).WithZstd(false))
+
+	resp, err := client.Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if acceptEncoding.Load() != "gzip, deflate" {
+		t.Errorf("Accept-Encoding = %q, want the enabled codecs only", acceptEncoding.Load())
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Encoding") != "br" || bytes.Equal(body, payload) == true {
+		t.Errorf("a disabled coding was decoded: Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestDecompressTransportKeepsCallerAcceptEncoding(t *testing.T) {
+	var acceptEncoding atomic.Value
+
+	server := newEncodingServer(t, "zstd", []byte("zstd payload"), &acceptEncoding)
+	client := NewHttpClient().WithDecompressTransOption(NewDecompressTransOption())
+
+	resp, err := client.Get(context.Background(), server.URL, NewRequestOption().WithHeader("Accept-Encoding", "zstd"), nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, body, err := resp.ToBytes()
+	if err != nil || string(body) != "zstd payload" || acceptEncoding.Load() != "zstd" {
+		t.Errorf("body = %q, %v, Accept-Encoding = %q", body, err, acceptEncoding.Load())
+	}
+}
+
+func TestToBytesDecodesContentEncoding(t *testing.T) {
+	payload := []byte("read helper payload")
+
+	for _, contentEncoding := range []string{"br", "zstd", "deflate", "br, gzip"} {
+		t.Run(contentEncoding, func(t *testing.T) {
+			server := newEncodingServer(t, contentEncoding, payload, nil)
+
+			// asking for the coding explicitly stops net/http from decoding gzip itself
+			requestOption := NewRequestOption().WithHeader("Accept-Encoding", contentEncoding)
+
+			resp, err := NewHttpClient().Get(context.Background(), server.URL, requestOption, nil)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+
+			_, body, err := resp.ToBytes()
+			if err != nil || bytes.Equal(body, payload) == false {
+				t.Errorf("ToBytes = %q, %v, want %q", body, err, payload)
+			}
+		})
+	}
+}
+
+func TestToBytesUnknownEncoding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "compress")
+		_, _ = w.Write([]byte("opaque"))
+	}))
+	defer server.Close()
+
+	resp, err := NewHttpClient().Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, body, err := resp.ToBytes()
+	if err != nil || string(body) != "opaque" {
+		t.Errorf("ToBytes = %q, %v, want the body undecoded", body, err)
+	}
+}
+
+func TestToBytesHonorsDisabledCodec(t *testing.T) {
+	payload := []byte("brotli payload")
+
+	server := newEncodingServer(t, "br", payload, nil)
+	client := NewHttpClient().WithDecompressTransOption(NewDecompressTransOption().WithBrotli(false))
+
+	resp, err := client.Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, body, err := resp.ToBytes()
+	if err != nil || bytes.Equal(body, encodeTestBody(t, "br", payload)) == false {
+		t.Errorf("ToBytes = %q, %v, want the body left encoded", body, err)
+	}
+}
+
+// closeTrackingReader records whether it was closed.
+type closeTrackingReader struct {
+	io.Reader
+
+	closed bool
+}
+
+// Close implements [io.Closer].
+func (p *closeTrackingReader) Close() error {
+	p.closed = true
+
+	return nil
+}
+
+func TestNewDecodeChainClosesBodyOnError(t *testing.T) {
+	body := &closeTrackingReader{Reader: strings.NewReader("not compressed")}
+
+	// the br decoder is created before the gzip header check fails
+	_, err := newDecodeChain(body, []string{ContentEncodingGzip, ContentEncodingBrotli})
+	if err == nil || body.closed == false {
+		t.Errorf("newDecodeChain = %v, closed = %v, want an error and the body closed", err, body.closed)
+	}
+}
@@ -3,6 +3,7 @@ package thttp
 import (
 	"context"
 	"io"
+	"iter"
 	"net/http"
 	_url "net/url"
 	"time"
@@ -31,6 +32,77 @@ func WithTimeout(timeout time.Duration) *HttpClient {
 	return defaultClient.WithTimeout(timeout)
 }
 
+// WithDialTimeout sets the dial timeout of the default client. See [HttpClient.WithDialTimeout].
+func WithDialTimeout(timeout time.Duration) *HttpClient {
+	return defaultClient.WithDialTimeout(timeout)
+}
+
+// WithKeepAlive sets the TCP keep-alive interval of the default client. See [HttpClient.WithKeepAlive].
+func WithKeepAlive(keepAlive time.Duration) *HttpClient {
+	return defaultClient.WithKeepAlive(keepAlive)
+}
+
+// WithResolver sets the host name resolver of the default client. See [HttpClient.WithResolver].
+func WithResolver(resolver *Resolver) *HttpClient {
+	return defaultClient.WithResolver(resolver)
+}
+
+// WithUnixSocket sends every request of the default client over a Unix socket. See [HttpClient.WithUnixSocket].
+func WithUnixSocket(path string) *HttpClient {
+	return defaultClient.WithUnixSocket(path)
+}
+
+// WithUnixSocketFor sends requests of the default client for host over a Unix socket. See
+// [HttpClient.WithUnixSocketFor].
+func WithUnixSocketFor(host string, path string) *HttpClient {
+	return defaultClient.WithUnixSocketFor(host, path)
+}
+
+// WithLocalAddr binds connections of the default client to a local address or interface. See
+// [HttpClient.WithLocalAddr].
+func WithLocalAddr(addr string) *HttpClient {
+	return defaultClient.WithLocalAddr(addr)
+}
+
+// WithIpPreference selects the IP families dialed by the default client. See [HttpClient.WithIpPreference].
+func WithIpPreference(preference IpPreference) *HttpClient {
+	return defaultClient.WithIpPreference(preference)
+}
+
+// WithDialGuard restricts the destinations the default client may reach. See [HttpClient.WithDialGuard].
+func WithDialGuard(guard *DialGuard) *HttpClient {
+	return defaultClient.WithDialGuard(guard)
+}
+
+// WithTlsHandshakeTimeout sets the TLS handshake timeout of the default client. See
+// [HttpClient.WithTlsHandshakeTimeout].
+func WithTlsHandshakeTimeout(timeout time.Duration) *HttpClient {
+	return defaultClient.WithTlsHandshakeTimeout(timeout)
+}
+
+// WithResponseHeaderTimeout sets the response header timeout of the default client. See
+// [HttpClient.WithResponseHeaderTimeout].
+func WithResponseHeaderTimeout(timeout time.Duration) *HttpClient {
+	return defaultClient.WithResponseHeaderTimeout(timeout)
+}
+
+// WithIdleConnTimeout sets the idle connection timeout of the default client. See [HttpClient.WithIdleConnTimeout].
+func WithIdleConnTimeout(timeout time.Duration) *HttpClient {
+	return defaultClient.WithIdleConnTimeout(timeout)
+}
+
+// WithExpectContinueTimeout sets the 100-continue timeout of the default client. See
+// [HttpClient.WithExpectContinueTimeout].
+func WithExpectContinueTimeout(timeout time.Duration) *HttpClient {
+	return defaultClient.WithExpectContinueTimeout(timeout)
+}
+
+// WithMaxResponseHeaderBytes limits response header size on the default client. See
+// [HttpClient.WithMaxResponseHeaderBytes].
+func WithMaxResponseHeaderBytes(maxBytes int64) *HttpClient {
+	return defaultClient.WithMaxResponseHeaderBytes(maxBytes)
+}
+
 // WithProxyUrl sets a static HTTP proxy on the default client. See [HttpClient.WithProxyUrl].
 func WithProxyUrl(addr string) *HttpClient {
 	return defaultClient.WithProxyUrl(addr)
@@ -41,11 +113,53 @@ func WithProxyFunc(option ProxyFunc) *HttpClient {
 	return defaultClient.WithProxyFunc(option)
 }
 
+// WithProxyFromEnvironment sets the proxy of the default client from the environment. See
+// [HttpClient.WithProxyFromEnvironment].
+func WithProxyFromEnvironment() *HttpClient {
+	return defaultClient.WithProxyFromEnvironment()
+}
+
+// WithNoProxy sets the hosts that bypass the proxy of the default client. See [HttpClient.WithNoProxy].
+func WithNoProxy(hosts ...string) *HttpClient {
+	return defaultClient.WithNoProxy(hosts...)
+}
+
+// WithProxyBasicAuth sets the proxy credentials of the default client. See [HttpClient.WithProxyBasicAuth].
+func WithProxyBasicAuth(username string, password string) *HttpClient {
+	return defaultClient.WithProxyBasicAuth(username, password)
+}
+
+// WithProxyConnectHeader adds a proxy CONNECT header to the default client. See [HttpClient.WithProxyConnectHeader].
+func WithProxyConnectHeader(key string, value string) *HttpClient {
+	return defaultClient.WithProxyConnectHeader(key, value)
+}
+
 // WithUnsafeTls controls TLS verification on the default client. See [HttpClient.WithUnsafeTls].
 func WithUnsafeTls(unsafe bool) *HttpClient {
 	return defaultClient.WithUnsafeTls(unsafe)
 }
 
+// WithClientCertFiles sets a reloading client certificate on the default client. See [HttpClient.WithClientCertFiles].
+func WithClientCertFiles(certPath string, keyPath string, caPath string) *HttpClient {
+	return defaultClient.WithClientCertFiles(certPath, keyPath, caPath)
+}
+
+// WithClientCertReloader sets the client certificate reloader of the default client. See
+// [HttpClient.WithClientCertReloader].
+func WithClientCertReloader(reloader *ClientCertReloader) *HttpClient {
+	return defaultClient.WithClientCertReloader(reloader)
+}
+
+// WithRootCAs sets the trusted CA certificates of the default client. See [HttpClient.WithRootCAs].
+func WithRootCAs(option *RootCAsOption) *HttpClient {
+	return defaultClient.WithRootCAs(option)
+}
+
+// WithPinnedKeys pins server keys for host on the default client. See [HttpClient.WithPinnedKeys].
+func WithPinnedKeys(host string, pins ...string) *HttpClient {
+	return defaultClient.WithPinnedKeys(host, pins...)
+}
+
 // WithRetryTransOption enables request retries on the default client. See [HttpClient.WithRetryTransOption].
 func WithRetryTransOption(option *RetryTransOption) *HttpClient {
 	return defaultClient.WithRetryTransOption(option)
@@ -56,6 +170,67 @@ func WithLogTransOption(option *LogTransOption) *HttpClient {
 	return defaultClient.WithLogTransOption(option)
 }
 
+// WithDecompressTransOption enables response decompression on the default client. See [HttpClient.WithDecompressTransOption].
+func WithDecompressTransOption(option *DecompressTransOption) *HttpClient {
+	return defaultClient.WithDecompressTransOption(option)
+}
+
+// WithAuthenticator sets the authenticator of the default client. See [HttpClient.WithAuthenticator].
+func WithAuthenticator(authenticator Authenticator) *HttpClient {
+	return defaultClient.WithAuthenticator(authenticator)
+}
+
+// WithBasicAuth sets HTTP Basic credentials on the default client. See [HttpClient.WithBasicAuth].
+func WithBasicAuth(username string, password string) *HttpClient {
+	return defaultClient.WithBasicAuth(username, password)
+}
+
+// WithBearerToken sets a static bearer token on the default client. See [HttpClient.WithBearerToken].
+func WithBearerToken(token string) *HttpClient {
+	return defaultClient.WithBearerToken(token)
+}
+
+// WithDigestAuth sets HTTP Digest credentials on the default client. See [HttpClient.WithDigestAuth].
+func WithDigestAuth(username string, password string) *HttpClient {
+	return defaultClient.WithDigestAuth(username, password)
+}
+
+// WithTokenSource sets an OAuth2 token source on the default client. See [HttpClient.WithTokenSource].
+func WithTokenSource(source TokenSource) *HttpClient {
+	return defaultClient.WithTokenSource(source)
+}
+
+// WithRequestSigner sets the request signer of the default client. See [HttpClient.WithRequestSigner].
+func WithRequestSigner(signer RequestSigner) *HttpClient {
+	return defaultClient.WithRequestSigner(signer)
+}
+
+// WithQuery sets default query parameters on the default client. See [HttpClient.WithQuery].
+func WithQuery(params interface{}) *HttpClient {
+	return defaultClient.WithQuery(params)
+}
+
+// WithRequestCompressOption enables request body compression on the default client. See [HttpClient.WithRequestCompressOption].
+func WithRequestCompressOption(option *RequestCompressOption) *HttpClient {
+	return defaultClient.WithRequestCompressOption(option)
+}
+
+// WithReadIdleTimeout sets the response body read idle timeout of the default client. See
+// [HttpClient.WithReadIdleTimeout].
+func WithReadIdleTimeout(timeout time.Duration) *HttpClient {
+	return defaultClient.WithReadIdleTimeout(timeout)
+}
+
+// WithMaxResponseSize limits response body size on the default client. See [HttpClient.WithMaxResponseSize].
+func WithMaxResponseSize(maxSize int64) *HttpClient {
+	return defaultClient.WithMaxResponseSize(maxSize)
+}
+
+// WithMaxDecodedResponseSize limits decompressed response body size on the default client. See [HttpClient.WithMaxDecodedResponseSize].
+func WithMaxDecodedResponseSize(maxSize int64) *HttpClient {
+	return defaultClient.WithMaxDecodedResponseSize(maxSize)
+}
+
 // WithCookieJar sets the cookie jar on the default client. See [HttpClient.WithCookieJar].
 func WithCookieJar(jar http.CookieJar) *HttpClient {
 	return defaultClient.WithCookieJar(jar)
@@ -76,6 +251,26 @@ func WithResponseHookFunc(option ResponseHookFunc) *HttpClient {
 	return defaultClient.WithResponseHookFunc(option)
 }
 
+// WithUploadProgressFunc registers a request body progress callback on the default client. See [HttpClient.WithUploadProgressFunc].
+func WithUploadProgressFunc(option ProgressFunc) *HttpClient {
+	return defaultClient.WithUploadProgressFunc(option)
+}
+
+// WithDownloadProgressFunc registers a response body progress callback on the default client. See [HttpClient.WithDownloadProgressFunc].
+func WithDownloadProgressFunc(option ProgressFunc) *HttpClient {
+	return defaultClient.WithDownloadProgressFunc(option)
+}
+
+// WithBandwidthLimit caps body throughput on the default client. See [HttpClient.WithBandwidthLimit].
+func WithBandwidthLimit(bytesPerSec int64) *HttpClient {
+	return defaultClient.WithBandwidthLimit(bytesPerSec)
+}
+
+// WithBandwidthLimiter installs a shared bandwidth limiter on the default client. See [HttpClient.WithBandwidthLimiter].
+func WithBandwidthLimiter(limiter *BandwidthLimiter) *HttpClient {
+	return defaultClient.WithBandwidthLimiter(limiter)
+}
+
 // WithOptions applies multiple options to the default client. See [HttpClient.WithOptions].
 func WithOptions(options map[int]interface{}) *HttpClient {
 	return defaultClient.WithOptions(options)
@@ -136,6 +331,16 @@ func PostJson(ctx context.Context, url string, requestOption *RequestOption, par
 	return defaultClient.PostJson(ctx, url, requestOption, params)
 }
 
+// Sse subscribes to a Server-Sent Events stream using the default client. See [HttpClient.Sse].
+func Sse(ctx context.Context, url string, requestOption *RequestOption) iter.Seq2[*SseEvent, error] {
+	return defaultClient.Sse(ctx, url, requestOption)
+}
+
+// PostForm sends a form-encoded POST using the default client. See [HttpClient.PostForm].
+func PostForm(ctx context.Context, url string, requestOption *RequestOption, params interface{}) (*Response, error) {
+	return defaultClient.PostForm(ctx, url, requestOption, params)
+}
+
 // PostMultipart sends multipart/form-data using the default client. See [HttpClient.PostMultipart].
 func PostMultipart(ctx context.Context, url string, requestOption *RequestOption, params _url.Values) (*Response, error) {
 	return defaultClient.PostMultipart(ctx, url, requestOption, params)
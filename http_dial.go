@@ -0,0 +1,312 @@
+package thttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDialTimeout is the default limit for establishing a TCP connection.
+	DefaultDialTimeout = 30 * time.Second
+
+	// DefaultKeepAlive is the default TCP keep-alive probe interval.
+	DefaultKeepAlive = 30 * time.Second
+)
+
+// IpPreference selects the IP families used to connect to servers, see [HttpClient.WithIpPreference].
+type IpPreference int
+
+const (
+	// IpPreferenceAuto dials addresses in resolver order and races the other family after a short delay
+	// (Happy Eyeballs). It is the default.
+	IpPreferenceAuto IpPreference = iota
+	// IpPreferenceIpv4 dials IPv4 addresses first and falls back to IPv6 when no IPv4 connection can be made.
+	IpPreferenceIpv4
+	// IpPreferenceIpv6 dials IPv6 addresses first and falls back to IPv4 when no IPv6 connection can be made.
+	IpPreferenceIpv6
+	// IpPreferenceIpv4Only dials IPv4 addresses only.
+	IpPreferenceIpv4Only
+	// IpPreferenceIpv6Only dials IPv6 addresses only.
+	IpPreferenceIpv6Only
+)
+
+// dialSettings holds the connection options of an [HttpClient]; [dialSettings.dialContext] composes them into
+// [http.Transport.DialContext] so each option can change without discarding the others.
+type dialSettings struct {
+	timeout   time.Duration
+	keepAlive time.Duration
+
+	resolver *Resolver
+
+	// unixSockets maps "host:port", "host" or "*" to a socket path; it is replaced, never modified, on update.
+	unixSockets map[string]string
+
+	localIp        net.IP
+	localInterface string
+
+	ipPreference IpPreference
+
+	guard *DialGuard
+}
+
+// newDialSettings returns the default dial settings.
+func newDialSettings() *dialSettings {
+	return &dialSettings{
+		timeout:   DefaultDialTimeout,
+		keepAlive: DefaultKeepAlive,
+	}
+}
+
+// setUnixSockets merges sockets into the socket mapping; an empty path removes its host.
+func (p *dialSettings) setUnixSockets(sockets map[string]string) {
+	merged := make(map[string]string, len(p.unixSockets)+len(sockets))
+	for host, path := range p.unixSockets {
+		merged[host] = path
+	}
+
+	for host, path := range sockets {
+		host = strings.ToLower(host)
+
+		if path == "" {
+			delete(merged, host)
+		} else {
+			merged[host] = path
+		}
+	}
+
+	p.unixSockets = merged
+}
+
+// setLocalAddr binds outgoing connections to addr, an IP address or a network interface name; empty unbinds them.
+func (p *dialSettings) setLocalAddr(addr string) error {
+	p.localIp = nil
+	p.localInterface = ""
+
+	if addr == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(addr)
+	if ip != nil {
+		p.localIp = ip
+
+		return nil
+	}
+
+	_, err := net.InterfaceByName(addr)
+	if err != nil {
+		return fmt.Errorf("thttp: invalid OptTransLocalAddr value: %q is neither an IP address nor a network interface", addr)
+	}
+
+	p.localInterface = addr
+
+	return nil
+}
+
+// networks returns the networks to dial in order, or nil to let [net.Dialer] choose.
+func (p *dialSettings) networks() []string {
+	switch p.ipPreference {
+	case IpPreferenceIpv4:
+		return []string{"tcp4", "tcp6"}
+	case IpPreferenceIpv6:
+		return []string{"tcp6", "tcp4"}
+	case IpPreferenceIpv4Only:
+		return []string{"tcp4"}
+	case IpPreferenceIpv6Only:
+		return []string{"tcp6"}
+	}
+
+	// an interface has one local address per family, so the family must be known before dialing
+	if p.localInterface != "" {
+		return []string{"tcp4", "tcp6"}
+	}
+
+	return nil
+}
+
+// dialContext returns a [http.Transport.DialContext] function for the settings. Unix socket mappings are checked
+// first, then each preferred network is tried in turn, resolving through the [Resolver] if one is set. The
+// [DialGuard] checks every TCP connection; Unix sockets are trusted configuration and bypass it.
+func (p *dialSettings) dialContext() func(context.Context, string, string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   p.timeout,
+		KeepAlive: p.keepAlive,
+	}
+
+	if p.localIp != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: p.localIp}
+	}
+
+	if p.guard != nil {
+		dialer.Control = p.guard.control
+	}
+
+	dialContext := defaultTransportDialContext(dialer)
+
+	if p.localInterface != "" {
+		dialContext = interfaceDialContext(dialer, p.localInterface)
+	}
+
+	if p.resolver != nil {
+		dialContext = p.resolver.dialContext(dialContext)
+	}
+
+	networks := p.networks()
+	if networks != nil {
+		dialContext = networksDialContext(networks, dialContext)
+	}
+
+	if len(p.unixSockets) > 0 {
+		unixDialer := &net.Dialer{
+			Timeout: p.timeout,
+		}
+
+		dialContext = unixSocketDialContext(p.unixSockets, defaultTransportDialContext(unixDialer), dialContext)
+	}
+
+	return dialContext
+}
+
+// unixSocketPath returns the socket path mapped to address: an exact "host:port" entry, else a "host" entry, else
+// the "*" entry.
+func unixSocketPath(sockets map[string]string, address string) (string, bool) {
+	address = strings.ToLower(address)
+
+	path, ok := sockets[address]
+	if ok == true {
+		return path, true
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err == nil {
+		path, ok = sockets[host]
+		if ok == true {
+			return path, true
+		}
+	}
+
+	path, ok = sockets["*"]
+
+	return path, ok
+}
+
+// unixSocketDialContext dials the Unix socket mapped to each address with unixDial, and other addresses with dial.
+func unixSocketDialContext(sockets map[string]string, unixDial func(context.Context, string, string) (net.Conn, error),
+	dial func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		path, ok := unixSocketPath(sockets, address)
+		if ok == true {
+			return unixDial(ctx, "unix", path)
+		}
+
+		return dial(ctx, network, address)
+	}
+}
+
+// networksDialContext dials each of networks in turn until a connection is made. The error of the first network
+// with a usable address is returned, so a missing fallback family does not hide the real failure.
+func networksDialContext(networks []string, dial func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if network != "tcp" {
+			return dial(ctx, network, address)
+		}
+
+		var firstErr error
+
+		for _, val := range networks {
+			conn, err := dial(ctx, val, address)
+			if err == nil {
+				return conn, nil
+			}
+
+			if firstErr == nil || (isNoSuitableAddress(firstErr) == true && isNoSuitableAddress(err) == false) {
+				firstErr = err
+			}
+
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		return nil, firstErr
+	}
+}
+
+// isNoSuitableAddress reports whether err means the host has no address in the dialed family.
+func isNoSuitableAddress(err error) bool {
+	var addrErr *net.AddrError
+	if errors.As(err, &addrErr) == true {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) == true {
+		return dnsErr.Err == "no suitable address"
+	}
+
+	return false
+}
+
+// interfaceDialContext dials from the address of the network interface name in the family of each dial. Interface
+// addresses are read on every dial so address changes are picked up.
+func interfaceDialContext(dialer *net.Dialer, name string) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		localAddr, err := interfaceAddr(name, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		ifaceDialer := *dialer
+		ifaceDialer.LocalAddr = localAddr
+
+		return defaultTransportDialContext(&ifaceDialer)(ctx, network, address)
+	}
+}
+
+// interfaceAddr returns the first address of the network interface name usable to dial address over network.
+// IPv6 link-local addresses are skipped because they only reach the local link.
+func interfaceAddr(name string, network string, address string) (*net.TCPAddr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	wantIpv4 := network == "tcp4"
+	if network == "tcp" {
+		host, _, err := net.SplitHostPort(address)
+		if err == nil {
+			ip := net.ParseIP(host)
+			wantIpv4 = ip == nil || ip.To4() != nil
+		}
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if ok == false {
+			continue
+		}
+
+		isIpv4 := ipNet.IP.To4() != nil
+		if isIpv4 != wantIpv4 || (isIpv4 == false && ipNet.IP.IsLinkLocalUnicast() == true) {
+			continue
+		}
+
+		return &net.TCPAddr{IP: ipNet.IP}, nil
+	}
+
+	family := "IPv6"
+	if wantIpv4 == true {
+		family = "IPv4"
+	}
+
+	return nil, &net.AddrError{Err: fmt.Sprintf("network interface %s has no %s address", name, family), Addr: address}
+}
@@ -0,0 +1,318 @@
+package thttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDialSettings(t *testing.T) {
+	client := NewHttpClient().WithDialTimeout(time.Second).WithKeepAlive(-1)
+
+	// each dial option keeps the others
+	if client.dialSettings.timeout != time.Second || client.dialSettings.keepAlive != -1 {
+		t.Errorf("dial settings = %+v, want both options", client.dialSettings)
+	}
+
+	client = (&HttpClient{}).WithKeepAlive(time.Minute)
+	if client.dialSettings.timeout != DefaultDialTimeout || client.dialSettings.keepAlive != time.Minute {
+		t.Errorf("dial settings of a zero client = %+v, want the default timeout", client.dialSettings)
+	}
+
+	server := newContentServer(t, http.StatusOK, ContentTypeTextPlain, []byte("ok"))
+
+	resp, err := NewHttpClient().WithDialTimeout(time.Second).Get(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, text, err := resp.ToString()
+	if err != nil || text != "ok" {
+		t.Errorf("ToString = %q, %v", text, err)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	dialContext := (&dialSettings{timeout: time.Nanosecond}).dialContext()
+
+	_, err := dialContext(context.Background(), "tcp", "127.0.0.1:1")
+
+	var netErr net.Error
+	if errors.As(err, &netErr) == false || netErr.Timeout() == false {
+		t.Errorf("dial = %v, want a timeout", err)
+	}
+}
+
+// newRemoteAddrServer returns a server answering with the request's Host and the client address it saw.
+func newRemoteAddrServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host + " " + r.RemoteAddr))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newUnixSocketServer returns a server listening on a Unix socket, and the socket path.
+func newUnixSocketServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+
+	// socket paths are limited to about 100 bytes, which t.TempDir may exceed
+	dir, err := os.MkdirTemp("", "thttp")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	path := filepath.Join(dir, "http.sock")
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Unix sockets are unavailable: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return server, path
+}
+
+func TestUnixSocket(t *testing.T) {
+	_, path := newUnixSocketServer(t)
+
+	text, err := getText(NewHttpClient().WithUnixSocket(path), "http://docker/v1.43/info")
+	if err != nil || text != "docker" {
+		t.Errorf("Get over WithUnixSocket = %q, %v, want the URL host", text, err)
+	}
+
+	client := NewHttpClient().
+		WithUnixSocketFor("API.test:8080", path).
+		WithUnixSocketFor("other.test", path).
+		WithResolver(NewResolver().WithOverride("api.test", "127.0.0.1"))
+
+	for _, target := range []string{"http://api.test:8080/", "http://other.test:81/"} {
+		_, err = getText(client, target)
+		if err != nil {
+			t.Errorf("Get %s = %v, want the socket", target, err)
+		}
+	}
+
+	server := newRemoteAddrServer(t)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// other ports are dialed over TCP
+	text, err = getText(client, "http://api.test:"+port)
+	if err != nil || strings.HasPrefix(text, "api.test:"+port+" 127.0.0.1:") == false {
+		t.Errorf("Get over TCP = %q, %v", text, err)
+	}
+
+	// an empty path removes the host
+	client.WithUnixSocketFor("other.test", "")
+
+	_, err = getText(client, "http://other.test:81/")
+	if err == nil {
+		t.Errorf("Get of a removed host succeeded")
+	}
+}
+
+func TestUnixSocketPath(t *testing.T) {
+	settings := &dialSettings{}
+	settings.setUnixSockets(map[string]string{"api.test:443": "/exact", "API.test": "/host"})
+	settings.setUnixSockets(map[string]string{"*": "/any", "gone.test": "/gone"})
+	settings.setUnixSockets(map[string]string{"gone.test": ""})
+
+	tests := map[string]string{
+		"api.test:443": "/exact",
+		"api.test:80":  "/host",
+		"API.TEST:80":  "/host",
+		"gone.test:80": "/any",
+		"other:80":     "/any",
+	}
+
+	for address, want := range tests {
+		path, ok := unixSocketPath(settings.unixSockets, address)
+		if ok == false || path != want {
+			t.Errorf("unixSocketPath(%s) = %q, %t, want %q", address, path, ok, want)
+		}
+	}
+
+	_, ok := unixSocketPath(map[string]string{"api.test": "/host"}, "other:80")
+	if ok == true {
+		t.Errorf("unixSocketPath matched an unmapped host")
+	}
+
+	client := NewHttpClient().WithUnixSocket("/any").WithOption(OptTransUnixSockets, nil)
+	if len(client.dialSettings.unixSockets) != 0 {
+		t.Errorf("unix sockets after nil = %v, want none", client.dialSettings.unixSockets)
+	}
+}
+
+// loopbackInterface returns the name of a loopback network interface with an IPv4 address.
+func loopbackInterface(t *testing.T) string {
+	t.Helper()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("Interfaces: %v", err)
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+
+		_, err := interfaceAddr(iface.Name, "tcp4", "127.0.0.1:80")
+		if err == nil {
+			return iface.Name
+		}
+	}
+
+	t.Skipf("no loopback interface with an IPv4 address")
+
+	return ""
+}
+
+func TestLocalAddr(t *testing.T) {
+	server := newRemoteAddrServer(t)
+
+	tests := map[string]struct {
+		addr    string
+		wantErr string
+	}{
+		"ip":           {addr: "127.0.0.1"},
+		"interface":    {addr: loopbackInterface(t)},
+		"unbound":      {addr: ""},
+		"other family": {addr: "::1", wantErr: "no suitable address"},
+		"unknown":      {addr: "no-such-if0", wantErr: "thttp: invalid OptTransLocalAddr value"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			text, err := getText(NewHttpClient().WithLocalAddr(test.addr), server.URL)
+			if test.wantErr != "" {
+				if err == nil || strings.Contains(err.Error(), test.wantErr) == false {
+					t.Errorf("Get = %q, %v, want %q", text, err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil || strings.Contains(text, " 127.0.0.1:") == false {
+				t.Errorf("Get = %q, %v, want a connection from 127.0.0.1", text, err)
+			}
+		})
+	}
+}
+
+func TestIpPreference(t *testing.T) {
+	server := newRemoteAddrServer(t)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	tests := map[IpPreference]bool{
+		IpPreferenceAuto:     true,
+		IpPreferenceIpv4:     true,
+		IpPreferenceIpv6:     true,
+		IpPreferenceIpv4Only: true,
+		IpPreferenceIpv6Only: false,
+	}
+
+	for preference, wantOk := range tests {
+		// the server only listens on IPv4
+		client := NewHttpClient().WithIpPreference(preference).WithResolver(NewResolver().WithOverride("dual.test", "::1", "127.0.0.1"))
+
+		_, err := getText(client, "http://dual.test:"+port)
+		if (err == nil) != wantOk {
+			t.Errorf("Get with preference %d = %v, want success %t", preference, err, wantOk)
+		}
+	}
+
+	_, err := getText(NewHttpClient().WithIpPreference(IpPreference(9)), server.URL)
+	if err == nil || strings.Contains(err.Error(), "unknown preference 9") == false {
+		t.Errorf("Get with an unknown preference = %v, want an error", err)
+	}
+}
+
+// dialTestConn is a fake connection to addr that records whether it was closed.
+type dialTestConn struct {
+	net.Conn
+
+	addr   string
+	closed atomic.Bool
+}
+
+func (p *dialTestConn) Close() error {
+	p.closed.Store(true)
+
+	return nil
+}
+
+func TestNetworksDialContext(t *testing.T) {
+	noAddr := &net.DNSError{Err: "no suitable address", Name: "host", IsNotFound: true}
+	refused := errors.New("connection refused")
+
+	tests := map[string]struct {
+		errs     map[string]error
+		want     string
+		wantErr  error
+		wantDial string
+	}{
+		"first network":  {want: "tcp6", wantDial: "tcp6"},
+		"fallback":       {errs: map[string]error{"tcp6": refused}, want: "tcp4", wantDial: "tcp6 tcp4"},
+		"first error":    {errs: map[string]error{"tcp6": refused, "tcp4": errors.New("unreachable")}, wantErr: refused, wantDial: "tcp6 tcp4"},
+		"usable address": {errs: map[string]error{"tcp6": noAddr, "tcp4": refused}, wantErr: refused, wantDial: "tcp6 tcp4"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dials := make([]string, 0)
+
+			dial := func(ctx context.Context, network string, address string) (net.Conn, error) {
+				dials = append(dials, network)
+
+				err := test.errs[network]
+				if err != nil {
+					return nil, err
+				}
+
+				return &dialTestConn{addr: network}, nil
+			}
+
+			conn, err := networksDialContext([]string{"tcp6", "tcp4"}, dial)(context.Background(), "tcp", "host:80")
+
+			if strings.Join(dials, " ") != test.wantDial {
+				t.Errorf("dials = %v, want %s", dials, test.wantDial)
+			}
+
+			if test.wantErr != nil {
+				if err != test.wantErr {
+					t.Errorf("dial = %v, want %v", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil || conn.(*dialTestConn).addr != test.want {
+				t.Errorf("dial = %v, %v, want %s", conn, err, test.want)
+			}
+		})
+	}
+}
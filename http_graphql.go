@@ -0,0 +1,428 @@
+package thttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	_url "net/url"
+	"strings"
+)
+
+const (
+	// graphqlPersistedQueryNotFound is the error message servers return for an unknown persisted query hash.
+	graphqlPersistedQueryNotFound = "PersistedQueryNotFound"
+)
+
+// GraphqlRequest is a GraphQL operation.
+type GraphqlRequest struct {
+	Query         string      `json:"query,omitempty"`
+	OperationName string      `json:"operationName,omitempty"`
+	Variables     interface{} `json:"variables,omitempty"`
+
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphqlLocation is a line and column in the query document.
+type GraphqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphqlError is one entry of a GraphQL response's errors array.
+type GraphqlError struct {
+	Message   string            `json:"message"`
+	Locations []GraphqlLocation `json:"locations,omitempty"`
+
+	// Path lists the field names (string) and list indices (float64) leading to the failed field.
+	Path []interface{} `json:"path,omitempty"`
+
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Error implements [error].
+func (p *GraphqlError) Error() string {
+	if len(p.Path) == 0 {
+		return "thttp: GraphQL error: " + p.Message
+	}
+
+	path := make([]string, 0, len(p.Path))
+	for _, segment := range p.Path {
+		path = append(path, fmt.Sprint(segment))
+	}
+
+	return fmt.Sprintf("thttp: GraphQL error at %s: %s", strings.Join(path, "."), p.Message)
+}
+
+// Code returns extensions.code (for example "UNAUTHENTICATED"), or an empty string.
+func (p *GraphqlError) Code() string {
+	code, _ := p.Extensions["code"].(string)
+
+	return code
+}
+
+// GraphqlErrors is the errors array of a GraphQL response. It is returned alongside any partial data.
+type GraphqlErrors []*GraphqlError
+
+// Error implements [error].
+func (p GraphqlErrors) Error() string {
+	if len(p) == 1 {
+		return p[0].Error()
+	}
+
+	messages := make([]string, 0, len(p))
+	for _, graphqlErr := range p {
+		messages = append(messages, graphqlErr.Message)
+	}
+
+	return fmt.Sprintf("thttp: %d GraphQL errors: %s", len(p), strings.Join(messages, "; "))
+}
+
+// Unwrap exposes the individual errors to [errors.Is] and [errors.As].
+func (p GraphqlErrors) Unwrap() []error {
+	errs := make([]error, 0, len(p))
+	for _, graphqlErr := range p {
+		errs = append(errs, graphqlErr)
+	}
+
+	return errs
+}
+
+// graphqlResponse is the wire form of a GraphQL response.
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphqlErrors   `json:"errors"`
+}
+
+// isPersistedQueryNotFound reports whether the server does not know the persisted query hash.
+func (p *graphqlResponse) isPersistedQueryNotFound() bool {
+	for _, graphqlErr := range p.Errors {
+		if graphqlErr.Message == graphqlPersistedQueryNotFound || graphqlErr.Code() == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GraphqlClient sends GraphQL operations through an [HttpClient], so the client's headers, retry, logging, and other
+// options apply. It is safe for concurrent use once configured.
+type GraphqlClient struct {
+	client *HttpClient
+	url    string
+
+	requestOption *RequestOption
+
+	persistedQueries bool
+}
+
+// NewGraphqlClient returns a client for the GraphQL endpoint at url. A nil client uses the package default client.
+func NewGraphqlClient(client *HttpClient, url string) *GraphqlClient {
+	if client == nil {
+		client = defaultClient
+	}
+
+	return &GraphqlClient{
+		client: client,
+		url:    url,
+	}
+}
+
+// WithRequestOption sets per-request options applied to every operation; the option is copied per operation and
+// never modified.
+func (p *GraphqlClient) WithRequestOption(requestOption *RequestOption) *GraphqlClient {
+	p.requestOption = requestOption
+
+	return p
+}
+
+// WithPersistedQueries enables Automatic Persisted Queries: queries are first sent as a GET carrying only the
+// sha256 hash of the document, so responses can be cached by HTTP caches and CDNs, and are registered with a POST of
+// the full document when the server answers PersistedQueryNotFound. Only an operation that is unambiguously a query
+// (the one named by OperationName, or the only one in the document) uses GET; mutations, subscriptions, and
+// documents whose operation cannot be determined are always sent with POST.
+func (p *GraphqlClient) WithPersistedQueries(persistedQueries bool) *GraphqlClient {
+	p.persistedQueries = persistedQueries
+
+	return p
+}
+
+// graphqlTokens splits a GraphQL document into names and punctuators, dropping whitespace, commas, and comments.
+// Strings and numbers become the placeholder tokens `"` and "0", as only the document structure matters.
+func graphqlTokens(document string) []string {
+	tokens := make([]string, 0)
+
+	isNameStart := func(c byte) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+
+	isNameChar := func(c byte) bool {
+		return isNameStart(c) || (c >= '0' && c <= '9')
+	}
+
+	for i := 0; i < len(document); {
+		c := document[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == 0xef && strings.HasPrefix(document[i:], "\ufeff"):
+			i += len("\ufeff")
+		case c == '#':
+			for i < len(document) && document[i] != '\n' && document[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(document[i:], `"""`):
+			i += 3
+
+			for i < len(document) && strings.HasPrefix(document[i:], `"""`) == false {
+				if strings.HasPrefix(document[i:], `\"""`) {
+					i += 4
+				} else {
+					i++
+				}
+			}
+
+			i += 3
+			tokens = append(tokens, `"`)
+		case c == '"':
+			i++
+
+			for i < len(document) && document[i] != '"' && document[i] != '\n' {
+				if document[i] == '\\' {
+					i++
+				}
+
+				i++
+			}
+
+			i++
+			tokens = append(tokens, `"`)
+		case isNameStart(c):
+			start := i
+			for i < len(document) && isNameChar(document[i]) {
+				i++
+			}
+
+			tokens = append(tokens, document[start:i])
+		case c == '-' || (c >= '0' && c <= '9'):
+			i++
+			for i < len(document) && (isNameChar(document[i]) || document[i] == '.' || document[i] == '+' || document[i] == '-') {
+				i++
+			}
+
+			tokens = append(tokens, "0")
+		default:
+			i++
+			tokens = append(tokens, string(c))
+		}
+	}
+
+	return tokens
+}
+
+// graphqlOperationType returns the type ("query", "mutation", or "subscription") of the operation in document
+// selected by operationName, or of its only operation when operationName is empty. Fragment definitions are skipped.
+// It returns "" when the operation cannot be determined: an unknown name, several operations without a name, or a
+// document that is not a well-formed executable document.
+func graphqlOperationType(document string, operationName string) string {
+	tokens := graphqlTokens(document)
+
+	types := make([]string, 0)
+	names := make([]string, 0)
+
+	for i := 0; i < len(tokens); {
+		var opType, opName string
+
+		switch tokens[i] {
+		case "{":
+			opType = "query"
+		case "query", "mutation", "subscription":
+			opType = tokens[i]
+
+			if i+1 < len(tokens) && tokens[i+1] != "{" && tokens[i+1] != "(" && tokens[i+1] != "@" {
+				opName = tokens[i+1]
+			}
+		case "fragment":
+		default:
+			return ""
+		}
+
+		// skip to the brace closing the selection set, ignoring braces of object values in arguments
+		braces, parens := 0, 0
+		closed := false
+
+		for ; i < len(tokens) && closed == false; i++ {
+			switch tokens[i] {
+			case "(":
+				parens++
+			case ")":
+				parens--
+			case "{":
+				braces++
+			case "}":
+				braces--
+
+				closed = braces == 0 && parens == 0
+			}
+		}
+
+		if closed == false {
+			return ""
+		}
+
+		if opType != "" {
+			types = append(types, opType)
+			names = append(names, opName)
+		}
+	}
+
+	if operationName == "" {
+		if len(types) == 1 {
+			return types[0]
+		}
+
+		return ""
+	}
+
+	for idx, name := range names {
+		if name == operationName {
+			return types[idx]
+		}
+	}
+
+	return ""
+}
+
+// send executes one HTTP exchange for request, with GET when get is set, and decodes the GraphQL response.
+func (p *GraphqlClient) send(ctx context.Context, request *GraphqlRequest, get bool) (*graphqlResponse, error) {
+	requestOption := p.requestOption.clone()
+	requestOption.WithHeader("Accept", ContentTypeApplicationGraphqlResponse+", "+ContentTypeApplicationJson)
+
+	var resp *Response
+	var err error
+
+	if get == true {
+		params := _url.Values{}
+
+		if request.Query != "" {
+			params.Set("query", request.Query)
+		}
+
+		if request.OperationName != "" {
+			params.Set("operationName", request.OperationName)
+		}
+
+		if request.Variables != nil {
+			variables, err := json.Marshal(request.Variables)
+			if err != nil {
+				return nil, err
+			}
+
+			params.Set("variables", string(variables))
+		}
+
+		if len(request.Extensions) > 0 {
+			extensions, err := json.Marshal(request.Extensions)
+			if err != nil {
+				return nil, err
+			}
+
+			params.Set("extensions", string(extensions))
+		}
+
+		resp, err = p.client.Get(ctx, p.url, requestOption, params)
+	} else {
+		resp, err = p.client.PostJson(ctx, p.url, requestOption, request)
+	}
+
+	if err != nil {
+		if resp != nil && resp.Response != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	graphqlResp := &graphqlResponse{}
+
+	statusCode, err := resp.ToJson(graphqlResp, nil)
+	if err != nil {
+		if statusCode >= 400 {
+			return nil, fmt.Errorf("thttp: GraphQL request failed with HTTP status %d: %w", statusCode, err)
+		}
+
+		return nil, err
+	}
+
+	if len(graphqlResp.Errors) == 0 && statusCode >= 400 {
+		return nil, fmt.Errorf("thttp: GraphQL request failed with HTTP status %d", statusCode)
+	}
+
+	return graphqlResp, nil
+}
+
+// Do sends request and decodes the response data into data (which may be nil). When the response carries errors,
+// any partial data is still decoded and [GraphqlErrors] is returned.
+func (p *GraphqlClient) Do(ctx context.Context, request *GraphqlRequest, data interface{}) error {
+	var graphqlResp *graphqlResponse
+	var err error
+
+	if p.persistedQueries == true && request.Query != "" && graphqlOperationType(request.Query, request.OperationName) == "query" {
+		hash := sha256.Sum256([]byte(request.Query))
+
+		persisted := &GraphqlRequest{
+			OperationName: request.OperationName,
+			Variables:     request.Variables,
+
+			Extensions: map[string]interface{}{},
+		}
+
+		for key, val := range request.Extensions {
+			persisted.Extensions[key] = val
+		}
+
+		persisted.Extensions["persistedQuery"] = map[string]interface{}{
+			"version":    1,
+			"sha256Hash": hex.EncodeToString(hash[:]),
+		}
+
+		graphqlResp, err = p.send(ctx, persisted, true)
+		if err == nil && graphqlResp.isPersistedQueryNotFound() {
+			// register the document under its hash
+			persisted.Query = request.Query
+
+			graphqlResp, err = p.send(ctx, persisted, false)
+		}
+	} else {
+		graphqlResp, err = p.send(ctx, request, false)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if data != nil && len(graphqlResp.Data) > 0 && string(graphqlResp.Data) != "null" {
+		err = json.Unmarshal(graphqlResp.Data, data)
+		if err != nil {
+			return fmt.Errorf("thttp: cannot decode GraphQL data: %w", err)
+		}
+	}
+
+	if len(graphqlResp.Errors) > 0 {
+		return graphqlResp.Errors
+	}
+
+	return nil
+}
+
+// GraphqlQuery sends query with variables (a map or struct; nil omits them) and decodes the response data into a
+// new T. See [GraphqlClient.Do] for partial data and errors.
+func GraphqlQuery[T any](ctx context.Context, client *GraphqlClient, query string, variables interface{}) (T, error) {
+	var data T
+
+	err := client.Do(ctx, &GraphqlRequest{Query: query, Variables: variables}, &data)
+
+	return data, err
+}
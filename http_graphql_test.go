@@ -0,0 +1,268 @@
+package thttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// graphqlServer is a GraphQL endpoint supporting Automatic Persisted Queries. It records the method of every
+// request, answers a document containing "partial" with partial data and errors, and echoes the variables otherwise.
+type graphqlServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	methods   []string
+	persisted map[string]string
+}
+
+func newGraphqlServer(t *testing.T) *graphqlServer {
+	t.Helper()
+
+	server := &graphqlServer{persisted: map[string]string{}}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &struct {
+			Query      string                     `json:"query"`
+			Variables  map[string]interface{}     `json:"variables"`
+			Extensions map[string]json.RawMessage `json:"extensions"`
+		}{}
+
+		if r.Method == http.MethodGet {
+			query := r.URL.Query()
+
+			request.Query = query.Get("query")
+			_ = json.Unmarshal([]byte(query.Get("variables")), &request.Variables)
+			_ = json.Unmarshal([]byte(query.Get("extensions")), &request.Extensions)
+		} else {
+			body, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(body, request)
+		}
+
+		w.Header().Set("Content-Type", ContentTypeApplicationGraphqlResponse)
+
+		server.mu.Lock()
+		server.methods = append(server.methods, r.Method)
+
+		persistedQuery := &struct {
+			Sha256Hash string `json:"sha256Hash"`
+		}{}
+
+		if extension, ok := request.Extensions["persistedQuery"]; ok == true {
+			_ = json.Unmarshal(extension, persistedQuery)
+
+			if request.Query == "" {
+				request.Query = server.persisted[persistedQuery.Sha256Hash]
+			} else {
+				hash := sha256.Sum256([]byte(request.Query))
+				if hex.EncodeToString(hash[:]) != persistedQuery.Sha256Hash {
+					t.Errorf("persisted query hash = %s, want the sha256 of the document", persistedQuery.Sha256Hash)
+				}
+
+				server.persisted[persistedQuery.Sha256Hash] = request.Query
+			}
+		}
+		server.mu.Unlock()
+
+		switch {
+		case request.Query == "":
+			_, _ = w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotFound"}]}`))
+		case strings.Contains(request.Query, "partial"):
+			_, _ = w.Write([]byte(`{"data":{"user":{"name":"ann","email":null}},"errors":[` +
+				`{"message":"forbidden","path":["user","email"],"locations":[{"line":1,"column":20}],"extensions":{"code":"FORBIDDEN"}}]}`))
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"echo": request.Variables}})
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// takeMethods returns and resets the recorded request methods.
+func (p *graphqlServer) takeMethods() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	methods := p.methods
+	p.methods = nil
+
+	return methods
+}
+
+// graphqlEchoData is the response data of [graphqlServer] for an ordinary document.
+type graphqlEchoData struct {
+	Echo map[string]interface{} `json:"echo"`
+}
+
+func TestGraphqlQuery(t *testing.T) {
+	server := newGraphqlServer(t)
+	client := NewGraphqlClient(NewHttpClient(), server.URL)
+
+	data, err := GraphqlQuery[graphqlEchoData](context.Background(), client, "query Echo($id: ID!) { echo(id: $id) }", map[string]interface{}{"id": "42"})
+	if err != nil || data.Echo["id"] != "42" {
+		t.Fatalf("GraphqlQuery = %+v, %v, want the variables echoed", data, err)
+	}
+
+	methods := server.takeMethods()
+	if len(methods) != 1 || methods[0] != http.MethodPost {
+		t.Errorf("methods = %v, want a single POST", methods)
+	}
+}
+
+func TestGraphqlPartialErrors(t *testing.T) {
+	server := newGraphqlServer(t)
+	client := NewGraphqlClient(NewHttpClient(), server.URL)
+
+	data := &struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}{}
+
+	err := client.Do(context.Background(), &GraphqlRequest{Query: "{ partial { name email } }"}, data)
+
+	var graphqlErrs GraphqlErrors
+	if errors.As(err, &graphqlErrs) == false || len(graphqlErrs) != 1 {
+		t.Fatalf("Do = %v, want GraphqlErrors", err)
+	}
+
+	if data.User.Name != "ann" {
+		t.Errorf("partial data = %+v, want it decoded", data)
+	}
+
+	var graphqlErr *GraphqlError
+	if errors.As(err, &graphqlErr) == false {
+		t.Fatalf("Do = %v, want a *GraphqlError in the chain", err)
+	}
+
+	if graphqlErr.Code() != "FORBIDDEN" || len(graphqlErr.Locations) != 1 || graphqlErr.Locations[0].Column != 20 {
+		t.Errorf("error = %+v", graphqlErr)
+	}
+
+	if err.Error() != "thttp: GraphQL error at user.email: forbidden" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+
+	many := GraphqlErrors{{Message: "a"}, {Message: "b"}}
+	if many.Error() != "thttp: 2 GraphQL errors: a; b" {
+		t.Errorf("Error() = %q", many.Error())
+	}
+}
+
+func TestGraphqlHttpError(t *testing.T) {
+	tests := map[string]struct {
+		body    string
+		wantErr string
+	}{
+		"no errors":   {body: `{"data":null}`, wantErr: "thttp: GraphQL request failed with HTTP status 500"},
+		"not json":    {body: `<html>oops</html>`, wantErr: "thttp: GraphQL request failed with HTTP status 500"},
+		"with errors": {body: `{"errors":[{"message":"boom"}]}`, wantErr: "thttp: GraphQL error: boom"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newContentServer(t, http.StatusInternalServerError, ContentTypeApplicationJson, []byte(test.body))
+			client := NewGraphqlClient(NewHttpClient(), server.URL)
+
+			err := client.Do(context.Background(), &GraphqlRequest{Query: "{ a }"}, nil)
+			if err == nil || strings.HasPrefix(err.Error(), test.wantErr) == false {
+				t.Errorf("Do = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestGraphqlPersistedQueries(t *testing.T) {
+	server := newGraphqlServer(t)
+	client := NewGraphqlClient(NewHttpClient(), server.URL).WithPersistedQueries(true)
+
+	query := "query Echo($id: ID!) { echo(id: $id) }"
+
+	for _, wantMethods := range [][]string{{http.MethodGet, http.MethodPost}, {http.MethodGet}} {
+		data, err := GraphqlQuery[graphqlEchoData](context.Background(), client, query, map[string]interface{}{"id": "7"})
+		if err != nil || data.Echo["id"] != "7" {
+			t.Fatalf("GraphqlQuery = %+v, %v", data, err)
+		}
+
+		methods := server.takeMethods()
+		if strings.Join(methods, ",") != strings.Join(wantMethods, ",") {
+			t.Errorf("methods = %v, want %v", methods, wantMethods)
+		}
+	}
+
+	err := client.Do(context.Background(), &GraphqlRequest{Query: "mutation { echo }"}, nil)
+	if err != nil {
+		t.Fatalf("Do mutation: %v", err)
+	}
+
+	methods := server.takeMethods()
+	if len(methods) != 1 || methods[0] != http.MethodPost {
+		t.Errorf("mutation methods = %v, want a single POST", methods)
+	}
+}
+
+func TestGraphqlOperationType(t *testing.T) {
+	tests := map[string]struct {
+		document      string
+		operationName string
+		want          string
+	}{
+		"shorthand":             {document: "{ user { name } }", want: "query"},
+		"named query":           {document: "query User { user { name } }", want: "query"},
+		"mutation":              {document: "mutation { like(id: 1) }", want: "mutation"},
+		"subscription":          {document: "subscription { feed }", want: "subscription"},
+		"leading comment":       {document: "# query comment\nmutation M { like }", want: "mutation"},
+		"single-line":           {document: `mutation M { note(text: "query { x }") }`, want: "mutation"},
+		"block string":          {document: "mutation { note(text: \"\"\"a }\n\\\"\"\" b\"\"\") }", want: "mutation"},
+		"object argument":       {document: "mutation { set(input: {a: {b: 1}}) { ok } }", want: "mutation"},
+		"fragment first":        {document: "fragment F on User { name } mutation { create { ...F } }", want: "mutation"},
+		"selected mutation":     {document: "query Q { a } mutation M { b }", operationName: "M", want: "mutation"},
+		"selected query":        {document: "query Q { a } mutation M { b }", operationName: "Q", want: "query"},
+		"ambiguous":             {document: "query Q { a } mutation M { b }"},
+		"unknown operationName": {document: "query Q { a }", operationName: "M"},
+		"unterminated":          {document: "query { a "},
+		"garbage":               {document: "hello"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := graphqlOperationType(test.document, test.operationName)
+			if got != test.want {
+				t.Errorf("graphqlOperationType(%q, %q) = %q, want %q", test.document, test.operationName, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGraphqlPersistedQueriesPostUnlessQuery(t *testing.T) {
+	server := newGraphqlServer(t)
+	client := NewGraphqlClient(NewHttpClient(), server.URL).WithPersistedQueries(true)
+
+	requests := []*GraphqlRequest{
+		{Query: "# query\nmutation { echo }"},
+		{Query: "query Q { echo } mutation M { echo }", OperationName: "M"},
+		{Query: "query Q { echo } mutation M { echo }"},
+	}
+
+	for _, request := range requests {
+		err := client.Do(context.Background(), request, nil)
+		if err != nil {
+			t.Fatalf("Do(%q): %v", request.Query, err)
+		}
+
+		methods := server.takeMethods()
+		if len(methods) != 1 || methods[0] != http.MethodPost {
+			t.Errorf("Do(%q) methods = %v, want a single POST", request.Query, methods)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package thttp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// defaultBlockedCidrs are the non-public ranges a new [DialGuard] denies: unspecified, loopback, private,
+// carrier-grade NAT, link-local (including cloud metadata endpoints), IETF protocol assignments, benchmarking,
+// documentation, multicast, reserved, and broadcast addresses.
+var defaultBlockedCidrs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+
+	"::/128",
+	"::1/128",
+	"100::/64",
+	"2001:db8::/32",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// nat64Prefix is the well-known NAT64 prefix, whose addresses embed an IPv4 destination.
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// BlockedDestinationError is returned when a [DialGuard] refuses a request or connection. Retrying cannot succeed,
+// so the default retry policy does not retry it.
+type BlockedDestinationError struct {
+	// Address is the refused URL ("scheme://host:port") or dialed "ip:port".
+	Address string
+
+	// Reason describes the rule that refused it.
+	Reason string
+}
+
+// Error implements [error].
+func (p *BlockedDestinationError) Error() string {
+	return fmt.Sprintf("thttp: destination %s blocked: %s", p.Address, p.Reason)
+}
+
+// DialGuard restricts where an [HttpClient] may connect, protecting services that fetch user-supplied URLs against
+// server-side request forgery. IP rules are enforced on the resolved address of every connection, so host names
+// that resolve or rebind to a blocked address are refused; scheme and port rules are enforced on every request,
+// including each redirect hop. Through a proxy, the destination host is resolved and every address checked before
+// the request is sent, and hosts that cannot be resolved locally are refused; the proxy resolves the host again, so
+// a rebinding answer between the two lookups is not caught, and the proxy address itself must be allowed. Configure
+// it before passing it to [HttpClient.WithDialGuard].
+type DialGuard struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+
+	schemes map[string]bool
+	ports   map[int]bool
+
+	err error
+}
+
+// NewDialGuard returns a [DialGuard] that denies loopback, private, link-local, and other non-public addresses and
+// allows only the http and https schemes on any port.
+func NewDialGuard() *DialGuard {
+	guard := &DialGuard{
+		schemes: map[string]bool{
+			"http":  true,
+			"https": true,
+		},
+	}
+
+	return guard.WithDenyCidrs(defaultBlockedCidrs...)
+}
+
+// parseCidrs parses cidrs, accepting bare addresses as single-address prefixes.
+func parseCidrs(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+
+		if strings.Contains(cidr, "/") == false {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("thttp: invalid dial guard CIDR %q", cidr)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("thttp: invalid dial guard CIDR %q", cidr)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// WithDenyCidrs adds ranges, in CIDR notation or as single addresses, that may not be connected to. Deny
+// "0.0.0.0/0" and "::/0" to connect only to the ranges of [DialGuard.WithAllowCidrs].
+func (p *DialGuard) WithDenyCidrs(cidrs ...string) *DialGuard {
+	prefixes, err := parseCidrs(cidrs)
+	if err != nil {
+		p.err = err
+
+		return p
+	}
+
+	p.deny = append(p.deny, prefixes...)
+
+	return p
+}
+
+// WithAllowCidrs adds ranges, in CIDR notation or as single addresses, that may be connected to even when a deny
+// range covers them, for example an internal service the client is meant to reach.
+func (p *DialGuard) WithAllowCidrs(cidrs ...string) *DialGuard {
+	prefixes, err := parseCidrs(cidrs)
+	if err != nil {
+		p.err = err
+
+		return p
+	}
+
+	p.allow = append(p.allow, prefixes...)
+
+	return p
+}
+
+// WithSchemes replaces the allowed URL schemes (default http and https).
+func (p *DialGuard) WithSchemes(schemes ...string) *DialGuard {
+	p.schemes = make(map[string]bool, len(schemes))
+
+	for _, scheme := range schemes {
+		p.schemes[strings.ToLower(scheme)] = true
+	}
+
+	return p
+}
+
+// WithPorts restricts requests to the given ports, with the scheme default port used when a URL has none; no
+// ports (the default) allows any port.
+func (p *DialGuard) WithPorts(ports ...int) *DialGuard {
+	p.ports = make(map[int]bool, len(ports))
+
+	for _, port := range ports {
+		p.ports[port] = true
+	}
+
+	return p
+}
+
+// checkAddr returns a [*BlockedDestinationError] if addr may not be connected to. IPv4-mapped and NAT64 addresses
+// are checked as the IPv4 address they embed.
+func (p *DialGuard) checkAddr(addr netip.Addr, address string) error {
+	addr = addr.Unmap()
+
+	if addr.Is6() == true && nat64Prefix.Contains(addr) == true {
+		embedded := addr.As16()
+		addr = netip.AddrFrom4([4]byte{embedded[12], embedded[13], embedded[14], embedded[15]})
+	}
+
+	for _, prefix := range p.allow {
+		if prefix.Contains(addr) == true {
+			return nil
+		}
+	}
+
+	for _, prefix := range p.deny {
+		if prefix.Contains(addr) == true {
+			return &BlockedDestinationError{
+				Address: address,
+				Reason:  fmt.Sprintf("address %s is in denied range %s", addr, prefix),
+			}
+		}
+	}
+
+	return nil
+}
+
+// control is a [net.Dialer.Control] function that checks the resolved address of each connection.
+func (p *DialGuard) control(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &BlockedDestinationError{
+			Address: address,
+			Reason:  fmt.Sprintf("unexpected %s address", network),
+		}
+	}
+
+	return p.checkAddr(addrPort.Addr(), address)
+}
+
+// checkRequest returns a [*BlockedDestinationError] if the scheme or port of req is not allowed.
+func (p *DialGuard) checkRequest(req *http.Request) error {
+	scheme := strings.ToLower(req.URL.Scheme)
+
+	port := req.URL.Port()
+	if port == "" {
+		switch scheme {
+		case "http", "ws":
+			port = "80"
+		case "https", "wss":
+			port = "443"
+		}
+	}
+
+	address := scheme + "://" + req.URL.Host
+	if port != "" {
+		address = scheme + "://" + net.JoinHostPort(req.URL.Hostname(), port)
+	}
+
+	if p.schemes[scheme] == false {
+		return &BlockedDestinationError{
+			Address: address,
+			Reason:  fmt.Sprintf("scheme %q is not allowed", scheme),
+		}
+	}
+
+	if len(p.ports) > 0 {
+		portNum, err := strconv.Atoi(port)
+		if err != nil || p.ports[portNum] == false {
+			return &BlockedDestinationError{
+				Address: address,
+				Reason:  fmt.Sprintf("port %q is not allowed", port),
+			}
+		}
+	}
+
+	// IP literals are refused before dialing so the error is not wrapped in a dial error
+	addr, err := netip.ParseAddr(req.URL.Hostname())
+	if err == nil {
+		return p.checkAddr(addr, address)
+	}
+
+	return nil
+}
+
+// guardTransport refuses requests whose scheme or port a [DialGuard] does not allow. It wraps the base transport,
+// so every redirect hop and retry attempt is checked. Requests sent through a proxy also have their destination
+// resolved and checked here, because the proxy resolves it and the dial-time check only sees the proxy address.
+type guardTransport struct {
+	transport http.RoundTripper
+
+	guard *DialGuard
+
+	// proxy is the [http.Transport.Proxy] of the base transport, nil when no proxy is set.
+	proxy ProxyFunc
+	// resolver resolves proxied destinations, nil for the system resolver.
+	resolver *Resolver
+}
+
+// checkProxied returns a [*BlockedDestinationError] if any address of the host of req, which is sent through a
+// proxy, is blocked or the host cannot be resolved. IP literals were already checked by [DialGuard.checkRequest].
+func (p *guardTransport) checkProxied(req *http.Request) error {
+	host := req.URL.Hostname()
+
+	_, err := netip.ParseAddr(host)
+	if err == nil {
+		return nil
+	}
+
+	address := strings.ToLower(req.URL.Scheme) + "://" + req.URL.Host
+
+	ctx, cancel := context.WithTimeout(req.Context(), DefaultDialTimeout)
+	defer cancel()
+
+	var addrs []netip.Addr
+	if p.resolver != nil {
+		addrs, err = p.resolver.LookupNetIP(ctx, host)
+	} else {
+		addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	}
+
+	if err != nil {
+		return &BlockedDestinationError{
+			Address: address,
+			Reason:  fmt.Sprintf("proxied host cannot be resolved for checking: %v", err),
+		}
+	}
+
+	for _, addr := range addrs {
+		err = p.guard.checkAddr(addr, address)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (p *guardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := p.guard.checkRequest(req)
+
+	if err == nil && p.proxy != nil {
+		proxyUrl, proxyErr := p.proxy(req)
+		if proxyErr == nil && proxyUrl != nil {
+			err = p.checkProxied(req)
+		}
+	}
+
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	return p.transport.RoundTrip(req)
+}
+
+// wrapGuardTransport wraps transport with a [guardTransport] using guard, resolving proxied destinations with
+// resolver (nil for the system resolver).
+func wrapGuardTransport(transport *http.Transport, guard *DialGuard, resolver *Resolver) http.RoundTripper {
+	guardTransport := &guardTransport{
+		transport: transport,
+		guard:     guard,
+		proxy:     transport.Proxy,
+		resolver:  resolver,
+	}
+
+	return guardTransport
+}
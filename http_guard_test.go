@@ -0,0 +1,251 @@
+package thttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	_url "net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDialGuardControl(t *testing.T) {
+	guard := NewDialGuard()
+
+	tests := map[string]bool{
+		"127.0.0.1:80":               false,
+		"10.1.2.3:80":                false,
+		"172.16.0.1:80":              false,
+		"192.168.1.1:80":             false,
+		"100.64.0.1:80":              false,
+		"169.254.169.254:80":         false,
+		"0.0.0.0:80":                 false,
+		"224.0.0.1:80":               false,
+		"[::1]:80":                   false,
+		"[::]:80":                    false,
+		"[fe80::1]:80":               false,
+		"[fd00::1]:80":               false,
+		"[::ffff:127.0.0.1]:80":      false,
+		"[::ffff:10.0.0.1]:80":       false,
+		"[64:ff9b::a9fe:a9fe]:80":    false,
+		"[64:ff9b::7f00:1]:80":       false,
+		"8.8.8.8:443":                true,
+		"[2606:4700:4700::1111]:443": true,
+		"[::ffff:8.8.8.8]:443":       true,
+		"[64:ff9b::808:808]:443":     true,
+	}
+
+	for address, wantOk := range tests {
+		err := guard.control("tcp", address, nil)
+
+		var blockedErr *BlockedDestinationError
+		if wantOk == true && err != nil {
+			t.Errorf("control(%s) = %v, want allowed", address, err)
+		} else if wantOk == false && (errors.As(err, &blockedErr) == false || blockedErr.Address != address) {
+			t.Errorf("control(%s) = %v, want *BlockedDestinationError", address, err)
+		}
+	}
+
+	err := guard.control("unix", "/var/run/docker.sock", nil)
+	if err == nil {
+		t.Errorf("control of a Unix socket succeeded, want an error")
+	}
+}
+
+func TestDialGuardCidrs(t *testing.T) {
+	guard := NewDialGuard().WithAllowCidrs("127.0.0.1", "10.20.0.0/16").WithDenyCidrs("8.8.8.0/24")
+
+	tests := map[string]bool{
+		"127.0.0.1:80":   true,
+		"127.0.0.2:80":   false,
+		"10.20.30.40:80": true,
+		"10.21.0.1:80":   false,
+		"8.8.8.8:80":     false,
+		"8.8.4.4:80":     true,
+	}
+
+	for address, wantOk := range tests {
+		err := guard.control("tcp", address, nil)
+		if (err == nil) != wantOk {
+			t.Errorf("control(%s) = %v, want allowed %t", address, err, wantOk)
+		}
+	}
+
+	server := newContentServer(t, http.StatusOK, ContentTypeTextPlain, []byte("ok"))
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// host names are checked on the address they resolve to
+	resolver := NewResolver().WithOverride("svc.test", "127.0.0.1")
+
+	_, err := getText(NewHttpClient().WithResolver(resolver).WithDialGuard(NewDialGuard()), "http://svc.test:"+port)
+
+	var blockedErr *BlockedDestinationError
+	if errors.As(err, &blockedErr) == false {
+		t.Errorf("Get of a loopback host name = %v, want *BlockedDestinationError", err)
+	}
+
+	_, err = getText(NewHttpClient().WithDialGuard(NewDialGuard()), server.URL)
+	if errors.As(err, &blockedErr) == false || blockedErr.Address != "http://"+server.Listener.Addr().String() {
+		t.Errorf("Get of a loopback address = %v, want *BlockedDestinationError", err)
+	}
+
+	text, err := getText(NewHttpClient().WithResolver(resolver).WithDialGuard(guard), "http://svc.test:"+port)
+	if err != nil || text != "ok" {
+		t.Errorf("Get of an allowed address = %q, %v", text, err)
+	}
+
+	_, err = getText(NewHttpClient().WithDialGuard(NewDialGuard().WithAllowCidrs("10.0.0.0/33")), server.URL)
+	if err == nil || strings.Contains(err.Error(), "thttp: invalid dial guard CIDR") == false {
+		t.Errorf("Get with an invalid CIDR = %v, want the guard error", err)
+	}
+}
+
+func TestDialGuardRedirect(t *testing.T) {
+	target := newContentServer(t, http.StatusOK, ContentTypeTextPlain, []byte("target"))
+	_, targetPort, _ := net.SplitHostPort(target.Listener.Addr().String())
+
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	_, redirectorPort, _ := net.SplitHostPort(redirector.Listener.Addr().String())
+
+	redirectorPortNum, _ := strconv.Atoi(redirectorPort)
+	targetPortNum, _ := strconv.Atoi(targetPort)
+
+	tests := map[string]struct {
+		guard  *DialGuard
+		to     string
+		reason string
+	}{
+		"port": {
+			guard:  NewDialGuard().WithAllowCidrs("127.0.0.1").WithPorts(redirectorPortNum),
+			to:     target.URL,
+			reason: fmt.Sprintf("port %q is not allowed", targetPort),
+		},
+		"scheme": {
+			guard:  NewDialGuard().WithAllowCidrs("127.0.0.1").WithSchemes("http"),
+			to:     "https://127.0.0.1:" + targetPort,
+			reason: `scheme "https" is not allowed`,
+		},
+		"address": {
+			guard:  NewDialGuard().WithAllowCidrs("127.0.0.1"),
+			to:     "http://169.254.169.254/latest/meta-data",
+			reason: "address 169.254.169.254 is in denied range 169.254.0.0/16",
+		},
+		"allowed": {
+			guard: NewDialGuard().WithAllowCidrs("127.0.0.1").WithPorts(redirectorPortNum, targetPortNum),
+			to:    target.URL,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewHttpClient().WithDialGuard(test.guard)
+
+			text, err := getText(client, redirector.URL+"/?to="+_url.QueryEscape(test.to))
+			if test.reason == "" {
+				if err != nil || text != "target" {
+					t.Errorf("Get = %q, %v, want the redirect followed", text, err)
+				}
+
+				return
+			}
+
+			var blockedErr *BlockedDestinationError
+			if errors.As(err, &blockedErr) == false || blockedErr.Reason != test.reason {
+				t.Errorf("Get = %v, want the hop refused for %s", err, test.reason)
+			}
+		})
+	}
+}
+
+func TestBlockedDestinationNotRetried(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		http.Redirect(w, r, "http://10.0.0.1/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := NewHttpClient().WithDialGuard(NewDialGuard().WithAllowCidrs("127.0.0.1")).
+		WithRetryTransOption(NewRetryTransOption().WithMaxCount(3).WithWaitTime(200*time.Millisecond, 200*time.Millisecond))
+
+	start := time.Now()
+
+	_, err := getText(client, server.URL)
+
+	var blockedErr *BlockedDestinationError
+	if errors.As(err, &blockedErr) == false {
+		t.Fatalf("Get = %v, want *BlockedDestinationError", err)
+	}
+
+	// a retry would have waited before each attempt
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond || requests.Load() != 1 {
+		t.Errorf("Get took %s after %d requests, want no retry", elapsed, requests.Load())
+	}
+}
+
+func TestDialGuardProxiedDestination(t *testing.T) {
+	var proxied atomic.Int32
+
+	// a forward proxy answering every request itself
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+
+		_, _ = w.Write([]byte("proxied " + r.URL.Host))
+	}))
+	defer proxy.Close()
+
+	stub := newDnsStub(t, map[string]*dnsStubRecord{})
+
+	resolver := NewResolver().WithNameservers(stub.addr).
+		WithOverride("internal.test", "8.8.8.8", "10.0.0.5").
+		WithOverride("public.test", "8.8.8.8")
+
+	// the proxy itself is on loopback and has to be allowed
+	client := NewHttpClient().WithProxyUrl(proxy.URL).WithResolver(resolver).
+		WithDialGuard(NewDialGuard().WithAllowCidrs("127.0.0.1"))
+
+	tests := map[string]struct {
+		url    string
+		reason string
+	}{
+		"public":     {url: "http://public.test/"},
+		"internal":   {url: "http://internal.test/", reason: "address 10.0.0.5 is in denied range 10.0.0.0/8"},
+		"ip literal": {url: "http://10.0.0.5/", reason: "address 10.0.0.5 is in denied range 10.0.0.0/8"},
+		"unresolved": {url: "http://missing.test/", reason: "proxied host cannot be resolved for checking"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			before := proxied.Load()
+
+			text, err := getText(client, test.url)
+			if test.reason == "" {
+				if err != nil || text != "proxied public.test" {
+					t.Errorf("Get = %q, %v, want the proxy answer", text, err)
+				}
+
+				return
+			}
+
+			var blockedErr *BlockedDestinationError
+			if errors.As(err, &blockedErr) == false || strings.HasPrefix(blockedErr.Reason, test.reason) == false {
+				t.Errorf("Get = %v, want %q", err, test.reason)
+			}
+
+			if proxied.Load() != before {
+				t.Errorf("the refused request reached the proxy")
+			}
+		})
+	}
+}
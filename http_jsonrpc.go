@@ -0,0 +1,372 @@
+package thttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+)
+
+// JsonRpcVersion is the protocol version sent in every JSON-RPC request.
+const JsonRpcVersion = "2.0"
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	JsonRpcParseError     = -32700
+	JsonRpcInvalidRequest = -32600
+	JsonRpcMethodNotFound = -32601
+	JsonRpcInvalidParams  = -32602
+	JsonRpcInternalError  = -32603
+)
+
+// JsonRpcError is a JSON-RPC error object returned by the server.
+type JsonRpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+
+	// Data holds the optional, server-defined error details undecoded.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Error implements [error].
+func (p *JsonRpcError) Error() string {
+	if len(p.Data) == 0 {
+		return fmt.Sprintf("thttp: JSON-RPC error %d: %s", p.Code, p.Message)
+	}
+
+	return fmt.Sprintf("thttp: JSON-RPC error %d: %s (data: %s)", p.Code, p.Message, p.Data)
+}
+
+// jsonRpcRequest is the wire form of a call or, without Id, a notification.
+type jsonRpcRequest struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	Id      *int64      `json:"id,omitempty"`
+}
+
+// jsonRpcResponse is the wire form of a response object.
+type jsonRpcResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *JsonRpcError   `json:"error"`
+}
+
+// JsonRpcClient calls a JSON-RPC 2.0 endpoint over HTTP POST through an [HttpClient], so the client's headers,
+// retry, logging, and other options apply to every call. It is safe for concurrent use.
+type JsonRpcClient struct {
+	client *HttpClient
+	url    string
+
+	requestOption *RequestOption
+
+	nextId atomic.Int64
+}
+
+// NewJsonRpcClient returns a client for the endpoint at url. A nil client uses the package default client.
+func NewJsonRpcClient(client *HttpClient, url string) *JsonRpcClient {
+	if client == nil {
+		client = defaultClient
+	}
+
+	return &JsonRpcClient{
+		client: client,
+		url:    url,
+	}
+}
+
+// WithRequestOption sets per-request options (headers, timeout, and so on) applied to every call; the option is
+// copied per call and never modified.
+func (p *JsonRpcClient) WithRequestOption(requestOption *RequestOption) *JsonRpcClient {
+	p.requestOption = requestOption
+
+	return p
+}
+
+// newId returns the next request id; ids start at 1 and are unique per client.
+func (p *JsonRpcClient) newId() *int64 {
+	id := p.nextId.Add(1)
+
+	return &id
+}
+
+// post sends payload as JSON to the endpoint with a private copy of the configured request option.
+func (p *JsonRpcClient) post(ctx context.Context, payload interface{}) (*Response, error) {
+	resp, err := p.client.PostJson(ctx, p.url, p.requestOption.clone(), payload)
+	if err != nil {
+		if resp != nil && resp.Response != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Call invokes method with params (a struct, map, or slice; nil omits params) and decodes the result into result,
+// which may be nil to discard it. Server error objects are returned as [*JsonRpcError], and a response whose id
+// differs from the request's is an error.
+func (p *JsonRpcClient) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := p.newId()
+
+	resp, err := p.post(ctx, &jsonRpcRequest{
+		Jsonrpc: JsonRpcVersion,
+		Method:  method,
+		Params:  params,
+		Id:      id,
+	})
+	if err != nil {
+		return err
+	}
+
+	rpcResp := &jsonRpcResponse{}
+
+	statusCode, err := resp.ToJson(rpcResp, nil)
+	if err != nil {
+		if statusCode >= 400 {
+			return fmt.Errorf("thttp: JSON-RPC call %s failed with HTTP status %d: %w", method, statusCode, err)
+		}
+
+		return err
+	}
+
+	if rpcResp.Error == nil && statusCode >= 400 {
+		return fmt.Errorf("thttp: JSON-RPC call %s failed with HTTP status %d", method, statusCode)
+	}
+
+	if rpcResp.idKey() != strconv.FormatInt(*id, 10) {
+		// servers answer with a null id when they cannot read the request's id
+		if rpcResp.Error != nil && rpcResp.idKey() == "null" {
+			return rpcResp.Error
+		}
+
+		return fmt.Errorf("thttp: JSON-RPC call %s got a response for id %s, want id %d", method, rpcResp.idKey(), *id)
+	}
+
+	return rpcResp.decodeResult(result)
+}
+
+// idKey returns the response id in the form ids are matched by: integers in decimal without a fraction or exponent,
+// any other value as its JSON text, and "null" when the id is null or missing.
+func (p *jsonRpcResponse) idKey() string {
+	raw := bytes.TrimSpace(p.Id)
+	if len(raw) == 0 {
+		return "null"
+	}
+
+	var number json.Number
+
+	err := json.Unmarshal(raw, &number)
+	if err == nil && bytes.HasPrefix(raw, []byte(`"`)) == false {
+		id, err := strconv.ParseInt(number.String(), 10, 64)
+		if err == nil {
+			return strconv.FormatInt(id, 10)
+		}
+
+		float, err := number.Float64()
+		if err == nil && float == float64(int64(float)) {
+			return strconv.FormatInt(int64(float), 10)
+		}
+	}
+
+	return string(raw)
+}
+
+// decodeResult returns the error object, or unmarshals the result into result when it is non-nil.
+func (p *jsonRpcResponse) decodeResult(result interface{}) error {
+	if p.Error != nil {
+		return p.Error
+	}
+
+	if result == nil || len(p.Result) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(p.Result, result)
+}
+
+// Notify sends a notification, which has no id and gets no response. Only transport and HTTP status errors are
+// reported.
+func (p *JsonRpcClient) Notify(ctx context.Context, method string, params interface{}) error {
+	resp, err := p.post(ctx, &jsonRpcRequest{
+		Jsonrpc: JsonRpcVersion,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("thttp: JSON-RPC notification %s failed with HTTP status %d", method, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// JsonRpcCall invokes method on client and decodes the result into a new T. See [JsonRpcClient.Call].
+func JsonRpcCall[T any](ctx context.Context, client *JsonRpcClient, method string, params interface{}) (T, error) {
+	var result T
+
+	err := client.Call(ctx, method, params, &result)
+
+	return result, err
+}
+
+// JsonRpcBatchCall is one entry of a [JsonRpcBatch]. After [JsonRpcClient.SendBatch] returns nil, Err holds the
+// call's own outcome; it is always nil for notifications.
+type JsonRpcBatchCall struct {
+	Method string
+	Params interface{}
+
+	// Result receives the decoded result; nil discards it.
+	Result interface{}
+
+	Err error
+
+	notify bool
+	id     *int64
+}
+
+// JsonRpcBatch collects calls and notifications sent together in one HTTP request with [JsonRpcClient.SendBatch].
+type JsonRpcBatch struct {
+	calls []*JsonRpcBatchCall
+}
+
+// NewJsonRpcBatch returns an empty batch.
+func NewJsonRpcBatch() *JsonRpcBatch {
+	return &JsonRpcBatch{
+		calls: make([]*JsonRpcBatchCall, 0),
+	}
+}
+
+// Call adds a call whose result is decoded into result and returns its entry for inspecting Err afterwards.
+func (p *JsonRpcBatch) Call(method string, params interface{}, result interface{}) *JsonRpcBatchCall {
+	call := &JsonRpcBatchCall{
+		Method: method,
+		Params: params,
+		Result: result,
+	}
+
+	p.calls = append(p.calls, call)
+
+	return call
+}
+
+// Notify adds a notification to the batch.
+func (p *JsonRpcBatch) Notify(method string, params interface{}) *JsonRpcBatch {
+	p.calls = append(p.calls, &JsonRpcBatchCall{
+		Method: method,
+		Params: params,
+
+		notify: true,
+	})
+
+	return p
+}
+
+// SendBatch sends every entry of batch in one request and matches the responses to calls by id. The returned error
+// covers the exchange as a whole (transport, HTTP status, or an undecodable body); per-call outcomes, including
+// a missing response, are stored in each [JsonRpcBatchCall.Err]. An empty batch is not sent.
+func (p *JsonRpcClient) SendBatch(ctx context.Context, batch *JsonRpcBatch) error {
+	if batch == nil || len(batch.calls) == 0 {
+		return nil
+	}
+
+	payload := make([]*jsonRpcRequest, 0, len(batch.calls))
+	pending := make(map[string]*JsonRpcBatchCall)
+
+	for _, call := range batch.calls {
+		call.Err = nil
+
+		if call.notify == false {
+			call.id = p.newId()
+			pending[strconv.FormatInt(*call.id, 10)] = call
+		}
+
+		payload = append(payload, &jsonRpcRequest{
+			Jsonrpc: JsonRpcVersion,
+			Method:  call.Method,
+			Params:  call.Params,
+			Id:      call.id,
+		})
+	}
+
+	resp, err := p.post(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	statusCode, body, err := resp.ToBytes()
+	if err != nil {
+		return err
+	}
+
+	body = bytes.TrimSpace(body)
+
+	// a batch of notifications only gets an empty response
+	if len(pending) == 0 && len(body) == 0 {
+		if statusCode >= 400 {
+			return fmt.Errorf("thttp: JSON-RPC batch failed with HTTP status %d", statusCode)
+		}
+
+		return nil
+	}
+
+	rpcResps := make([]*jsonRpcResponse, 0, len(pending))
+
+	if bytes.HasPrefix(body, []byte("{")) {
+		// servers reject a malformed batch with a single error object
+		rpcResp := &jsonRpcResponse{}
+
+		err = json.Unmarshal(body, rpcResp)
+		if err == nil && rpcResp.Error != nil {
+			for _, call := range pending {
+				call.Err = rpcResp.Error
+			}
+
+			return nil
+		}
+	} else {
+		err = json.Unmarshal(body, &rpcResps)
+	}
+
+	if err != nil || (len(rpcResps) == 0 && len(pending) > 0) {
+		if err == nil {
+			err = errors.New("unexpected response body")
+		}
+
+		if statusCode >= 400 {
+			return fmt.Errorf("thttp: JSON-RPC batch failed with HTTP status %d: %w", statusCode, err)
+		}
+
+		return fmt.Errorf("thttp: cannot decode JSON-RPC batch response: %w", err)
+	}
+
+	for _, rpcResp := range rpcResps {
+		// responses may come in any order; unknown and repeated ids are ignored
+		call, ok := pending[rpcResp.idKey()]
+		if ok == false {
+			continue
+		}
+
+		call.Err = rpcResp.decodeResult(call.Result)
+
+		delete(pending, rpcResp.idKey())
+	}
+
+	for _, call := range pending {
+		call.Err = fmt.Errorf("thttp: no JSON-RPC response for %s (id %d)", call.Method, *call.id)
+	}
+
+	return nil
+}
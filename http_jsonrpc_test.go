@@ -0,0 +1,322 @@
+package thttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// jsonRpcTestRequest is a request as decoded by [newJsonRpcServer].
+type jsonRpcTestRequest struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Id      json.RawMessage `json:"id"`
+}
+
+// jsonRpcTestAnswer computes the response of one request: add sums its params, fail returns an error object.
+// Notifications get no response.
+func jsonRpcTestAnswer(request *jsonRpcTestRequest) map[string]interface{} {
+	if len(request.Id) == 0 {
+		return nil
+	}
+
+	answer := map[string]interface{}{"jsonrpc": JsonRpcVersion, "id": request.Id}
+
+	switch request.Method {
+	case "add":
+		var params []int
+		_ = json.Unmarshal(request.Params, &params)
+
+		sum := 0
+		for _, param := range params {
+			sum += param
+		}
+
+		answer["result"] = sum
+	case "fail":
+		answer["error"] = map[string]interface{}{"code": JsonRpcInvalidParams, "message": "bad params", "data": []int{1}}
+	default:
+		answer["error"] = map[string]interface{}{"code": JsonRpcMethodNotFound, "message": "method not found"}
+	}
+
+	return answer
+}
+
+// newJsonRpcServer returns a JSON-RPC 2.0 endpoint built on [jsonRpcTestAnswer]. Batch responses come back in
+// reverse order, and the number of notifications seen is counted in notifications.
+func newJsonRpcServer(t *testing.T, notifications *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+
+		requests := make([]*jsonRpcTestRequest, 0)
+
+		batch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+		if batch == true {
+			_ = json.Unmarshal(body, &requests)
+		} else {
+			request := &jsonRpcTestRequest{}
+			_ = json.Unmarshal(body, request)
+
+			requests = append(requests, request)
+		}
+
+		answers := make([]map[string]interface{}, 0)
+
+		for i := len(requests) - 1; i >= 0; i-- {
+			if requests[i].Jsonrpc != JsonRpcVersion {
+				t.Errorf("jsonrpc = %q", requests[i].Jsonrpc)
+			}
+
+			answer := jsonRpcTestAnswer(requests[i])
+			if answer == nil {
+				if notifications != nil {
+					notifications.Add(1)
+				}
+
+				continue
+			}
+
+			answers = append(answers, answer)
+		}
+
+		switch {
+		case len(answers) == 0:
+			w.WriteHeader(http.StatusNoContent)
+		case batch == true:
+			_ = json.NewEncoder(w).Encode(answers)
+		default:
+			_ = json.NewEncoder(w).Encode(answers[0])
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestJsonRpcCall(t *testing.T) {
+	server := newJsonRpcServer(t, nil)
+	client := NewJsonRpcClient(NewHttpClient(), server.URL)
+
+	var sum int
+
+	err := client.Call(context.Background(), "add", []int{1, 2, 3}, &sum)
+	if err != nil || sum != 6 {
+		t.Fatalf("Call = %d, %v, want 6", sum, err)
+	}
+
+	sum, err = JsonRpcCall[int](context.Background(), client, "add", []int{4, 5})
+	if err != nil || sum != 9 {
+		t.Errorf("JsonRpcCall = %d, %v, want 9", sum, err)
+	}
+
+	err = client.Call(context.Background(), "add", []int{1}, nil)
+	if err != nil {
+		t.Errorf("Call with a nil result = %v", err)
+	}
+}
+
+func TestJsonRpcCallError(t *testing.T) {
+	server := newJsonRpcServer(t, nil)
+	client := NewJsonRpcClient(NewHttpClient(), server.URL)
+
+	err := client.Call(context.Background(), "fail", nil, nil)
+
+	var rpcErr *JsonRpcError
+	if errors.As(err, &rpcErr) == false {
+		t.Fatalf("Call = %v, want *JsonRpcError", err)
+	}
+
+	if rpcErr.Code != JsonRpcInvalidParams || rpcErr.Message != "bad params" || string(rpcErr.Data) != "[1]" {
+		t.Errorf("error = %+v", rpcErr)
+	}
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<html>bad gateway</html>"))
+	}))
+	defer broken.Close()
+
+	err = NewJsonRpcClient(NewHttpClient(), broken.URL).Call(context.Background(), "add", nil, nil)
+	if err == nil || strings.Contains(err.Error(), "HTTP status 502") == false {
+		t.Errorf("Call against a failing endpoint = %v, want the HTTP status", err)
+	}
+}
+
+func TestJsonRpcNotify(t *testing.T) {
+	var notifications atomic.Int32
+
+	server := newJsonRpcServer(t, &notifications)
+	client := NewJsonRpcClient(NewHttpClient(), server.URL)
+
+	err := client.Notify(context.Background(), "add", []int{1})
+	if err != nil || notifications.Load() != 1 {
+		t.Errorf("Notify = %v, notifications = %d, want 1", err, notifications.Load())
+	}
+}
+
+func TestJsonRpcBatch(t *testing.T) {
+	var notifications atomic.Int32
+
+	server := newJsonRpcServer(t, &notifications)
+	client := NewJsonRpcClient(NewHttpClient(), server.URL)
+
+	var first, second int
+
+	batch := NewJsonRpcBatch()
+	firstCall := batch.Call("add", []int{1, 2}, &first)
+	failCall := batch.Call("fail", nil, nil)
+	batch.Notify("add", []int{0})
+	secondCall := batch.Call("add", []int{10, 20}, &second)
+
+	err := client.SendBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+
+	if firstCall.Err != nil || first != 3 || secondCall.Err != nil || second != 30 {
+		t.Errorf("results = %d (%v), %d (%v), want 3 and 30 matched by id", first, firstCall.Err, second, secondCall.Err)
+	}
+
+	var rpcErr *JsonRpcError
+	if errors.As(failCall.Err, &rpcErr) == false || rpcErr.Code != JsonRpcInvalidParams {
+		t.Errorf("fail call error = %v", failCall.Err)
+	}
+
+	if notifications.Load() != 1 {
+		t.Errorf("notifications = %d, want 1", notifications.Load())
+	}
+
+	// a batch of notifications only gets an empty response
+	err = client.SendBatch(context.Background(), NewJsonRpcBatch().Notify("add", nil).Notify("add", nil))
+	if err != nil || notifications.Load() != 3 {
+		t.Errorf("SendBatch of notifications = %v, notifications = %d, want 3", err, notifications.Load())
+	}
+
+	err = client.SendBatch(context.Background(), NewJsonRpcBatch())
+	if err != nil {
+		t.Errorf("SendBatch of an empty batch = %v", err)
+	}
+}
+
+func TestJsonRpcBatchMissingAndRejected(t *testing.T) {
+	tests := map[string]struct {
+		body    string
+		wantErr string
+	}{
+		"missing response": {body: `[]`, wantErr: "thttp: cannot decode JSON-RPC batch response"},
+		"batch rejected":   {body: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`},
+		"partial":          {body: `[{"jsonrpc":"2.0","id":1,"result":1}]`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newContentServer(t, http.StatusOK, ContentTypeApplicationJson, []byte(test.body))
+			client := NewJsonRpcClient(NewHttpClient(), server.URL)
+
+			batch := NewJsonRpcBatch()
+			firstCall := batch.Call("add", nil, nil)
+			secondCall := batch.Call("add", nil, nil)
+
+			err := client.SendBatch(context.Background(), batch)
+			if test.wantErr != "" {
+				if err == nil || strings.Contains(err.Error(), test.wantErr) == false {
+					t.Errorf("SendBatch = %v, want %q", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("SendBatch: %v", err)
+			}
+
+			if name == "partial" {
+				if firstCall.Err != nil || secondCall.Err == nil {
+					t.Errorf("errors = %v, %v, want only the unanswered call to fail", firstCall.Err, secondCall.Err)
+				}
+
+				return
+			}
+
+			var rpcErr *JsonRpcError
+			if errors.As(firstCall.Err, &rpcErr) == false || errors.As(secondCall.Err, &rpcErr) == false {
+				t.Errorf("errors = %v, %v, want the batch error on every call", firstCall.Err, secondCall.Err)
+			}
+		})
+	}
+}
+
+func TestJsonRpcCallMatchesId(t *testing.T) {
+	tests := map[string]struct {
+		body    string
+		wantErr string
+		rpcErr  bool
+	}{
+		"float id":      {body: `{"jsonrpc":"2.0","id":1.0,"result":7}`},
+		"exponent id":   {body: `{"jsonrpc":"2.0","id":1e0,"result":7}`},
+		"other id":      {body: `{"jsonrpc":"2.0","id":2,"result":7}`, wantErr: "got a response for id 2, want id 1"},
+		"string id":     {body: `{"jsonrpc":"2.0","id":"1","result":7}`, wantErr: `got a response for id "1", want id 1`},
+		"missing id":    {body: `{"jsonrpc":"2.0","result":7}`, wantErr: "got a response for id null, want id 1"},
+		"null id error": {body: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`, rpcErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newContentServer(t, http.StatusOK, ContentTypeApplicationJson, []byte(test.body))
+			client := NewJsonRpcClient(NewHttpClient(), server.URL)
+
+			var result int
+
+			err := client.Call(context.Background(), "add", nil, &result)
+
+			switch {
+			case test.rpcErr == true:
+				var rpcErr *JsonRpcError
+				if errors.As(err, &rpcErr) == false || rpcErr.Code != JsonRpcParseError {
+					t.Errorf("Call = %v, want the error object", err)
+				}
+			case test.wantErr != "":
+				if err == nil || strings.Contains(err.Error(), test.wantErr) == false {
+					t.Errorf("Call = %v, want %q", err, test.wantErr)
+				}
+			case err != nil || result != 7:
+				t.Errorf("Call = %d, %v, want 7", result, err)
+			}
+		})
+	}
+}
+
+func TestJsonRpcBatchMatchesNumericIds(t *testing.T) {
+	body := `[{"jsonrpc":"2.0","id":2.0,"result":2},{"jsonrpc":"2.0","id":"1","result":-1},{"jsonrpc":"2.0","id":1,"result":1}]`
+
+	server := newContentServer(t, http.StatusOK, ContentTypeApplicationJson, []byte(body))
+	client := NewJsonRpcClient(NewHttpClient(), server.URL)
+
+	var first, second int
+
+	batch := NewJsonRpcBatch()
+	firstCall := batch.Call("add", nil, &first)
+	secondCall := batch.Call("add", nil, &second)
+
+	err := client.SendBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+
+	if firstCall.Err != nil || first != 1 || secondCall.Err != nil || second != 2 {
+		t.Errorf("results = %d (%v), %d (%v), want 1 and 2", first, firstCall.Err, second, secondCall.Err)
+	}
+}
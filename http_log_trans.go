@@ -3,7 +3,8 @@ package thttp
 import (
 	"fmt"
 	"net/http"
-	"strings"
+	"net/http/httptrace"
+	"sync/atomic"
 	"time"
 
 	"github.com/choveylee/tlog"
@@ -89,8 +90,49 @@ func shouldEmitSlowLog(resp *http.Response, err error, ignoreNotFound bool) bool
 	return true
 }
 
+// withCompressDetail adds the uncompressed and compressed request body sizes when [OptRequestCompress] applied.
+func withCompressDetail(event *tlog.Tevent, req *http.Request) *tlog.Tevent {
+	compressStats := requestCompressStatsFromContext(req.Context())
+	if compressStats == nil {
+		return event
+	}
+
+	return event.Detailf("req.body_size: %d", compressStats.rawSize).
+		Detailf("req.compressed_body_size: %d (%s)", compressStats.compressedSize, compressStats.encoding)
+}
+
+// withRemoteAddrTrace returns req with a [httptrace.ClientTrace] recording the address of the connection used, so
+// logs show where the host name resolved to.
+func withRemoteAddrTrace(req *http.Request, remoteAddr *atomic.Value) *http.Request {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Conn != nil && info.Conn.RemoteAddr() != nil {
+				remoteAddr.Store(info.Conn.RemoteAddr().String())
+			}
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// withRemoteAddrDetail adds the connection address recorded by [withRemoteAddrTrace], when known.
+func withRemoteAddrDetail(event *tlog.Tevent, remoteAddr *atomic.Value) *tlog.Tevent {
+	addr, ok := remoteAddr.Load().(string)
+	if ok == false {
+		return event
+	}
+
+	return event.Detailf("req.remote_addr: %s", addr)
+}
+
 // RoundTrip implements [http.RoundTripper].
 func (p *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	remoteAddr := &atomic.Value{}
+
+	if p.logTransOption.enableSlowLog == true || p.logTransOption.enableAccessLog == true {
+		req = withRemoteAddrTrace(req, remoteAddr)
+	}
+
 	startedAt := time.Now()
 	resp, err := p.transport.RoundTrip(req)
 	latency := time.Since(startedAt)
@@ -101,8 +143,8 @@ func (p *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		httpClientRequestHistogram.Observe(float64(latency)/float64(time.Millisecond), req.Method, fmt.Sprint(resp.StatusCode), req.Host)
 	}
 
-	// add slow log
-	if p.logTransOption.enableSlowLog == true &&
+	// add slow log, except for long-lived streams whose first byte may legitimately take a while
+	if p.logTransOption.enableSlowLog == true && isStreamRequest(req.Context()) == false &&
 		latency > p.logTransOption.slowLatency &&
 		shouldEmitSlowLog(resp, err, p.logTransOption.ignoreNotFound) {
 		event := tlog.I(req.Context()).Err(err).Detailf("req.method: %s", req.Method).
@@ -113,6 +155,9 @@ func (p *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 			event = event.Detailf("resp.status code: %d", resp.StatusCode)
 		}
 
+		event = withRemoteAddrDetail(event, remoteAddr)
+		event = withCompressDetail(event, req)
+
 		event.Msg("thttp slow request observed")
 	}
 
@@ -122,14 +167,17 @@ func (p *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 			Detailf("req.host: %s", req.Host).Detailf("req.url: %s", req.URL.String()).
 			Detailf("latency_ms: %d", latency.Milliseconds())
 
+		event = withRemoteAddrDetail(event, remoteAddr)
+		event = withCompressDetail(event, req)
+
 		if p.logTransOption.includeHeaders == true {
 			for key, vals := range req.Header {
-				event = event.Detailf("req.header.%s: %s", key, strings.Join(vals, ";"))
+				event = event.Detailf("req.header.%s: %s", key, headerLogValue(key, vals))
 			}
 
 			if resp != nil {
 				for key, vals := range resp.Header {
-					event = event.Detailf("resp.header.%s: %s", key, strings.Join(vals, ";"))
+					event = event.Detailf("resp.header.%s: %s", key, headerLogValue(key, vals))
 				}
 			}
 		}
@@ -0,0 +1,926 @@
+package thttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP Message Signatures (RFC 9421) algorithm identifiers.
+const (
+	MessageSignatureHmacSha256      = "hmac-sha256"
+	MessageSignatureEd25519         = "ed25519"
+	MessageSignatureEcdsaP256Sha256 = "ecdsa-p256-sha256"
+)
+
+const (
+	// DefaultMessageSignatureLabel is the label of signatures added by [MessageSigner].
+	DefaultMessageSignatureLabel = "sig1"
+
+	// DefaultMessageSignatureClockSkew is the clock difference tolerated when checking created and expires.
+	DefaultMessageSignatureClockSkew = time.Minute
+)
+
+// defaultMessageSignatureComponents are covered when [MessageSigner.WithComponents] is not used; content-digest and
+// content-type are added for requests with a body.
+var defaultMessageSignatureComponents = []string{"@method", "@authority", "@path", "@query"}
+
+// contentDigest returns the RFC 9530 Content-Digest value for body using sha-256.
+func contentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// verifyContentDigest checks every sha-256 and sha-512 member of a Content-Digest header against body. At least one
+// supported member must be present.
+func verifyContentDigest(header string, body []byte) error {
+	checked := false
+
+	for _, member := range splitSfList(header) {
+		name, value, ok := strings.Cut(member, "=")
+		if ok == false {
+			continue
+		}
+
+		var sum []byte
+
+		switch strings.TrimSpace(name) {
+		case "sha-256":
+			digest := sha256.Sum256(body)
+			sum = digest[:]
+		case "sha-512":
+			digest := sha512.Sum512(body)
+			sum = digest[:]
+		default:
+			continue
+		}
+
+		expected, err := parseSfBinary(value)
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare(expected, sum) != 1 {
+			return fmt.Errorf("thttp: Content-Digest %s does not match the body", strings.TrimSpace(name))
+		}
+
+		checked = true
+	}
+
+	if checked == false {
+		return errors.New("thttp: Content-Digest has no supported algorithm")
+	}
+
+	return nil
+}
+
+// splitSfList splits a structured field list or dictionary at top-level commas, leaving commas inside strings,
+// inner lists, and byte sequences alone.
+func splitSfList(value string) []string {
+	members := make([]string, 0)
+
+	depth := 0
+	inString := false
+	inBinary := false
+	start := 0
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case inBinary:
+			if c == ':' {
+				inBinary = false
+			}
+		case c == '"':
+			inString = true
+		case c == ':':
+			inBinary = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			members = append(members, strings.TrimSpace(value[start:i]))
+			start = i + 1
+		}
+	}
+
+	last := strings.TrimSpace(value[start:])
+	if last != "" {
+		members = append(members, last)
+	}
+
+	return members
+}
+
+// parseSfBinary decodes a structured field byte sequence (":base64:").
+func parseSfBinary(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+		return nil, fmt.Errorf("thttp: invalid structured field byte sequence %q", value)
+	}
+
+	return base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+}
+
+// parseSfString reads a quoted string starting at pos and returns it with the position after the closing quote.
+func parseSfString(value string, pos int) (string, int, error) {
+	if pos >= len(value) || value[pos] != '"' {
+		return "", pos, errors.New("thttp: expected quoted string in structured field")
+	}
+
+	builder := strings.Builder{}
+
+	for pos++; pos < len(value); pos++ {
+		switch value[pos] {
+		case '\\':
+			pos++
+			if pos < len(value) {
+				builder.WriteByte(value[pos])
+			}
+		case '"':
+			return builder.String(), pos + 1, nil
+		default:
+			builder.WriteByte(value[pos])
+		}
+	}
+
+	return "", pos, errors.New("thttp: unterminated quoted string in structured field")
+}
+
+// signatureInput is one parsed Signature-Input member.
+type signatureInput struct {
+	label      string
+	components []string
+
+	// params holds the signature parameters; string values are unquoted.
+	params map[string]string
+	// raw is the serialized member value, used verbatim as @signature-params.
+	raw string
+}
+
+// parseSignatureInput parses a Signature-Input header into its members in order.
+func parseSignatureInput(header string) ([]*signatureInput, error) {
+	inputs := make([]*signatureInput, 0)
+
+	for _, member := range splitSfList(header) {
+		label, raw, ok := strings.Cut(member, "=")
+		if ok == false {
+			return nil, fmt.Errorf("thttp: invalid Signature-Input member %q", member)
+		}
+
+		raw = strings.TrimSpace(raw)
+
+		input := &signatureInput{
+			label:      strings.TrimSpace(label),
+			components: make([]string, 0),
+			params:     make(map[string]string),
+			raw:        raw,
+		}
+
+		if len(raw) == 0 || raw[0] != '(' {
+			return nil, fmt.Errorf("thttp: invalid Signature-Input member %q: want inner list", member)
+		}
+
+		pos := 1
+		for {
+			for pos < len(raw) && raw[pos] == ' ' {
+				pos++
+			}
+
+			if pos >= len(raw) {
+				return nil, fmt.Errorf("thttp: unterminated Signature-Input member %q", member)
+			}
+
+			if raw[pos] == ')' {
+				pos++
+
+				break
+			}
+
+			component, next, err := parseSfString(raw, pos)
+			if err != nil {
+				return nil, err
+			}
+
+			if next < len(raw) && raw[next] == ';' {
+				return nil, fmt.Errorf("thttp: unsupported component parameters in %q", member)
+			}
+
+			input.components = append(input.components, component)
+			pos = next
+		}
+
+		for pos < len(raw) {
+			if raw[pos] != ';' {
+				return nil, fmt.Errorf("thttp: invalid Signature-Input parameters in %q", member)
+			}
+
+			pos++
+
+			nameEnd := strings.IndexByte(raw[pos:], '=')
+			if nameEnd < 0 {
+				return nil, fmt.Errorf("thttp: invalid Signature-Input parameters in %q", member)
+			}
+
+			name := raw[pos : pos+nameEnd]
+			pos += nameEnd + 1
+
+			if pos < len(raw) && raw[pos] == '"' {
+				val, next, err := parseSfString(raw, pos)
+				if err != nil {
+					return nil, err
+				}
+
+				input.params[name] = val
+				pos = next
+			} else {
+				end := strings.IndexByte(raw[pos:], ';')
+				if end < 0 {
+					end = len(raw) - pos
+				}
+
+				input.params[name] = raw[pos : pos+end]
+				pos += end
+			}
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+// requestScheme returns the lowercase scheme of req on either side of the connection.
+func requestScheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+
+	if req.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// componentValue returns the value of one covered component of req.
+func componentValue(req *http.Request, component string) (string, error) {
+	switch component {
+	case "@method":
+		return req.Method, nil
+	case "@scheme":
+		return requestScheme(req), nil
+	case "@authority":
+		authority := strings.ToLower(requestHost(req))
+
+		scheme := requestScheme(req)
+		if (scheme == "http" && strings.HasSuffix(authority, ":80")) ||
+			(scheme == "https" && strings.HasSuffix(authority, ":443")) {
+			authority = authority[:strings.LastIndexByte(authority, ':')]
+		}
+
+		return authority, nil
+	case "@target-uri":
+		return requestScheme(req) + "://" + strings.ToLower(requestHost(req)) + req.URL.RequestURI(), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		path := req.URL.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+
+		return path, nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	}
+
+	if strings.HasPrefix(component, "@") {
+		return "", fmt.Errorf("thttp: unsupported derived component %q", component)
+	}
+
+	vals := req.Header.Values(component)
+	if len(vals) == 0 {
+		return "", fmt.Errorf("thttp: covered header %q is missing", component)
+	}
+
+	trimmed := make([]string, 0, len(vals))
+	for _, val := range vals {
+		trimmed = append(trimmed, strings.TrimSpace(val))
+	}
+
+	return strings.Join(trimmed, ", "), nil
+}
+
+// signatureBase builds the RFC 9421 signature base for components and the serialized signature parameters.
+func signatureBase(req *http.Request, components []string, signatureParams string) (string, error) {
+	builder := strings.Builder{}
+
+	for _, component := range components {
+		val, err := componentValue(req, component)
+		if err != nil {
+			return "", err
+		}
+
+		builder.WriteString(`"` + component + `": ` + val + "\n")
+	}
+
+	builder.WriteString(`"@signature-params": ` + signatureParams)
+
+	return builder.String(), nil
+}
+
+var (
+	errInvalidEd25519PrivateKey = errors.New("thttp: ed25519 requires a 64-byte private key")
+	errInvalidEcdsaPrivateKey   = errors.New("thttp: ecdsa-p256-sha256 requires a P-256 private key")
+)
+
+// validEcdsaPublicKey reports whether key is a complete P-256 public key.
+func validEcdsaPublicKey(key *ecdsa.PublicKey) bool {
+	return key != nil && key.Curve == elliptic.P256() && key.X != nil && key.Y != nil
+}
+
+// signWithKey signs base with key according to algorithm.
+func signWithKey(algorithm string, key interface{}, base string) ([]byte, error) {
+	switch algorithm {
+	case MessageSignatureHmacSha256:
+		secret, ok := key.([]byte)
+		if ok == false {
+			return nil, fmt.Errorf("thttp: invalid %s key: want []byte, got %T", algorithm, key)
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(base))
+
+		return mac.Sum(nil), nil
+	case MessageSignatureEd25519:
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if ok == false || len(privateKey) != ed25519.PrivateKeySize {
+			return nil, errInvalidEd25519PrivateKey
+		}
+
+		return ed25519.Sign(privateKey, []byte(base)), nil
+	case MessageSignatureEcdsaP256Sha256:
+		privateKey, ok := key.(*ecdsa.PrivateKey)
+		if ok == false || privateKey == nil || privateKey.Curve != elliptic.P256() {
+			return nil, errInvalidEcdsaPrivateKey
+		}
+
+		digest := sha256.Sum256([]byte(base))
+
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+		if err != nil {
+			return nil, err
+		}
+
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("thttp: unsupported signature algorithm %q", algorithm)
+	}
+}
+
+// verifyWithKey checks signature over base with key according to algorithm.
+func verifyWithKey(algorithm string, key interface{}, base string, signature []byte) bool {
+	switch algorithm {
+	case MessageSignatureHmacSha256:
+		secret, ok := key.([]byte)
+		if ok == false {
+			return false
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(base))
+
+		return hmac.Equal(mac.Sum(nil), signature)
+	case MessageSignatureEd25519:
+		publicKey, ok := key.(ed25519.PublicKey)
+		if ok == false || len(publicKey) != ed25519.PublicKeySize {
+			return false
+		}
+
+		return ed25519.Verify(publicKey, []byte(base), signature)
+	case MessageSignatureEcdsaP256Sha256:
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if ok == false || validEcdsaPublicKey(publicKey) == false || len(signature) != 64 {
+			return false
+		}
+
+		digest := sha256.Sum256([]byte(base))
+
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+
+		return ecdsa.Verify(publicKey, digest[:], r, s)
+	default:
+		return false
+	}
+}
+
+// MessageSigner signs requests with HTTP Message Signatures (RFC 9421), adding Signature-Input, Signature, and
+// (by default) Content-Digest (RFC 9530) headers. It implements [RequestSigner]; install it with
+// [HttpClient.WithRequestSigner] so every attempt is signed with a fresh created timestamp.
+type MessageSigner struct {
+	keyId     string
+	algorithm string
+	key       interface{}
+
+	label      string
+	components []string
+
+	contentDigest bool
+	expires       time.Duration
+	tag           string
+
+	now func() time.Time
+}
+
+// newMessageSigner returns a signer with the default label, components, and Content-Digest enabled.
+func newMessageSigner(keyId string, algorithm string, key interface{}) *MessageSigner {
+	return &MessageSigner{
+		keyId:     keyId,
+		algorithm: algorithm,
+		key:       key,
+
+		label: DefaultMessageSignatureLabel,
+
+		contentDigest: true,
+
+		now: time.Now,
+	}
+}
+
+// NewHmacMessageSigner returns an hmac-sha256 signer using a shared secret.
+func NewHmacMessageSigner(keyId string, secret []byte) *MessageSigner {
+	return newMessageSigner(keyId, MessageSignatureHmacSha256, secret)
+}
+
+// NewEd25519MessageSigner returns an ed25519 signer; key must be a 64-byte private key.
+func NewEd25519MessageSigner(keyId string, key ed25519.PrivateKey) (*MessageSigner, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errInvalidEd25519PrivateKey
+	}
+
+	return newMessageSigner(keyId, MessageSignatureEd25519, key), nil
+}
+
+// NewEcdsaMessageSigner returns an ecdsa-p256-sha256 signer; key must be on the P-256 curve.
+func NewEcdsaMessageSigner(keyId string, key *ecdsa.PrivateKey) (*MessageSigner, error) {
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, errInvalidEcdsaPrivateKey
+	}
+
+	return newMessageSigner(keyId, MessageSignatureEcdsaP256Sha256, key), nil
+}
+
+// WithLabel sets the signature label used in Signature-Input and Signature (default "sig1").
+func (p *MessageSigner) WithLabel(label string) *MessageSigner {
+	p.label = label
+
+	return p
+}
+
+// WithComponents sets the covered components, in order: derived components such as "@method", "@target-uri",
+// "@authority", "@scheme", "@request-target", "@path", and "@query", and lowercase header names such as
+// "content-digest", "content-type", or "date". Covered headers must be present on the request.
+func (p *MessageSigner) WithComponents(components ...string) *MessageSigner {
+	p.components = components
+
+	return p
+}
+
+// WithContentDigest controls whether a Content-Digest header is added for the request body (default true).
+func (p *MessageSigner) WithContentDigest(contentDigest bool) *MessageSigner {
+	p.contentDigest = contentDigest
+
+	return p
+}
+
+// WithExpires adds an expires parameter this long after the created time; 0 omits it.
+func (p *MessageSigner) WithExpires(expires time.Duration) *MessageSigner {
+	p.expires = expires
+
+	return p
+}
+
+// WithTag sets the application-specific tag parameter; empty omits it.
+func (p *MessageSigner) WithTag(tag string) *MessageSigner {
+	p.tag = tag
+
+	return p
+}
+
+// SignRequest implements [RequestSigner].
+func (p *MessageSigner) SignRequest(req *http.Request) error {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+
+	if p.contentDigest && hasBody {
+		body, err := readRequestBody(req)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Digest", contentDigest(body))
+	}
+
+	components := p.components
+	if components == nil {
+		components = append([]string(nil), defaultMessageSignatureComponents...)
+
+		if p.contentDigest && hasBody {
+			components = append(components, "content-digest")
+		}
+
+		if hasBody && req.Header.Get("Content-Type") != "" {
+			components = append(components, "content-type")
+		}
+	}
+
+	lowered := make([]string, 0, len(components))
+	quoted := make([]string, 0, len(components))
+	for _, component := range components {
+		lowered = append(lowered, strings.ToLower(component))
+		quoted = append(quoted, strconv.Quote(strings.ToLower(component)))
+	}
+
+	created := p.now().Unix()
+
+	signatureParams := "(" + strings.Join(quoted, " ") + ");created=" + strconv.FormatInt(created, 10)
+
+	if p.expires > 0 {
+		signatureParams += ";expires=" + strconv.FormatInt(created+int64(p.expires/time.Second), 10)
+	}
+
+	signatureParams += ";keyid=" + strconv.Quote(p.keyId) + ";alg=" + strconv.Quote(p.algorithm)
+
+	if p.tag != "" {
+		signatureParams += ";tag=" + strconv.Quote(p.tag)
+	}
+
+	base, err := signatureBase(req, lowered, signatureParams)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signWithKey(p.algorithm, p.key, base)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature-Input", p.label+"="+signatureParams)
+	req.Header.Set("Signature", p.label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+
+	return nil
+}
+
+// verificationKey is a key registered with a [MessageVerifier].
+type verificationKey struct {
+	algorithm string
+	key       interface{}
+}
+
+// MessageVerifier verifies HTTP Message Signatures (RFC 9421) produced by [MessageSigner] or compatible peers,
+// for example in a server handler or a test.
+type MessageVerifier struct {
+	keys map[string]*verificationKey
+
+	label              string
+	requiredComponents []string
+
+	maxAge    time.Duration
+	clockSkew time.Duration
+
+	now func() time.Time
+
+	err error
+}
+
+// NewMessageVerifier returns a verifier with no keys that checks the first signature on a request.
+func NewMessageVerifier() *MessageVerifier {
+	return &MessageVerifier{
+		keys: make(map[string]*verificationKey),
+
+		clockSkew: DefaultMessageSignatureClockSkew,
+
+		now: time.Now,
+	}
+}
+
+// WithHmacKey registers a shared secret for keyId.
+func (p *MessageVerifier) WithHmacKey(keyId string, secret []byte) *MessageVerifier {
+	p.keys[keyId] = &verificationKey{algorithm: MessageSignatureHmacSha256, key: secret}
+
+	return p
+}
+
+// WithEd25519Key registers an ed25519 public key for keyId. A key of the wrong length makes
+// [MessageVerifier.VerifyRequest] fail.
+func (p *MessageVerifier) WithEd25519Key(keyId string, key ed25519.PublicKey) *MessageVerifier {
+	if len(key) != ed25519.PublicKeySize {
+		p.err = fmt.Errorf("thttp: invalid ed25519 key for keyid %q: want %d bytes, got %d", keyId,
+			ed25519.PublicKeySize, len(key))
+
+		return p
+	}
+
+	p.keys[keyId] = &verificationKey{algorithm: MessageSignatureEd25519, key: key}
+
+	return p
+}
+
+// WithEcdsaKey registers a P-256 public key for keyId. A nil key or one on another curve makes
+// [MessageVerifier.VerifyRequest] fail.
+func (p *MessageVerifier) WithEcdsaKey(keyId string, key *ecdsa.PublicKey) *MessageVerifier {
+	if validEcdsaPublicKey(key) == false {
+		p.err = fmt.Errorf("thttp: invalid ecdsa key for keyid %q: want a P-256 public key", keyId)
+
+		return p
+	}
+
+	p.keys[keyId] = &verificationKey{algorithm: MessageSignatureEcdsaP256Sha256, key: key}
+
+	return p
+}
+
+// WithLabel verifies the signature with this label instead of the first one.
+func (p *MessageVerifier) WithLabel(label string) *MessageVerifier {
+	p.label = label
+
+	return p
+}
+
+// WithRequiredComponents rejects signatures that do not cover all of components.
+func (p *MessageVerifier) WithRequiredComponents(components ...string) *MessageVerifier {
+	p.requiredComponents = components
+
+	return p
+}
+
+// WithMaxAge rejects signatures created longer than maxAge ago; 0 disables the check (expires is always honored).
+func (p *MessageVerifier) WithMaxAge(maxAge time.Duration) *MessageVerifier {
+	p.maxAge = maxAge
+
+	return p
+}
+
+// VerifyRequest checks the signature of req. When content-digest is covered, the Content-Digest header is also
+// checked against the body, which is read and restored. It fails without checking req when an invalid key was
+// registered.
+func (p *MessageVerifier) VerifyRequest(req *http.Request) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	inputs, err := parseSignatureInput(req.Header.Get("Signature-Input"))
+	if err != nil {
+		return err
+	}
+
+	var input *signatureInput
+	for _, candidate := range inputs {
+		if p.label == "" || candidate.label == p.label {
+			input = candidate
+
+			break
+		}
+	}
+
+	if input == nil {
+		return errors.New("thttp: no matching Signature-Input")
+	}
+
+	var signature []byte
+	for _, member := range splitSfList(req.Header.Get("Signature")) {
+		label, value, ok := strings.Cut(member, "=")
+		if ok == true && strings.TrimSpace(label) == input.label {
+			signature, err = parseSfBinary(value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if signature == nil {
+		return fmt.Errorf("thttp: no Signature for label %q", input.label)
+	}
+
+	key, ok := p.keys[input.params["keyid"]]
+	if ok == false {
+		return fmt.Errorf("thttp: unknown signature keyid %q", input.params["keyid"])
+	}
+
+	alg := input.params["alg"]
+	if alg != "" && alg != key.algorithm {
+		return fmt.Errorf("thttp: signature alg %q does not match the key", alg)
+	}
+
+	now := p.now()
+
+	created, err := strconv.ParseInt(input.params["created"], 10, 64)
+	if err == nil {
+		createdAt := time.Unix(created, 0)
+
+		if createdAt.After(now.Add(p.clockSkew)) {
+			return errors.New("thttp: signature created in the future")
+		}
+
+		if p.maxAge > 0 && now.Sub(createdAt) > p.maxAge+p.clockSkew {
+			return errors.New("thttp: signature is too old")
+		}
+	} else if p.maxAge > 0 {
+		return errors.New("thttp: signature has no created parameter")
+	}
+
+	expires, err := strconv.ParseInt(input.params["expires"], 10, 64)
+	if err == nil && now.After(time.Unix(expires, 0).Add(p.clockSkew)) {
+		return errors.New("thttp: signature expired")
+	}
+
+	for _, required := range p.requiredComponents {
+		covered := false
+		for _, component := range input.components {
+			if component == strings.ToLower(required) {
+				covered = true
+			}
+		}
+
+		if covered == false {
+			return fmt.Errorf("thttp: signature does not cover %q", required)
+		}
+	}
+
+	base, err := signatureBase(req, input.components, input.raw)
+	if err != nil {
+		return err
+	}
+
+	if verifyWithKey(key.algorithm, key.key, base, signature) == false {
+		return errors.New("thttp: signature mismatch")
+	}
+
+	for _, component := range input.components {
+		if component == "content-digest" {
+			body, err := readRequestBody(req)
+			if err != nil {
+				return err
+			}
+
+			return verifyContentDigest(req.Header.Get("Content-Digest"), body)
+		}
+	}
+
+	return nil
+}
+
+// HmacBodySigner signs the request body with HMAC-SHA256 for webhook-style schemes, writing
+// prefix + hex(HMAC(secret, payload)) to a header. With a timestamp header the payload is timestamp + "." + body
+// and the timestamp (Unix seconds) is sent too, so verifiers can reject replays. It implements [RequestSigner] and
+// verifies with [HmacBodySigner.VerifyRequest].
+type HmacBodySigner struct {
+	secret []byte
+
+	header          string
+	prefix          string
+	timestampHeader string
+
+	maxAge time.Duration
+
+	newHash func() hash.Hash
+	now     func() time.Time
+}
+
+// NewHmacBodySigner returns a signer writing "X-Signature: sha256=<hex>" without a timestamp.
+func NewHmacBodySigner(secret []byte) *HmacBodySigner {
+	return &HmacBodySigner{
+		secret: secret,
+
+		header: "X-Signature",
+		prefix: "sha256=",
+
+		maxAge: 5 * time.Minute,
+
+		newHash: sha256.New,
+		now:     time.Now,
+	}
+}
+
+// WithHeader sets the signature header name and value prefix, such as "X-Hub-Signature-256" and "sha256=".
+func (p *HmacBodySigner) WithHeader(header string, prefix string) *HmacBodySigner {
+	p.header = header
+	p.prefix = prefix
+
+	return p
+}
+
+// WithTimestampHeader includes a Unix timestamp, sent in header, in the signed payload; empty disables it.
+func (p *HmacBodySigner) WithTimestampHeader(header string) *HmacBodySigner {
+	p.timestampHeader = header
+
+	return p
+}
+
+// WithMaxAge sets how old a timestamp [HmacBodySigner.VerifyRequest] accepts (default 5 minutes).
+func (p *HmacBodySigner) WithMaxAge(maxAge time.Duration) *HmacBodySigner {
+	p.maxAge = maxAge
+
+	return p
+}
+
+// mac computes the signature of body with timestamp.
+func (p *HmacBodySigner) mac(timestamp string, body []byte) []byte {
+	mac := hmac.New(p.newHash, p.secret)
+
+	if timestamp != "" {
+		mac.Write([]byte(timestamp + "."))
+	}
+
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
+
+// SignRequest implements [RequestSigner].
+func (p *HmacBodySigner) SignRequest(req *http.Request) error {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	timestamp := ""
+	if p.timestampHeader != "" {
+		timestamp = strconv.FormatInt(p.now().Unix(), 10)
+
+		req.Header.Set(p.timestampHeader, timestamp)
+	}
+
+	req.Header.Set(p.header, p.prefix+hex.EncodeToString(p.mac(timestamp, body)))
+
+	return nil
+}
+
+// VerifyRequest checks the signature header (and timestamp age, when configured) of req; the body is read and
+// restored.
+func (p *HmacBodySigner) VerifyRequest(req *http.Request) error {
+	value, ok := strings.CutPrefix(req.Header.Get(p.header), p.prefix)
+	if ok == false || value == "" {
+		return fmt.Errorf("thttp: missing or malformed %s header", p.header)
+	}
+
+	signature, err := hex.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("thttp: malformed %s header: %w", p.header, err)
+	}
+
+	timestamp := ""
+	if p.timestampHeader != "" {
+		timestamp = req.Header.Get(p.timestampHeader)
+
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("thttp: missing or malformed %s header", p.timestampHeader)
+		}
+
+		age := p.now().Sub(time.Unix(seconds, 0))
+		if p.maxAge > 0 && (age > p.maxAge || age < -DefaultMessageSignatureClockSkew) {
+			return errors.New("thttp: signature timestamp outside the accepted window")
+		}
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	if hmac.Equal(p.mac(timestamp, body), signature) == false {
+		return errors.New("thttp: signature mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,402 @@
+package thttp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newVerifyingServer returns a server answering 204 to requests verify accepts and 401 with the error otherwise.
+func newVerifyingServer(t *testing.T, verify func(req *http.Request) error) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := verify(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(err.Error()))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// replaceTestBody replaces the body of req after it was signed.
+func replaceTestBody(req *http.Request, body string) {
+	req.Body = io.NopCloser(strings.NewReader(body))
+	req.GetBody = nil
+}
+
+func TestMessageSignerRoundTrip(t *testing.T) {
+	ed25519Public, ed25519Private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ed25519Signer, err := NewEd25519MessageSigner("ed25519-key", ed25519Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecdsaSigner, err := NewEcdsaMessageSigner("ecdsa-key", ecdsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("shared-secret")
+
+	verifier := NewMessageVerifier().
+		WithHmacKey("hmac-key", secret).
+		WithEd25519Key("ed25519-key", ed25519Public).
+		WithEcdsaKey("ecdsa-key", &ecdsaKey.PublicKey).
+		WithRequiredComponents("@method", "@authority", "@path", "content-digest").
+		WithMaxAge(time.Minute)
+
+	server := newVerifyingServer(t, verifier.VerifyRequest)
+
+	signers := map[string]*MessageSigner{
+		"hmac-sha256":       NewHmacMessageSigner("hmac-key", secret),
+		"ed25519":           ed25519Signer,
+		"ecdsa-p256-sha256": ecdsaSigner,
+	}
+
+	for name, signer := range signers {
+		t.Run(name, func(t *testing.T) {
+			client := NewHttpClient().WithRequestSigner(signer)
+
+			resp, err := client.PostJson(context.Background(), server.URL+"/orders?id=7", nil, map[string]string{"item": "book"})
+			if err != nil {
+				t.Fatalf("PostJson: %v", err)
+			}
+
+			statusCode, body, err := resp.ToBytes()
+			if err != nil || statusCode != http.StatusNoContent {
+				t.Errorf("status = %d %q %v, want 204", statusCode, body, err)
+			}
+		})
+	}
+}
+
+func TestMessageVerifierRejectsTampering(t *testing.T) {
+	secret := []byte("shared-secret")
+
+	newSignedRequest := func(t *testing.T) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/orders?id=7", strings.NewReader(`{"item":"book"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", ContentTypeApplicationJson)
+
+		err = NewHmacMessageSigner("hmac-key", secret).WithExpires(time.Minute).SignRequest(req)
+		if err != nil {
+			t.Fatalf("SignRequest: %v", err)
+		}
+
+		return req
+	}
+
+	verifier := NewMessageVerifier().WithHmacKey("hmac-key", secret)
+
+	err := verifier.VerifyRequest(newSignedRequest(t))
+	if err != nil {
+		t.Fatalf("VerifyRequest of an untouched request: %v", err)
+	}
+
+	tests := map[string]struct {
+		tamper  func(req *http.Request)
+		wantErr string
+	}{
+		"method": {
+			tamper:  func(req *http.Request) { req.Method = http.MethodPut },
+			wantErr: "signature mismatch",
+		},
+		"query": {
+			tamper:  func(req *http.Request) { req.URL.RawQuery = "id=8" },
+			wantErr: "signature mismatch",
+		},
+		"content type": {
+			tamper:  func(req *http.Request) { req.Header.Set("Content-Type", "text/plain") },
+			wantErr: "signature mismatch",
+		},
+		"body": {
+			tamper:  func(req *http.Request) { replaceTestBody(req, `{"item":"gold"}`) },
+			wantErr: "Content-Digest",
+		},
+		"signature": {
+			tamper: func(req *http.Request) {
+				req.Header.Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(make([]byte, 32))+":")
+			},
+			wantErr: "signature mismatch",
+		},
+		"key id": {
+			tamper: func(req *http.Request) {
+				req.Header.Set("Signature-Input", strings.Replace(req.Header.Get("Signature-Input"), "hmac-key", "other-key", 1))
+			},
+			wantErr: "unknown signature keyid",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := newSignedRequest(t)
+			test.tamper(req)
+
+			err := verifier.VerifyRequest(req)
+			if err == nil || strings.Contains(err.Error(), test.wantErr) == false {
+				t.Errorf("VerifyRequest = %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageVerifierRejectsExpired(t *testing.T) {
+	secret := []byte("shared-secret")
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := NewHmacMessageSigner("hmac-key", secret).WithExpires(time.Minute)
+	signer.now = func() time.Time { return time.Now().Add(-time.Hour) }
+
+	err = signer.SignRequest(req)
+	if err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+
+	err = NewMessageVerifier().WithHmacKey("hmac-key", secret).VerifyRequest(req)
+	if err == nil || strings.Contains(err.Error(), "expired") == false {
+		t.Errorf("VerifyRequest = %v, want an expired signature", err)
+	}
+}
+
+func TestMessageSignerInvalidKeys(t *testing.T) {
+	_, err := NewEd25519MessageSigner("ed25519-key", ed25519.PrivateKey("short"))
+	if err == nil {
+		t.Errorf("NewEd25519MessageSigner with a short key succeeded")
+	}
+
+	_, err = NewEcdsaMessageSigner("ecdsa-key", nil)
+	if err == nil {
+		t.Errorf("NewEcdsaMessageSigner with a nil key succeeded")
+	}
+
+	// keys set directly bypass the constructors and must fail instead of panicking
+	signers := map[string]*MessageSigner{
+		"nil ed25519":   newMessageSigner("key", MessageSignatureEd25519, nil),
+		"short ed25519": newMessageSigner("key", MessageSignatureEd25519, ed25519.PrivateKey("short")),
+		"nil ecdsa":     newMessageSigner("key", MessageSignatureEcdsaP256Sha256, (*ecdsa.PrivateKey)(nil)),
+		"hmac string":   newMessageSigner("key", MessageSignatureHmacSha256, "secret"),
+	}
+
+	for name, signer := range signers {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = signer.SignRequest(req)
+			if err == nil {
+				t.Errorf("SignRequest succeeded, want a key error")
+			}
+		})
+	}
+}
+
+func TestMessageVerifierInvalidKeys(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("shared-secret")
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewHmacMessageSigner("hmac-key", secret).SignRequest(req)
+	if err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+
+	verifiers := map[string]*MessageVerifier{
+		"short ed25519": NewMessageVerifier().WithEd25519Key("ed25519-key", ed25519.PublicKey("short")),
+		"nil ecdsa":     NewMessageVerifier().WithEcdsaKey("ecdsa-key", nil),
+		"p-384 ecdsa":   NewMessageVerifier().WithEcdsaKey("ecdsa-key", &ecdsaKey.PublicKey),
+	}
+
+	for name, verifier := range verifiers {
+		t.Run(name, func(t *testing.T) {
+			// the invalid key is reported even when the request is signed with another, valid key
+			err := verifier.WithHmacKey("hmac-key", secret).VerifyRequest(req)
+			if err == nil || strings.Contains(err.Error(), "invalid") == false {
+				t.Errorf("VerifyRequest = %v, want the key error", err)
+			}
+		})
+	}
+
+	keys := map[string]*verificationKey{
+		"nil ed25519":    {algorithm: MessageSignatureEd25519},
+		"short ed25519":  {algorithm: MessageSignatureEd25519, key: ed25519.PublicKey("short")},
+		"nil ecdsa":      {algorithm: MessageSignatureEcdsaP256Sha256, key: (*ecdsa.PublicKey)(nil)},
+		"empty ecdsa":    {algorithm: MessageSignatureEcdsaP256Sha256, key: &ecdsa.PublicKey{Curve: elliptic.P256()}},
+		"hmac non-bytes": {algorithm: MessageSignatureHmacSha256, key: "secret"},
+	}
+
+	for name, key := range keys {
+		t.Run(name, func(t *testing.T) {
+			if verifyWithKey(key.algorithm, key.key, "base", make([]byte, 64)) == true {
+				t.Errorf("verifyWithKey accepted an invalid key")
+			}
+		})
+	}
+}
+
+// TestMessageVerifierRfc9421Vector verifies the hmac-sha256 example of RFC 9421 appendix B.2.5.
+func TestMessageVerifierRfc9421Vector(t *testing.T) {
+	secret, err := base64.StdEncoding.DecodeString("uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/foo?param=Value&Pet=dog", strings.NewReader(`{"hello": "world"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Digest", "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+	req.Header.Set("Signature-Input", `sig-b25=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`)
+	req.Header.Set("Signature", "sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:")
+
+	verifier := NewMessageVerifier().WithHmacKey("test-shared-secret", secret)
+	verifier.now = func() time.Time { return time.Unix(1618884473, 0) }
+
+	err = verifier.VerifyRequest(req)
+	if err != nil {
+		t.Errorf("VerifyRequest: %v", err)
+	}
+
+	req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:56 GMT")
+
+	err = verifier.VerifyRequest(req)
+	if err == nil {
+		t.Errorf("VerifyRequest accepted a modified Date header")
+	}
+}
+
+func TestHmacBodySignerRoundTrip(t *testing.T) {
+	signer := NewHmacBodySigner([]byte("webhook-secret")).
+		WithHeader("X-Hub-Signature-256", "sha256=").
+		WithTimestampHeader("X-Timestamp")
+
+	server := newVerifyingServer(t, signer.VerifyRequest)
+
+	client := NewHttpClient().WithRequestSigner(signer)
+
+	resp, err := client.PostJson(context.Background(), server.URL, nil, map[string]string{"event": "push"})
+	if err != nil {
+		t.Fatalf("PostJson: %v", err)
+	}
+
+	statusCode, body, err := resp.ToBytes()
+	if err != nil || statusCode != http.StatusNoContent {
+		t.Errorf("status = %d %q %v, want 204", statusCode, body, err)
+	}
+}
+
+func TestHmacBodySignerRejects(t *testing.T) {
+	secret := []byte("webhook-secret")
+
+	newSignedRequest := func(t *testing.T, signedAt time.Time) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/hook", strings.NewReader(`{"event":"push"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signer := NewHmacBodySigner(secret).WithTimestampHeader("X-Timestamp")
+		signer.now = func() time.Time { return signedAt }
+
+		err = signer.SignRequest(req)
+		if err != nil {
+			t.Fatalf("SignRequest: %v", err)
+		}
+
+		return req
+	}
+
+	verifier := NewHmacBodySigner(secret).WithTimestampHeader("X-Timestamp")
+
+	req := newSignedRequest(t, time.Now())
+	replaceTestBody(req, `{"event":"delete"}`)
+
+	err := verifier.VerifyRequest(req)
+	if err == nil || strings.Contains(err.Error(), "signature mismatch") == false {
+		t.Errorf("VerifyRequest of a modified body = %v, want a mismatch", err)
+	}
+
+	req = newSignedRequest(t, time.Now())
+	req.Header.Set("X-Timestamp", req.Header.Get("X-Timestamp")+"0")
+
+	err = verifier.VerifyRequest(req)
+	if err == nil {
+		t.Errorf("VerifyRequest accepted a modified timestamp")
+	}
+
+	err = verifier.VerifyRequest(newSignedRequest(t, time.Now().Add(-time.Hour)))
+	if err == nil || strings.Contains(err.Error(), "accepted window") == false {
+		t.Errorf("VerifyRequest of a stale request = %v, want a timestamp error", err)
+	}
+
+	err = NewHmacBodySigner([]byte("other-secret")).WithTimestampHeader("X-Timestamp").
+		VerifyRequest(newSignedRequest(t, time.Now()))
+	if err == nil {
+		t.Errorf("VerifyRequest accepted a signature made with another secret")
+	}
+}
+
+// TestHmacBodySignerGithubVector checks the webhook signature example from the GitHub documentation.
+func TestHmacBodySignerGithubVector(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/hook", strings.NewReader("Hello, World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := NewHmacBodySigner([]byte("It's a Secret to Everybody")).WithHeader("X-Hub-Signature-256", "sha256=")
+
+	err = signer.SignRequest(req)
+	if err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+
+	want := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if req.Header.Get("X-Hub-Signature-256") != want {
+		t.Errorf("signature = %q, want %q", req.Header.Get("X-Hub-Signature-256"), want)
+	}
+}
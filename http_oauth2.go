@@ -0,0 +1,458 @@
+package thttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	_url "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTokenExpiryDelta is how long before its expiry a cached token is considered expired and refreshed.
+	DefaultTokenExpiryDelta = 10 * time.Second
+)
+
+// Token is an OAuth2 access token.
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+
+	// Expiry is when the access token expires; the zero value means it does not expire.
+	Expiry time.Time
+}
+
+// Type returns the token type for the Authorization header, defaulting to "Bearer".
+func (p *Token) Type() string {
+	if p.TokenType == "" || strings.EqualFold(p.TokenType, "bearer") {
+		return "Bearer"
+	}
+
+	return p.TokenType
+}
+
+// valid reports whether the token is usable for at least expiryDelta more.
+func (p *Token) valid(expiryDelta time.Duration) bool {
+	if p == nil || p.AccessToken == "" {
+		return false
+	}
+
+	return p.Expiry.IsZero() || time.Now().Add(expiryDelta).Before(p.Expiry)
+}
+
+// TokenSource returns OAuth2 access tokens. Implementations must be safe for concurrent use.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// OAuth2Error is an error response from a token endpoint (RFC 6749 section 5.2).
+type OAuth2Error struct {
+	StatusCode int
+
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	Uri         string `json:"error_uri"`
+}
+
+// Error implements [error].
+func (p *OAuth2Error) Error() string {
+	if p.Description == "" {
+		return fmt.Sprintf("thttp: OAuth2 token request failed (status %d): %s", p.StatusCode, p.Code)
+	}
+
+	return fmt.Sprintf("thttp: OAuth2 token request failed (status %d): %s: %s", p.StatusCode, p.Code, p.Description)
+}
+
+// tokenResponse is the wire form of a token endpoint response; expires_in may be a number or a numeric string.
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    interface{} `json:"expires_in"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	ErrorUri         string `json:"error_uri"`
+}
+
+// tokenEndpoint holds the settings shared by the built-in grant flows.
+type tokenEndpoint struct {
+	client   *HttpClient
+	tokenUrl string
+
+	clientId     string
+	clientSecret string
+
+	scopes         []string
+	endpointParams _url.Values
+	authInBody     bool
+}
+
+// retrieveToken posts params to the token endpoint and parses the token response.
+func (p *tokenEndpoint) retrieveToken(ctx context.Context, params _url.Values) (*Token, error) {
+	if len(p.scopes) > 0 {
+		params.Set("scope", strings.Join(p.scopes, " "))
+	}
+
+	for key, vals := range p.endpointParams {
+		params[key] = append([]string(nil), vals...)
+	}
+
+	requestOption := NewRequestOption().WithHeader("Accept", ContentTypeApplicationJson)
+
+	if p.authInBody == true {
+		params.Set("client_id", p.clientId)
+
+		if p.clientSecret != "" {
+			params.Set("client_secret", p.clientSecret)
+		}
+
+		// never let a client-wide authenticator answer for the token request
+		requestOption.WithAuthenticator(nil)
+	} else {
+		requestOption.WithBasicAuth(_url.QueryEscape(p.clientId), _url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := p.client.PostForm(ctx, p.tokenUrl, requestOption, params)
+	if err != nil {
+		if resp != nil && resp.Response != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	tokenResp := &tokenResponse{}
+
+	statusCode, err := resp.ToJson(tokenResp, nil)
+	if err != nil {
+		if statusCode >= 400 {
+			return nil, &OAuth2Error{StatusCode: statusCode, Code: http.StatusText(statusCode)}
+		}
+
+		return nil, err
+	}
+
+	if tokenResp.Error != "" || statusCode >= 400 {
+		return nil, &OAuth2Error{
+			StatusCode: statusCode,
+
+			Code:        tokenResp.Error,
+			Description: tokenResp.ErrorDescription,
+			Uri:         tokenResp.ErrorUri,
+		}
+	}
+
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("thttp: OAuth2 token response (status %d) has no access_token", statusCode)
+	}
+
+	token := &Token{
+		AccessToken:  tokenResp.AccessToken,
+		TokenType:    tokenResp.TokenType,
+		RefreshToken: tokenResp.RefreshToken,
+	}
+
+	var expiresIn float64
+
+	switch retExpiresIn := tokenResp.ExpiresIn.(type) {
+	case float64:
+		expiresIn = retExpiresIn
+	case string:
+		expiresIn, _ = strconv.ParseFloat(retExpiresIn, 64)
+	}
+
+	if expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn * float64(time.Second)))
+	}
+
+	return token, nil
+}
+
+// ClientCredentialsTokenSource obtains tokens with the OAuth2 client credentials grant (RFC 6749 section 4.4).
+// It requests a new token on every call; wrap it with [NewCachedTokenSource] or install it with
+// [HttpClient.WithTokenSource], which caches.
+type ClientCredentialsTokenSource struct {
+	tokenEndpoint
+}
+
+// NewClientCredentialsTokenSource returns a client credentials source for the token endpoint at tokenUrl. Token
+// requests are sent with client, which must not itself authenticate with this source; a nil client uses the package
+// default client. Client credentials are sent with HTTP Basic unless [ClientCredentialsTokenSource.WithAuthInBody].
+func NewClientCredentialsTokenSource(client *HttpClient, tokenUrl string, clientId string, clientSecret string) *ClientCredentialsTokenSource {
+	if client == nil {
+		client = defaultClient
+	}
+
+	return &ClientCredentialsTokenSource{
+		tokenEndpoint: tokenEndpoint{
+			client:   client,
+			tokenUrl: tokenUrl,
+
+			clientId:     clientId,
+			clientSecret: clientSecret,
+		},
+	}
+}
+
+// WithScopes sets the requested scopes.
+func (p *ClientCredentialsTokenSource) WithScopes(scopes ...string) *ClientCredentialsTokenSource {
+	p.scopes = scopes
+
+	return p
+}
+
+// WithEndpointParams adds extra form parameters, such as audience or resource, to token requests.
+func (p *ClientCredentialsTokenSource) WithEndpointParams(params _url.Values) *ClientCredentialsTokenSource {
+	p.endpointParams = params
+
+	return p
+}
+
+// WithAuthInBody sends client_id and client_secret as form parameters instead of HTTP Basic credentials.
+func (p *ClientCredentialsTokenSource) WithAuthInBody(authInBody bool) *ClientCredentialsTokenSource {
+	p.authInBody = authInBody
+
+	return p
+}
+
+// Token implements [TokenSource].
+func (p *ClientCredentialsTokenSource) Token(ctx context.Context) (*Token, error) {
+	params := _url.Values{}
+	params.Set("grant_type", "client_credentials")
+
+	return p.retrieveToken(ctx, params)
+}
+
+// RefreshTokenSource obtains access tokens with the OAuth2 refresh token grant (RFC 6749 section 6). When the
+// server rotates the refresh token, the new one is used for later requests. Like [ClientCredentialsTokenSource], it
+// does not cache.
+type RefreshTokenSource struct {
+	tokenEndpoint
+
+	refreshToken string
+
+	sync.Mutex
+}
+
+// NewRefreshTokenSource returns a refresh token source for the token endpoint at tokenUrl. See
+// [NewClientCredentialsTokenSource] for client and credential handling; clientSecret may be empty for public clients.
+func NewRefreshTokenSource(client *HttpClient, tokenUrl string, clientId string, clientSecret string, refreshToken string) *RefreshTokenSource {
+	if client == nil {
+		client = defaultClient
+	}
+
+	return &RefreshTokenSource{
+		tokenEndpoint: tokenEndpoint{
+			client:   client,
+			tokenUrl: tokenUrl,
+
+			clientId:     clientId,
+			clientSecret: clientSecret,
+		},
+
+		refreshToken: refreshToken,
+	}
+}
+
+// WithScopes sets the requested scopes, which must not exceed those originally granted.
+func (p *RefreshTokenSource) WithScopes(scopes ...string) *RefreshTokenSource {
+	p.scopes = scopes
+
+	return p
+}
+
+// WithEndpointParams adds extra form parameters to token requests.
+func (p *RefreshTokenSource) WithEndpointParams(params _url.Values) *RefreshTokenSource {
+	p.endpointParams = params
+
+	return p
+}
+
+// WithAuthInBody sends client_id and client_secret as form parameters instead of HTTP Basic credentials.
+func (p *RefreshTokenSource) WithAuthInBody(authInBody bool) *RefreshTokenSource {
+	p.authInBody = authInBody
+
+	return p
+}
+
+// Token implements [TokenSource].
+func (p *RefreshTokenSource) Token(ctx context.Context) (*Token, error) {
+	p.Lock()
+	refreshToken := p.refreshToken
+	p.Unlock()
+
+	params := _url.Values{}
+	params.Set("grant_type", "refresh_token")
+	params.Set("refresh_token", refreshToken)
+
+	token, err := p.retrieveToken(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	} else {
+		p.Lock()
+		p.refreshToken = token.RefreshToken
+		p.Unlock()
+	}
+
+	return token, nil
+}
+
+// tokenFetch is one in-flight refresh shared by concurrent callers.
+type tokenFetch struct {
+	done chan struct{}
+
+	token *Token
+	err   error
+}
+
+// CachedTokenSource caches the token of another [TokenSource] until expiryDelta before it expires. Concurrent
+// callers that find no valid token share a single refresh, which is not aborted when one caller's ctx is cancelled.
+type CachedTokenSource struct {
+	source      TokenSource
+	expiryDelta time.Duration
+
+	token *Token
+	fetch *tokenFetch
+
+	sync.Mutex
+}
+
+// NewCachedTokenSource returns a caching wrapper around source using [DefaultTokenExpiryDelta].
+func NewCachedTokenSource(source TokenSource) *CachedTokenSource {
+	return &CachedTokenSource{
+		source:      source,
+		expiryDelta: DefaultTokenExpiryDelta,
+	}
+}
+
+// WithExpiryDelta sets how long before expiry a cached token is refreshed.
+func (p *CachedTokenSource) WithExpiryDelta(expiryDelta time.Duration) *CachedTokenSource {
+	p.Lock()
+	defer p.Unlock()
+
+	p.expiryDelta = expiryDelta
+
+	return p
+}
+
+// Token implements [TokenSource].
+func (p *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	p.Lock()
+
+	if p.token.valid(p.expiryDelta) {
+		token := p.token
+		p.Unlock()
+
+		return token, nil
+	}
+
+	fetch := p.fetch
+	if fetch == nil {
+		fetch = &tokenFetch{done: make(chan struct{})}
+		p.fetch = fetch
+
+		go p.refresh(context.WithoutCancel(ctx), fetch)
+	}
+
+	p.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.token, fetch.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refresh runs fetch against the underlying source and publishes the result.
+func (p *CachedTokenSource) refresh(ctx context.Context, fetch *tokenFetch) {
+	token, err := p.source.Token(ctx)
+
+	p.Lock()
+
+	if err == nil {
+		p.token = token
+	}
+
+	p.fetch = nil
+
+	p.Unlock()
+
+	fetch.token = token
+	fetch.err = err
+
+	close(fetch.done)
+}
+
+// Invalidate drops the cached token if its access token is accessToken, forcing the next call to refresh. Tokens
+// already replaced by a newer one are left alone.
+func (p *CachedTokenSource) Invalidate(accessToken string) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.token != nil && p.token.AccessToken == accessToken {
+		p.token = nil
+	}
+}
+
+// TokenAuth is an [Authenticator] sending tokens from a [TokenSource]. On HTTP 401 it invalidates the token that
+// was rejected and retries the request once with a freshly obtained token. Tokens are only sent to, and 401s only
+// honored from, the host of the original request (see [AllowRedirectCredentials]), so a redirect target can neither
+// read the token nor force a refresh.
+type TokenAuth struct {
+	source *CachedTokenSource
+}
+
+// NewTokenAuth returns an authenticator for source, wrapping it with [NewCachedTokenSource] unless it already is a
+// [*CachedTokenSource].
+func NewTokenAuth(source TokenSource) *TokenAuth {
+	cachedSource, ok := source.(*CachedTokenSource)
+	if ok == false {
+		cachedSource = NewCachedTokenSource(source)
+	}
+
+	return &TokenAuth{
+		source: cachedSource,
+	}
+}
+
+// Authenticate implements [Authenticator].
+func (p *TokenAuth) Authenticate(req *http.Request) error {
+	if inCredentialScope(req) == false {
+		return nil
+	}
+
+	token, err := p.source.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", token.Type()+" "+token.AccessToken)
+
+	return nil
+}
+
+// Challenge implements [AuthChallenger] by forcing a refresh of the rejected token.
+func (p *TokenAuth) Challenge(req *http.Request, resp *http.Response) (bool, error) {
+	if inCredentialScope(req) == false {
+		return false, nil
+	}
+
+	_, accessToken, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if ok == false {
+		return false, nil
+	}
+
+	p.source.Invalidate(accessToken)
+
+	return true, nil
+}
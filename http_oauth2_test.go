@@ -0,0 +1,349 @@
+package thttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTokenServer returns a token endpoint answering each request with handle.
+func newTokenServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("token request method = %s, want POST", r.Method)
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			t.Errorf("cannot parse token request: %v", err)
+		}
+
+		handle(w, r)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	server := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if ok == false || clientId != "client" || clientSecret != "s3cret" {
+			t.Errorf("basic auth = %q, %q, %v", clientId, clientSecret, ok)
+		}
+
+		if r.PostForm.Get("grant_type") != "client_credentials" {
+			t.Errorf("grant_type = %q", r.PostForm.Get("grant_type"))
+		}
+
+		if r.PostForm.Get("scope") != "read write" {
+			t.Errorf("scope = %q", r.PostForm.Get("scope"))
+		}
+
+		if r.PostForm.Get("audience") != "api" {
+			t.Errorf("audience = %q", r.PostForm.Get("audience"))
+		}
+
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+		_, _ = w.Write([]byte(`{"access_token":"abc","token_type":"bearer","expires_in":3600}`))
+	})
+
+	source := NewClientCredentialsTokenSource(NewHttpClient(), server.URL, "client", "s3cret").
+		WithScopes("read", "write").
+		WithEndpointParams(map[string][]string{"audience": {"api"}})
+
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	if token.AccessToken != "abc" || token.Type() != "Bearer" {
+		t.Errorf("token = %q %q, want Bearer abc", token.Type(), token.AccessToken)
+	}
+
+	remaining := time.Until(token.Expiry)
+	if remaining < 59*time.Minute || remaining > time.Hour {
+		t.Errorf("token expires in %s, want about 1h", remaining)
+	}
+}
+
+func TestClientCredentialsTokenSourceAuthInBody(t *testing.T) {
+	server := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("Authorization = %q, want none", r.Header.Get("Authorization"))
+		}
+
+		if r.PostForm.Get("client_id") != "client" || r.PostForm.Get("client_secret") != "s3cret" {
+			t.Errorf("client credentials = %q, %q", r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"))
+		}
+
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+		_, _ = w.Write([]byte(`{"access_token":"abc","expires_in":"60"}`))
+	})
+
+	source := NewClientCredentialsTokenSource(NewHttpClient(), server.URL, "client", "s3cret").WithAuthInBody(true)
+
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	if token.Expiry.IsZero() == true {
+		t.Errorf("string expires_in was ignored")
+	}
+}
+
+func TestClientCredentialsTokenSourceError(t *testing.T) {
+	server := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`))
+	})
+
+	source := NewClientCredentialsTokenSource(NewHttpClient(), server.URL, "client", "wrong")
+
+	_, err := source.Token(context.Background())
+
+	var oauth2Err *OAuth2Error
+	if errors.As(err, &oauth2Err) == false {
+		t.Fatalf("err = %v, want *OAuth2Error", err)
+	}
+
+	if oauth2Err.StatusCode != http.StatusUnauthorized || oauth2Err.Code != "invalid_client" ||
+		oauth2Err.Description != "unknown client" {
+		t.Errorf("err = %+v", oauth2Err)
+	}
+}
+
+func TestRefreshTokenSourceRotation(t *testing.T) {
+	var calls atomic.Int32
+
+	server := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		call := calls.Add(1)
+
+		if r.PostForm.Get("grant_type") != "refresh_token" {
+			t.Errorf("grant_type = %q", r.PostForm.Get("grant_type"))
+		}
+
+		want := fmt.Sprintf("refresh-%d", call)
+		if r.PostForm.Get("refresh_token") != want {
+			t.Errorf("call %d refresh_token = %q, want %q", call, r.PostForm.Get("refresh_token"), want)
+		}
+
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+
+		// the second response does not rotate the refresh token
+		if call == 1 {
+			_, _ = fmt.Fprintf(w, `{"access_token":"access-%d","refresh_token":"refresh-%d"}`, call, call+1)
+		} else {
+			_, _ = fmt.Fprintf(w, `{"access_token":"access-%d"}`, call)
+		}
+	})
+
+	source := NewRefreshTokenSource(NewHttpClient(), server.URL, "client", "", "refresh-1")
+
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-2" {
+		t.Errorf("first token = %+v", token)
+	}
+
+	token, err = source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	if token.AccessToken != "access-2" || token.RefreshToken != "refresh-2" {
+		t.Errorf("second token = %+v, want the previous refresh token kept", token)
+	}
+}
+
+// countingTokenSource issues "token-<n>" after delay, counting calls.
+type countingTokenSource struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+// Token implements [TokenSource].
+func (p *countingTokenSource) Token(ctx context.Context) (*Token, error) {
+	call := p.calls.Add(1)
+
+	time.Sleep(p.delay)
+
+	return &Token{
+		AccessToken: fmt.Sprintf("token-%d", call),
+		Expiry:      time.Now().Add(time.Hour),
+	}, nil
+}
+
+func TestCachedTokenSourceSingleFlight(t *testing.T) {
+	source := &countingTokenSource{delay: 50 * time.Millisecond}
+	cachedSource := NewCachedTokenSource(source)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			token, err := cachedSource.Token(context.Background())
+			if err != nil || token.AccessToken != "token-1" {
+				t.Errorf("Token = %v, %v, want token-1", token, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if source.calls.Load() != 1 {
+		t.Errorf("source called %d times, want 1", source.calls.Load())
+	}
+
+	// invalidating a token that was already replaced keeps the cached one
+	cachedSource.Invalidate("token-0")
+
+	_, _ = cachedSource.Token(context.Background())
+	if source.calls.Load() != 1 {
+		t.Errorf("source called %d times after a stale invalidation, want 1", source.calls.Load())
+	}
+
+	cachedSource.Invalidate("token-1")
+
+	token, err := cachedSource.Token(context.Background())
+	if err != nil || token.AccessToken != "token-2" {
+		t.Errorf("Token after invalidation = %v, %v, want token-2", token, err)
+	}
+}
+
+func TestCachedTokenSourceRefreshesExpiredToken(t *testing.T) {
+	source := &countingTokenSource{}
+	cachedSource := NewCachedTokenSource(source).WithExpiryDelta(2 * time.Hour)
+
+	for i := 1; i <= 2; i++ {
+		token, err := cachedSource.Token(context.Background())
+		if err != nil || token.AccessToken != fmt.Sprintf("token-%d", i) {
+			t.Errorf("Token = %v, %v, want token-%d", token, err, i)
+		}
+	}
+}
+
+func TestTokenAuthRetriesAfter401(t *testing.T) {
+	var tokenCalls atomic.Int32
+
+	tokenServer := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+		_, _ = fmt.Fprintf(w, `{"access_token":"access-%d","expires_in":3600}`, tokenCalls.Add(1))
+	})
+
+	var apiCalls atomic.Int32
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiCalls.Add(1)
+
+		// the first token is revoked server-side before it expires
+		if r.Header.Get("Authorization") != "Bearer access-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer apiServer.Close()
+
+	source := NewClientCredentialsTokenSource(NewHttpClient(), tokenServer.URL, "client", "s3cret")
+	client := NewHttpClient().WithTokenSource(source)
+
+	resp, err := client.Get(context.Background(), apiServer.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	statusCode, body, err := resp.ToBytes()
+	if err != nil || statusCode != http.StatusOK || string(body) != "ok" {
+		t.Fatalf("response = %d %q %v, want 200 ok", statusCode, body, err)
+	}
+
+	if tokenCalls.Load() != 2 || apiCalls.Load() != 2 {
+		t.Errorf("token calls = %d, api calls = %d, want 2 and 2", tokenCalls.Load(), apiCalls.Load())
+	}
+
+	// the refreshed token is cached for later requests
+	resp, err = client.Get(context.Background(), apiServer.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if tokenCalls.Load() != 2 || apiCalls.Load() != 3 {
+		t.Errorf("token calls = %d, api calls = %d, want 2 and 3", tokenCalls.Load(), apiCalls.Load())
+	}
+}
+
+func TestTokenAuthWithheldFromCrossHostRedirect(t *testing.T) {
+	var tokenCalls atomic.Int32
+
+	tokenServer := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		tokenCalls.Add(1)
+
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+		_, _ = w.Write([]byte(`{"access_token":"secret","expires_in":3600}`))
+	})
+
+	var leaked atomic.Bool
+
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			leaked.Store(true)
+		}
+
+		// a 401 from another host must not force a refresh
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer otherServer.Close()
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q on the original host", r.Header.Get("Authorization"))
+		}
+
+		http.Redirect(w, r, otherServer.URL, http.StatusFound)
+	}))
+	defer apiServer.Close()
+
+	source := NewClientCredentialsTokenSource(NewHttpClient(), tokenServer.URL, "client", "s3cret")
+	client := NewHttpClient().WithTokenSource(source)
+
+	resp, err := client.Get(context.Background(), apiServer.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	statusCode, _, _ := resp.ToBytes()
+	if statusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401 from the redirect target", statusCode)
+	}
+
+	if leaked.Load() == true {
+		t.Errorf("token was sent to the redirect target")
+	}
+
+	if tokenCalls.Load() != 1 {
+		t.Errorf("token calls = %d, want 1", tokenCalls.Load())
+	}
+}
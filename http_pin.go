@@ -0,0 +1,115 @@
+package thttp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// spkiPinPrefix is the optional prefix of pins in the HPKP "pin-sha256" notation.
+const spkiPinPrefix = "sha256/"
+
+// SpkiPin returns the pin of cert: the base64 SHA-256 digest of its SubjectPublicKeyInfo, as used by
+// [HttpClient.WithPinnedKeys]. Pins survive certificate renewal as long as the key is kept.
+func SpkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// normalizePin validates pin and strips the "sha256/" prefix.
+func normalizePin(pin string) (string, error) {
+	pin = strings.TrimPrefix(strings.TrimSpace(pin), spkiPinPrefix)
+
+	sum, err := base64.StdEncoding.DecodeString(pin)
+	if err != nil || len(sum) != sha256.Size {
+		return "", fmt.Errorf("thttp: invalid SPKI pin %q: want base64 SHA-256 digest", pin)
+	}
+
+	return pin, nil
+}
+
+// PinViolationError is returned when a server presents no certificate whose key matches the pins of its host. It
+// indicates an unexpected certificate rather than a transient fault, so the default retry policy does not retry it.
+type PinViolationError struct {
+	// Host is the server name, empty for IP literal hosts.
+	Host string
+
+	// Pins holds the pins of the certificates the server presented, leaf first.
+	Pins []string
+}
+
+// Error implements [error].
+func (p *PinViolationError) Error() string {
+	if p.Host == "" {
+		return fmt.Sprintf("thttp: certificate pin violation: presented keys %s match no pin", strings.Join(p.Pins, ", "))
+	}
+
+	return fmt.Sprintf("thttp: certificate pin violation for %s: presented keys %s match no pin", p.Host,
+		strings.Join(p.Pins, ", "))
+}
+
+// hostPins returns the pins configured for host: an exact entry, else a "*.parent" wildcard entry for a direct
+// subdomain, else the "*" entry.
+func hostPins(pins map[string][]string, host string) []string {
+	host = strings.ToLower(host)
+
+	vals, ok := pins[host]
+	if ok == true {
+		return vals
+	}
+
+	_, parent, found := strings.Cut(host, ".")
+	if found == true {
+		vals, ok = pins["*."+parent]
+		if ok == true {
+			return vals
+		}
+	}
+
+	return pins["*"]
+}
+
+// pinVerifier returns a [tls.Config.VerifyConnection] function enforcing pins. Certificates of verified chains are
+// matched when crypto/tls verified the server; otherwise (unsafe TLS or a reloaded CA bundle) only the leaf, whose
+// key the handshake proves, is matched, so a pinned intermediate cannot be satisfied by an unrelated certificate.
+func pinVerifier(pins map[string][]string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		allowed := hostPins(pins, state.ServerName)
+		if len(allowed) == 0 {
+			return nil
+		}
+
+		candidates := make([]*x509.Certificate, 0)
+		if len(state.VerifiedChains) > 0 {
+			for _, chain := range state.VerifiedChains {
+				candidates = append(candidates, chain...)
+			}
+		} else if len(state.PeerCertificates) > 0 {
+			candidates = append(candidates, state.PeerCertificates[0])
+		}
+
+		for _, cert := range candidates {
+			pin := SpkiPin(cert)
+
+			for _, val := range allowed {
+				if val == pin {
+					return nil
+				}
+			}
+		}
+
+		presented := make([]string, 0, len(state.PeerCertificates))
+		for _, cert := range state.PeerCertificates {
+			presented = append(presented, SpkiPin(cert))
+		}
+
+		return &PinViolationError{
+			Host: state.ServerName,
+			Pins: presented,
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package thttp
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPinnedKeys(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	server := newTlsServer(t, serverCa)
+
+	leafPin := SpkiPin(server.TLS.Certificates[0].Leaf)
+	caPin := SpkiPin(serverCa.cert)
+	otherPin := SpkiPin(newTestCa(t, "other ca").cert)
+
+	tests := map[string]struct {
+		host   string
+		pins   []string
+		unsafe bool
+		url    string
+		wantOk bool
+	}{
+		"leaf":             {host: "localhost", pins: []string{otherPin, leafPin}, wantOk: true},
+		"prefixed":         {host: "LOCALHOST", pins: []string{"sha256/" + leafPin}, wantOk: true},
+		"ca":               {host: "localhost", pins: []string{caPin}, wantOk: true},
+		"mismatch":         {host: "localhost", pins: []string{otherPin}},
+		"wildcard":         {host: "*", pins: []string{otherPin}},
+		"other host":       {host: "example.com", pins: []string{otherPin}, wantOk: true},
+		"unsafe leaf":      {host: "localhost", pins: []string{leafPin}, unsafe: true, wantOk: true},
+		"unsafe ca":        {host: "localhost", pins: []string{caPin}, unsafe: true},
+		"unsafe wildcard":  {host: "*", pins: []string{otherPin}, unsafe: true, url: server.URL},
+		"wildcard ip host": {host: "*", pins: []string{leafPin}, url: server.URL, wantOk: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewHttpClient().WithRootCAs(NewRootCAsOption().WithPem(serverCa.certPem)).WithUnsafeTls(test.unsafe).
+				WithPinnedKeys(test.host, test.pins...)
+
+			target := test.url
+			if target == "" {
+				target = localhostUrl(server)
+			}
+
+			_, err := getText(client, target)
+			if test.wantOk == true {
+				if err != nil {
+					t.Errorf("Get = %v, want success", err)
+				}
+
+				return
+			}
+
+			var pinErr *PinViolationError
+			if errors.As(err, &pinErr) == false {
+				t.Fatalf("Get = %v, want *PinViolationError", err)
+			}
+
+			if len(pinErr.Pins) == 0 || pinErr.Pins[0] != leafPin {
+				t.Errorf("presented pins = %v, want the leaf first", pinErr.Pins)
+			}
+		})
+	}
+}
+
+func TestPinnedKeysUpdate(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	server := newTlsServer(t, serverCa)
+
+	otherPin := SpkiPin(newTestCa(t, "other ca").cert)
+
+	client := NewHttpClient().WithRootCAs(NewRootCAsOption().WithPem(serverCa.certPem)).
+		WithPinnedKeys("localhost", otherPin).
+		WithPinnedKeys("example.com", otherPin)
+
+	_, err := getText(client, localhostUrl(server))
+	if err == nil {
+		t.Fatalf("Get with a mismatched pin succeeded")
+	}
+
+	// calling with no pins removes the host and keeps the others
+	client.WithPinnedKeys("localhost")
+
+	_, err = getText(client, localhostUrl(server))
+	if err != nil {
+		t.Errorf("Get after removing the pins = %v", err)
+	}
+
+	client.WithPinnedKeys("localhost", "not-a-pin")
+
+	_, err = getText(client, localhostUrl(server))
+	if err == nil || strings.Contains(err.Error(), "thttp: invalid SPKI pin") == false {
+		t.Errorf("Get with an invalid pin = %v, want the option error", err)
+	}
+}
+
+func TestPinnedKeysIpHost(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	server := newTlsServer(t, serverCa)
+
+	otherPin := SpkiPin(newTestCa(t, "other ca").cert)
+
+	// an IP literal pin would never match a server name, so it must fail instead of letting a mismatch through
+	for _, host := range []string{"127.0.0.1", "::1", "[::1]"} {
+		client := NewHttpClient().WithRootCAs(NewRootCAsOption().WithPem(serverCa.certPem)).WithPinnedKeys(host, otherPin)
+
+		_, err := getText(client, server.URL)
+		if err == nil || strings.Contains(err.Error(), "thttp: invalid pinned host") == false {
+			t.Errorf("Get with pinned host %s = %v, want the option error", host, err)
+		}
+	}
+}
+
+func TestPinViolationNotRetried(t *testing.T) {
+	err := &url.Error{Op: "Get", URL: "https://localhost", Err: &PinViolationError{Host: "localhost"}}
+
+	retry, _ := baseRetryPolicy(nil, err)
+	if retry == true {
+		t.Errorf("baseRetryPolicy retried a pin violation")
+	}
+}
@@ -0,0 +1,100 @@
+package thttp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProgressFunc reports body transfer progress: done is the number of bytes transferred so far and total is the
+// expected size, or -1 when unknown (alias so values round-trip through [HttpClient.WithOption]).
+type ProgressFunc = func(done int64, total int64)
+
+// maxBandwidthChunkSize bounds a single throttled read so large buffers do not burst past the configured rate.
+const maxBandwidthChunkSize = 32 * 1024
+
+// BandwidthLimiter is a token bucket that caps combined request and response body throughput in bytes per second.
+// A single limiter may be shared by many requests (and clients); it is safe for concurrent use.
+type BandwidthLimiter struct {
+	bytesPerSec int64
+
+	tokens float64
+	lastAt time.Time
+
+	sync.Mutex
+}
+
+// NewBandwidthLimiter returns a limiter allowing bytesPerSec bytes per second, or nil (no limit) when bytesPerSec <= 0.
+func NewBandwidthLimiter(bytesPerSec int64) *BandwidthLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+
+	return &BandwidthLimiter{
+		bytesPerSec: bytesPerSec,
+
+		tokens: float64(bytesPerSec),
+		lastAt: time.Now(),
+	}
+}
+
+// chunkSize returns the largest read size that keeps throttling smooth (about a tenth of a second of budget).
+func (p *BandwidthLimiter) chunkSize() int {
+	chunkSize := p.bytesPerSec / 10
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+
+	if chunkSize > maxBandwidthChunkSize {
+		chunkSize = maxBandwidthChunkSize
+	}
+
+	return int(chunkSize)
+}
+
+// WaitN blocks until n bytes may be transferred, or returns the context error if ctx ends first.
+// Tokens are reserved before waiting so concurrent callers are served in arrival order.
+func (p *BandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	if p == nil || n <= 0 {
+		return nil
+	}
+
+	p.Lock()
+
+	now := time.Now()
+
+	p.tokens += now.Sub(p.lastAt).Seconds() * float64(p.bytesPerSec)
+	if p.tokens > float64(p.bytesPerSec) {
+		p.tokens = float64(p.bytesPerSec)
+	}
+
+	p.lastAt = now
+	p.tokens -= float64(n)
+
+	var waitTime time.Duration
+	if p.tokens < 0 {
+		waitTime = time.Duration(-p.tokens / float64(p.bytesPerSec) * float64(time.Second))
+	}
+
+	p.Unlock()
+
+	if waitTime <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(waitTime)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+
+		// give back the reservation that will not be used
+		p.Lock()
+		p.tokens += float64(n)
+		p.Unlock()
+
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	return nil
+}
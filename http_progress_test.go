@@ -0,0 +1,65 @@
+package thttp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewBandwidthLimiterDisabled(t *testing.T) {
+	limiter := NewBandwidthLimiter(0)
+	if limiter != nil {
+		t.Fatalf("NewBandwidthLimiter(0) = %v, want nil", limiter)
+	}
+
+	err := limiter.WaitN(context.Background(), 1<<20)
+	if err != nil {
+		t.Errorf("WaitN on a nil limiter = %v, want nil", err)
+	}
+}
+
+func TestBandwidthLimiterWaitN(t *testing.T) {
+	limiter := NewBandwidthLimiter(1000)
+
+	// the bucket starts full, so the first second of budget is available at once
+	startAt := time.Now()
+
+	err := limiter.WaitN(context.Background(), 1000)
+	if err != nil || time.Since(startAt) > 50*time.Millisecond {
+		t.Fatalf("WaitN of the initial budget = %v after %s, want no wait", err, time.Since(startAt))
+	}
+
+	startAt = time.Now()
+
+	err = limiter.WaitN(context.Background(), 200)
+	if err != nil {
+		t.Fatalf("WaitN: %v", err)
+	}
+
+	elapsed := time.Since(startAt)
+	if elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("WaitN(200) at 1000 B/s took %s, want about 200ms", elapsed)
+	}
+}
+
+func TestBandwidthLimiterWaitNCanceled(t *testing.T) {
+	limiter := NewBandwidthLimiter(100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := limiter.WaitN(ctx, 1000)
+	if errors.Is(err, context.DeadlineExceeded) == false {
+		t.Fatalf("WaitN = %v, want the context error", err)
+	}
+
+	// the canceled reservation is given back, leaving the initial budget
+	limiter.Lock()
+	tokens := limiter.tokens
+	limiter.Unlock()
+
+	if tokens < 90 {
+		t.Errorf("tokens = %f after a canceled wait, want the reservation returned", tokens)
+	}
+}
@@ -0,0 +1,118 @@
+package thttp
+
+import (
+	"errors"
+	"net/http"
+	_url "net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// noProxySentinel stands in for the proxy when [httpproxy.Config] only decides whether NO_PROXY bypasses a URL.
+const noProxySentinel = "http://thttp-no-proxy.invalid"
+
+// proxySettings holds the proxy options of an [HttpClient]; [proxySettings.proxyFunc] composes them into
+// [http.Transport.Proxy] so the proxy source, bypass list, and credentials can change independently.
+type proxySettings struct {
+	source ProxyFunc
+
+	noProxy string
+
+	auth *_url.Userinfo
+}
+
+// proxyFunc returns a [http.Transport.Proxy] function for the settings, or nil when no proxy is set. Requests
+// matching noProxy go direct, and auth replaces any credentials of the chosen proxy URL.
+func (p *proxySettings) proxyFunc() ProxyFunc {
+	if p.source == nil {
+		return nil
+	}
+
+	source := p.source
+	auth := p.auth
+
+	var bypass func(*_url.URL) (*_url.URL, error)
+	if p.noProxy != "" {
+		config := &httpproxy.Config{
+			HTTPProxy:  noProxySentinel,
+			HTTPSProxy: noProxySentinel,
+			NoProxy:    p.noProxy,
+		}
+
+		bypass = config.ProxyFunc()
+	}
+
+	return func(req *http.Request) (*_url.URL, error) {
+		proxyUrl, err := source(req)
+		if err != nil || proxyUrl == nil {
+			return proxyUrl, err
+		}
+
+		if bypass != nil {
+			sentinel, err := bypass(req.URL)
+			if err == nil && sentinel == nil {
+				return nil, nil
+			}
+		}
+
+		if auth != nil {
+			authUrl := *proxyUrl
+			authUrl.User = auth
+
+			return &authUrl, nil
+		}
+
+		return proxyUrl, nil
+	}
+}
+
+// environmentProxyFunc returns a [ProxyFunc] for the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables
+// (or their lowercase forms) as read now, and a redacted description of them for logging.
+func environmentProxyFunc() (ProxyFunc, string) {
+	config := httpproxy.FromEnvironment()
+	proxyForUrl := config.ProxyFunc()
+
+	proxyFunc := func(req *http.Request) (*_url.URL, error) {
+		return proxyForUrl(req.URL)
+	}
+
+	description := "http_proxy=" + redactProxyUrl(config.HTTPProxy) + " https_proxy=" +
+		redactProxyUrl(config.HTTPSProxy) + " no_proxy=" + config.NoProxy
+
+	return proxyFunc, description
+}
+
+// parseProxyUrl parses a proxy address, prepending "http://" when the scheme is missing. Parse errors omit the
+// address so embedded credentials are not logged.
+func parseProxyUrl(proxy string) (*_url.URL, error) {
+	if strings.Contains(proxy, "://") == false {
+		proxy = "http://" + proxy
+	}
+
+	proxyUrl, err := _url.Parse(proxy)
+	if err != nil {
+		var urlErr *_url.Error
+		if errors.As(err, &urlErr) == true {
+			err = urlErr.Err
+		}
+
+		return nil, err
+	}
+
+	return proxyUrl, nil
+}
+
+// redactProxyUrl returns proxy with any password replaced, "none" when empty, or "invalid" when it cannot be parsed.
+func redactProxyUrl(proxy string) string {
+	if proxy == "" {
+		return "none"
+	}
+
+	proxyUrl, err := parseProxyUrl(proxy)
+	if err != nil {
+		return "invalid"
+	}
+
+	return proxyUrl.Redacted()
+}
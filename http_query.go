@@ -0,0 +1,388 @@
+package thttp
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryMarshaler is implemented by types that encode themselves as one or more query string or form values.
+// It takes precedence over [encoding.TextMarshaler].
+type QueryMarshaler interface {
+	MarshalQuery() ([]string, error)
+}
+
+// maxQueryDepth bounds struct nesting in [EncodeQuery] and [EncodeForm], so self-referential values fail instead of
+// recursing without end.
+const maxQueryDepth = 32
+
+// errQueryDepth is returned when struct nesting exceeds [maxQueryDepth].
+var errQueryDepth = fmt.Errorf("thttp: cannot encode parameters: structs nested deeper than %d levels, possibly a cycle",
+	maxQueryDepth)
+
+var (
+	queryMarshalerType = reflect.TypeOf((*QueryMarshaler)(nil)).Elem()
+	textMarshalerType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType           = reflect.TypeOf(time.Time{})
+)
+
+// queryTagOptions holds the comma-separated options of a query or form struct tag.
+type queryTagOptions struct {
+	omitEmpty bool
+	comma     bool
+}
+
+// parseQueryTag splits a struct tag such as "page,omitempty" into its name and options.
+func parseQueryTag(tag string) (string, queryTagOptions) {
+	options := queryTagOptions{}
+
+	parts := strings.Split(tag, ",")
+	for _, part := range parts[1:] {
+		switch strings.TrimSpace(part) {
+		case "omitempty":
+			options.omitEmpty = true
+		case "comma":
+			options.comma = true
+		}
+	}
+
+	return parts[0], options
+}
+
+// EncodeQuery converts v into [url.Values] for a query string. v may be [url.Values], map[string]string,
+// map[string][]string, or a struct (or pointer to struct) whose fields are encoded according to `query` tags:
+//
+//	type ListRequest struct {
+//		Page    int       `query:"page,omitempty"`
+//		Tags    []string  `query:"tag"`           // repeated: tag=a&tag=b
+//		Ids     []int     `query:"ids,comma"`     // joined: ids=1,2,3
+//		Since   time.Time `query:"since" layout:"2006-01-02"`
+//		Filter  Filter    `query:"filter"`        // nested: filter.name=...
+//		Paging                                    // embedded fields are flattened
+//		Ignored string    `query:"-"`
+//	}
+//
+// Untagged exported fields use the Go field name. time.Time defaults to RFC 3339; the layout tag accepts any
+// [time.Time.Format] layout or "unix" / "unixmilli". Values implementing [QueryMarshaler] or [encoding.TextMarshaler]
+// encode themselves. Nil pointers are skipped.
+func EncodeQuery(v interface{}) (url.Values, error) {
+	return encodeValues(v, "query")
+}
+
+// EncodeForm converts v into [url.Values] for an application/x-www-form-urlencoded body, like [EncodeQuery] but
+// reading `form` tags (falling back to `query` tags when a field has no form tag).
+func EncodeForm(v interface{}) (url.Values, error) {
+	return encodeValues(v, "form")
+}
+
+// encodeValues implements [EncodeQuery] and [EncodeForm] for the given tag name.
+func encodeValues(v interface{}, tagName string) (url.Values, error) {
+	values := make(url.Values)
+
+	switch retV := v.(type) {
+	case nil:
+		return values, nil
+	case url.Values:
+		for key, vals := range retV {
+			values[key] = append([]string(nil), vals...)
+		}
+
+		return values, nil
+	case map[string][]string:
+		for key, vals := range retV {
+			values[key] = append([]string(nil), vals...)
+		}
+
+		return values, nil
+	case map[string]string:
+		for key, val := range retV {
+			values.Set(key, val)
+		}
+
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return values, nil
+		}
+
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("thttp: cannot encode %T as %s parameters: want struct, url.Values, or map", v, tagName)
+	}
+
+	err := encodeStruct(values, rv, "", tagName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// encodeStruct adds the fields of rv to values, prefixing keys with prefix. depth counts the enclosing structs.
+func encodeStruct(values url.Values, rv reflect.Value, prefix string, tagName string, depth int) error {
+	if depth >= maxQueryDepth {
+		return errQueryDepth
+	}
+
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+
+		tag, ok := field.Tag.Lookup(tagName)
+		if ok == false && tagName != "query" {
+			tag = field.Tag.Get("query")
+		}
+
+		if tag == "-" {
+			continue
+		}
+
+		name, options := parseQueryTag(tag)
+
+		fieldValue := rv.Field(i)
+
+		// flatten untagged embedded structs, including unexported ones with exported fields
+		if field.Anonymous && name == "" {
+			embedded := fieldValue
+			for embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					break
+				}
+
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct && isSelfEncoding(embedded.Type()) == false {
+				err := encodeStruct(values, embedded, prefix, tagName, depth+1)
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		if field.IsExported() == false {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if options.omitEmpty && isEmptyQueryValue(fieldValue) {
+			continue
+		}
+
+		err := encodeField(values, prefix+name, fieldValue, options, field.Tag.Get("layout"), tagName, depth)
+		if err != nil {
+			if err == errQueryDepth {
+				return err
+			}
+
+			return fmt.Errorf("thttp: cannot encode field %s: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// isSelfEncoding reports whether values of t (or *t) format themselves rather than being expanded field by field.
+func isSelfEncoding(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+
+	return t.Implements(queryMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PointerTo(t).Implements(queryMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// encodeField adds the value(s) of one field under key.
+func encodeField(values url.Values, key string, rv reflect.Value, options queryTagOptions, layout string, tagName string, depth int) error {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+
+		rv = rv.Elem()
+	}
+
+	vals, ok, err := marshalQueryValue(rv, layout)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		for _, val := range vals {
+			values.Add(key, val)
+		}
+
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(key, string(rv.Bytes()))
+
+			return nil
+		}
+
+		items := make([]string, 0, rv.Len())
+
+		for i := 0; i < rv.Len(); i++ {
+			item := rv.Index(i)
+			for item.Kind() == reflect.Pointer || item.Kind() == reflect.Interface {
+				if item.IsNil() {
+					break
+				}
+
+				item = item.Elem()
+			}
+
+			if item.Kind() == reflect.Pointer || item.Kind() == reflect.Interface {
+				continue
+			}
+
+			itemVals, ok, err := marshalQueryValue(item, layout)
+			if err != nil {
+				return err
+			}
+
+			if ok == false {
+				itemVal, err := formatQueryScalar(item)
+				if err != nil {
+					return err
+				}
+
+				itemVals = []string{itemVal}
+			}
+
+			items = append(items, itemVals...)
+		}
+
+		if options.comma {
+			if len(items) > 0 {
+				values.Add(key, strings.Join(items, ","))
+			}
+
+			return nil
+		}
+
+		for _, item := range items {
+			values.Add(key, item)
+		}
+
+		return nil
+	case reflect.Struct:
+		return encodeStruct(values, rv, key+".", tagName, depth+1)
+	}
+
+	val, err := formatQueryScalar(rv)
+	if err != nil {
+		return err
+	}
+
+	values.Add(key, val)
+
+	return nil
+}
+
+// marshalQueryValue formats rv via [QueryMarshaler], time.Time layouts, or [encoding.TextMarshaler]. ok is false
+// when rv does not encode itself.
+func marshalQueryValue(rv reflect.Value, layout string) ([]string, bool, error) {
+	if rv.CanInterface() == false {
+		return nil, false, nil
+	}
+
+	candidates := []reflect.Value{rv}
+	if rv.CanAddr() {
+		candidates = append(candidates, rv.Addr())
+	}
+
+	for _, candidate := range candidates {
+		marshaler, ok := candidate.Interface().(QueryMarshaler)
+		if ok {
+			vals, err := marshaler.MarshalQuery()
+
+			return vals, true, err
+		}
+	}
+
+	if rv.Type() == timeType {
+		return []string{formatQueryTime(rv.Interface().(time.Time), layout)}, true, nil
+	}
+
+	for _, candidate := range candidates {
+		marshaler, ok := candidate.Interface().(encoding.TextMarshaler)
+		if ok {
+			text, err := marshaler.MarshalText()
+
+			return []string{string(text)}, true, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+// formatQueryTime formats t with layout, supporting the "unix" and "unixmilli" pseudo-layouts and defaulting to RFC 3339.
+func formatQueryTime(t time.Time, layout string) string {
+	switch layout {
+	case "":
+		return t.Format(time.RFC3339)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.Format(layout)
+	}
+}
+
+// formatQueryScalar formats strings, booleans, and numbers.
+func formatQueryScalar(rv reflect.Value) (string, error) {
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported kind %s", rv.Kind())
+	}
+}
+
+// isEmptyQueryValue reports whether rv is a zero value for omitempty purposes (zero times included).
+func isEmptyQueryValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	case reflect.Struct:
+		if rv.Type() == timeType && rv.CanInterface() {
+			return rv.Interface().(time.Time).IsZero()
+		}
+
+		return rv.IsZero()
+	default:
+		return rv.IsZero()
+	}
+}
@@ -0,0 +1,298 @@
+package thttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	_url "net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// queryTestStatus encodes itself with [QueryMarshaler].
+type queryTestStatus []string
+
+// MarshalQuery implements [QueryMarshaler].
+func (p queryTestStatus) MarshalQuery() ([]string, error) {
+	return []string{strings.Join(p, "|")}, nil
+}
+
+type queryTestPaging struct {
+	Page int `query:"page,omitempty"`
+	Size int `query:"size,omitempty"`
+}
+
+type queryTestFilter struct {
+	Name  string `query:"name"`
+	Owner *string
+}
+
+type queryTestRequest struct {
+	queryTestPaging
+
+	Tags    []string        `query:"tag"`
+	Ids     []int           `query:"ids,comma"`
+	Since   time.Time       `query:"since" layout:"2006-01-02"`
+	Until   time.Time       `query:"until,omitempty"`
+	Created time.Time       `query:"created" layout:"unix"`
+	Filter  queryTestFilter `query:"filter"`
+	Status  queryTestStatus `query:"status"`
+	Ip      net.IP          `query:"ip"`
+	Ratio   float64         `query:"ratio"`
+	Enabled *bool           `query:"enabled"`
+	Ignored string          `query:"-"`
+	Title   string
+	hidden  string
+}
+
+func TestEncodeQuery(t *testing.T) {
+	owner := "ann"
+
+	request := &queryTestRequest{
+		queryTestPaging: queryTestPaging{Page: 2},
+
+		Tags:    []string{"a", "b"},
+		Ids:     []int{1, 2, 3},
+		Since:   time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Created: time.Unix(1700000000, 0),
+		Filter:  queryTestFilter{Name: "book", Owner: &owner},
+		Status:  queryTestStatus{"open", "closed"},
+		Ip:      net.ParseIP("10.0.0.1"),
+		Ratio:   0.5,
+		Ignored: "x",
+		Title:   "t",
+		hidden:  "h",
+	}
+
+	values, err := EncodeQuery(request)
+	if err != nil {
+		t.Fatalf("EncodeQuery: %v", err)
+	}
+
+	want := _url.Values{
+		"page":         {"2"},
+		"tag":          {"a", "b"},
+		"ids":          {"1,2,3"},
+		"since":        {"2024-05-06"},
+		"created":      {"1700000000"},
+		"filter.name":  {"book"},
+		"filter.Owner": {"ann"},
+		"status":       {"open|closed"},
+		"ip":           {"10.0.0.1"},
+		"ratio":        {"0.5"},
+		"Title":        {"t"},
+	}
+
+	if reflect.DeepEqual(values, want) == false {
+		t.Errorf("EncodeQuery = %v, want %v", values, want)
+	}
+}
+
+func TestEncodeQueryInputs(t *testing.T) {
+	tests := map[string]struct {
+		params interface{}
+		want   _url.Values
+	}{
+		"nil":           {params: nil, want: _url.Values{}},
+		"nil pointer":   {params: (*queryTestRequest)(nil), want: _url.Values{}},
+		"url.Values":    {params: _url.Values{"a": {"1", "2"}}, want: _url.Values{"a": {"1", "2"}}},
+		"map of slices": {params: map[string][]string{"a": {"1"}}, want: _url.Values{"a": {"1"}}},
+		"map":           {params: map[string]string{"a": "1"}, want: _url.Values{"a": {"1"}}},
+	}
+
+	for name, test := range tests {
+		values, err := EncodeQuery(test.params)
+		if err != nil || reflect.DeepEqual(values, test.want) == false {
+			t.Errorf("%s: EncodeQuery = %v, %v, want %v", name, values, err, test.want)
+		}
+	}
+
+	_, err := EncodeQuery(42)
+	if err == nil {
+		t.Errorf("EncodeQuery accepted an int")
+	}
+
+	_, err = EncodeQuery(struct{ Values map[string]int }{Values: map[string]int{"a": 1}})
+	if err == nil || strings.Contains(err.Error(), "Values") == false {
+		t.Errorf("EncodeQuery of a map field = %v, want an error naming the field", err)
+	}
+}
+
+func TestEncodeForm(t *testing.T) {
+	form := struct {
+		User     string `form:"username" query:"user"`
+		Password string `query:"password"`
+		Remember bool   `form:"remember,omitempty"`
+	}{User: "ann", Password: "s3cret"}
+
+	values, err := EncodeForm(form)
+	if err != nil {
+		t.Fatalf("EncodeForm: %v", err)
+	}
+
+	want := _url.Values{"username": {"ann"}, "password": {"s3cret"}}
+	if reflect.DeepEqual(values, want) == false {
+		t.Errorf("EncodeForm = %v, want %v", values, want)
+	}
+}
+
+func TestQueryAndFormRequests(t *testing.T) {
+	var rawQuery, form atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery.Store(r.URL.RawQuery)
+
+		_ = r.ParseForm()
+		form.Store(r.PostForm.Encode())
+	}))
+	defer server.Close()
+
+	client := NewHttpClient().WithQuery(map[string]string{"client": "1"})
+
+	resp, err := client.Get(context.Background(), server.URL+"?a=0", nil, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if rawQuery.Load() != "a=0&client=1" {
+		t.Errorf("query = %q, want the client parameters", rawQuery.Load())
+	}
+
+	requestOption := NewRequestOption().WithQuery(queryTestPaging{Page: 3, Size: 10})
+
+	resp, err = client.Get(context.Background(), server.URL, requestOption, _url.Values{"b": {"1"}})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if rawQuery.Load() != "b=1&page=3&size=10" {
+		t.Errorf("query = %q, want the request parameters to replace the client ones", rawQuery.Load())
+	}
+
+	resp, err = client.PostForm(context.Background(), server.URL, nil, struct {
+		Name string `form:"name"`
+		Tags []string
+	}{Name: "book", Tags: []string{"x", "y"}})
+	if err != nil {
+		t.Fatalf("PostForm: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if form.Load() != "Tags=x&Tags=y&name=book" {
+		t.Errorf("form = %q", form.Load())
+	}
+
+	_, err = client.Get(context.Background(), server.URL, NewRequestOption().WithQuery(42), nil)
+	if err == nil || strings.Contains(err.Error(), "invalid OptQueryParams value") == false {
+		t.Errorf("Get with an invalid query = %v", err)
+	}
+}
+
+func TestPostFormKeepsRequestOption(t *testing.T) {
+	var contentType atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType.Store(r.Header.Get("Content-Type"))
+	}))
+	defer server.Close()
+
+	client := NewHttpClient()
+	requestOption := NewRequestOption().WithHeader("X-Tenant", "a")
+
+	resp, err := client.PostForm(context.Background(), server.URL, requestOption, _url.Values{"a": {"1"}})
+	if err != nil {
+		t.Fatalf("PostForm: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if contentType.Load() != ContentTypeApplicationForm {
+		t.Errorf("PostForm Content-Type = %q, want %q", contentType.Load(), ContentTypeApplicationForm)
+	}
+
+	// reusing the option for another body must not send the form Content-Type
+	resp, err = client.Post(context.Background(), server.URL, requestOption, []byte("{}"))
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+
+	_, _, _ = resp.ToBytes()
+
+	if contentType.Load() == ContentTypeApplicationForm {
+		t.Errorf("Post after PostForm Content-Type = %q, want the caller's option unchanged", contentType.Load())
+	}
+}
+
+func TestQueryVerbParams(t *testing.T) {
+	var rawQuery atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery.Store(r.Method + " " + r.URL.RawQuery)
+
+		w.Header().Set("Content-Length", "0")
+	}))
+	defer server.Close()
+
+	client := NewHttpClient()
+
+	getLen := func(ctx context.Context, url string, requestOption *RequestOption, params _url.Values) (*Response, error) {
+		_, err := client.GetLen(ctx, url, requestOption, params)
+
+		return nil, err
+	}
+
+	verbs := map[string]func(ctx context.Context, url string, requestOption *RequestOption, params _url.Values) (*Response, error){
+		http.MethodGet:     client.Get,
+		http.MethodHead:    getLen,
+		http.MethodDelete:  client.Delete,
+		http.MethodOptions: client.Options,
+		http.MethodTrace:   client.Trace,
+	}
+
+	for method, verb := range verbs {
+		resp, err := verb(context.Background(), server.URL+"?a=0", nil, _url.Values{"b": {"1"}})
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+
+		if resp != nil {
+			_, _, _ = resp.ToBytes()
+		}
+
+		if rawQuery.Load() != method+" a=0&b=1" {
+			t.Errorf("%s query = %q, want the params appended", method, rawQuery.Load())
+		}
+	}
+}
+
+type queryTestNode struct {
+	Name string         `query:"name"`
+	Next *queryTestNode `query:"next"`
+}
+
+func TestEncodeQueryCycle(t *testing.T) {
+	node := &queryTestNode{Name: "a"}
+	node.Next = node
+
+	_, err := EncodeQuery(node)
+	if err == nil || strings.Contains(err.Error(), "nested deeper") == false {
+		t.Fatalf("EncodeQuery of a cycle = %v, want a nesting error", err)
+	}
+
+	// finite nesting below the bound still encodes
+	list := &queryTestNode{Name: "a", Next: &queryTestNode{Name: "b", Next: &queryTestNode{Name: "c"}}}
+
+	values, err := EncodeQuery(list)
+	if err != nil || values.Get("next.next.name") != "c" {
+		t.Errorf("EncodeQuery = %v, %v, want next.next.name=c", values, err)
+	}
+}
@@ -9,7 +9,8 @@ import (
 
 // RequestOption carries per-request options, headers, and cookies merged with [HttpClient] defaults.
 // Per-request option keys must be set only through typed helpers (e.g. [RequestOption.WithLogTransOption]);
-// there is no generic option setter so [*LogTransOption] / [*RetryTransOption] are always shallow-copied when stored.
+// there is no generic option setter so option structs such as [*LogTransOption] / [*RetryTransOption] are always
+// shallow-copied when stored.
 type RequestOption struct {
 	options map[int]interface{}
 
@@ -70,6 +71,20 @@ func (p *RequestOption) snapshot() requestOptionSnapshot {
 	}
 }
 
+// clone returns an independent copy of p (an empty option when p is nil) so helpers can add options and headers
+// without mutating the caller's value.
+func (p *RequestOption) clone() *RequestOption {
+	snapshot := p.snapshot()
+
+	return &RequestOption{
+		options: snapshot.options,
+
+		Headers: snapshot.headers,
+
+		Cookies: snapshot.cookies,
+	}
+}
+
 // setOption stores a per-request option. Keys in [OptTransports] are ignored (transport is client-wide).
 func (p *RequestOption) setOption(key int, val interface{}) *RequestOption {
 	p.Lock()
@@ -103,6 +118,73 @@ func (p *RequestOption) WithLogTransOption(option *LogTransOption) *RequestOptio
 	return p.setOption(OptTransLog, option)
 }
 
+// WithDecompressTransOption attaches response decompression for this request ([OptTransDecompress]).
+func (p *RequestOption) WithDecompressTransOption(option *DecompressTransOption) *RequestOption {
+	return p.setOption(OptTransDecompress, option)
+}
+
+// WithAuthenticator authenticates this request with authenticator ([OptTransAuth]); nil disables a client-wide
+// authenticator for this request.
+func (p *RequestOption) WithAuthenticator(authenticator Authenticator) *RequestOption {
+	return p.setOption(OptTransAuth, authenticator)
+}
+
+// WithBasicAuth authenticates this request with HTTP Basic credentials. See [NewBasicAuth].
+func (p *RequestOption) WithBasicAuth(username string, password string) *RequestOption {
+	return p.WithAuthenticator(NewBasicAuth(username, password))
+}
+
+// WithBearerToken authenticates this request with a static bearer token. See [NewBearerAuth].
+func (p *RequestOption) WithBearerToken(token string) *RequestOption {
+	return p.WithAuthenticator(NewBearerAuth(token))
+}
+
+// WithDigestAuth authenticates this request with HTTP Digest credentials. See [NewDigestAuth]. Reuse one
+// [*DigestAuth] through [RequestOption.WithAuthenticator] to keep its challenge across requests.
+func (p *RequestOption) WithDigestAuth(username string, password string) *RequestOption {
+	return p.WithAuthenticator(NewDigestAuth(username, password))
+}
+
+// WithTokenSource authenticates this request with OAuth2 tokens from source. See [NewTokenAuth]; pass a shared
+// [*CachedTokenSource] to reuse cached tokens across requests.
+func (p *RequestOption) WithTokenSource(source TokenSource) *RequestOption {
+	return p.WithAuthenticator(NewTokenAuth(source))
+}
+
+// WithRequestSigner signs every attempt of this request with signer ([OptTransSign]); nil disables a client-wide
+// signer for this request.
+func (p *RequestOption) WithRequestSigner(signer RequestSigner) *RequestOption {
+	return p.setOption(OptTransSign, signer)
+}
+
+// WithQuery appends params to this request's URL ([OptQueryParams]); params may be [url.Values], a map, or a struct
+// encoded with [EncodeQuery]. It works with every verb helper, including [HttpClient.Get] alongside its url.Values.
+func (p *RequestOption) WithQuery(params interface{}) *RequestOption {
+	return p.setOption(OptQueryParams, params)
+}
+
+// WithRequestCompressOption compresses this request's body when it meets the size threshold ([OptRequestCompress]).
+func (p *RequestOption) WithRequestCompressOption(option *RequestCompressOption) *RequestOption {
+	return p.setOption(OptRequestCompress, option)
+}
+
+// WithReadIdleTimeout fails response body reads that receive no data for timeout on this request
+// ([OptReadIdleTimeout]); timeout <= 0 disables a client-wide idle timeout for this request.
+func (p *RequestOption) WithReadIdleTimeout(timeout time.Duration) *RequestOption {
+	return p.setOption(OptReadIdleTimeout, timeout)
+}
+
+// WithMaxResponseSize limits the response body to maxSize bytes on the wire for this request ([OptMaxResponseSize]).
+func (p *RequestOption) WithMaxResponseSize(maxSize int64) *RequestOption {
+	return p.setOption(OptMaxResponseSize, maxSize)
+}
+
+// WithMaxDecodedResponseSize limits the decompressed response body to maxSize bytes for this request
+// ([OptMaxDecodedResponseSize]).
+func (p *RequestOption) WithMaxDecodedResponseSize(maxSize int64) *RequestOption {
+	return p.setOption(OptMaxDecodedResponseSize, maxSize)
+}
+
 // WithCookieJar sets the cookie jar for this request ([OptCookieJar]).
 func (p *RequestOption) WithCookieJar(jar http.CookieJar) *RequestOption {
 	return p.setOption(OptCookieJar, jar)
@@ -123,6 +205,27 @@ func (p *RequestOption) WithResponseHookFunc(option ResponseHookFunc) *RequestOp
 	return p.setOption(OptExtraResponseHookFunc, option)
 }
 
+// WithUploadProgressFunc registers a request body progress callback for this request ([OptUploadProgressFunc]).
+func (p *RequestOption) WithUploadProgressFunc(option ProgressFunc) *RequestOption {
+	return p.setOption(OptUploadProgressFunc, option)
+}
+
+// WithDownloadProgressFunc registers a response body progress callback for this request ([OptDownloadProgressFunc]).
+func (p *RequestOption) WithDownloadProgressFunc(option ProgressFunc) *RequestOption {
+	return p.setOption(OptDownloadProgressFunc, option)
+}
+
+// WithBandwidthLimit caps body throughput for this request only to bytesPerSec bytes per second ([OptBandwidthLimit]).
+// bytesPerSec <= 0 removes any client-wide limit for this request.
+func (p *RequestOption) WithBandwidthLimit(bytesPerSec int64) *RequestOption {
+	return p.setOption(OptBandwidthLimit, NewBandwidthLimiter(bytesPerSec))
+}
+
+// WithBandwidthLimiter applies a shared [BandwidthLimiter] to this request ([OptBandwidthLimit]).
+func (p *RequestOption) WithBandwidthLimiter(limiter *BandwidthLimiter) *RequestOption {
+	return p.setOption(OptBandwidthLimit, limiter)
+}
+
 // WithHeader sets a header for this request (key is stored in lowercase).
 func (p *RequestOption) WithHeader(key string, val string) *RequestOption {
 	p.Lock()
@@ -0,0 +1,767 @@
+package thttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http/httptrace"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/choveylee/tlog"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// DefaultDnsCacheTtl is how long lookups through the system resolver, which does not report record TTLs, are
+	// cached by a [Resolver].
+	DefaultDnsCacheTtl = 30 * time.Second
+	// DefaultDnsMaxTtl caps the record TTLs honored by a [Resolver].
+	DefaultDnsMaxTtl = 5 * time.Minute
+	// DefaultDnsNegativeTtl is how long a failed lookup is cached when the server gives no SOA negative TTL.
+	DefaultDnsNegativeTtl = 5 * time.Second
+
+	// dnsQueryTimeout bounds one query to one nameserver when the context has no earlier deadline.
+	dnsQueryTimeout = 5 * time.Second
+
+	// dnsUdpSize is the receive buffer for UDP responses; larger answers are truncated and retried over TCP.
+	dnsUdpSize = 1232
+
+	// dnsFallbackDelay is how long dialing the first address family runs before the other family is raced against
+	// it, the default of [net.Dialer.FallbackDelay].
+	dnsFallbackDelay = 300 * time.Millisecond
+)
+
+// dnsCacheEntry is a cached lookup result; err is set for negative entries.
+type dnsCacheEntry struct {
+	addrs []netip.Addr
+	err   error
+
+	expires time.Time
+}
+
+// dnsLookup is an in-flight lookup shared by concurrent callers for the same host.
+type dnsLookup struct {
+	done chan struct{}
+
+	addrs []netip.Addr
+	err   error
+}
+
+// Resolver resolves host names for an [HttpClient] ([OptTransResolver]) with static overrides (like curl
+// --resolve), optional custom nameservers, and a cache of positive and negative answers. With nameservers it queries
+// them directly and honors record TTLs (and SOA negative TTLs); otherwise it uses the system resolver and caches
+// answers for [DefaultDnsCacheTtl]. Fresh lookups are logged at debug level and reported to [httptrace.ClientTrace]
+// DNS hooks. Connections to hosts with IPv4 and IPv6 addresses race the two families (Happy Eyeballs). A Resolver
+// is safe for concurrent use and may be shared between clients.
+type Resolver struct {
+	overrides   map[string][]netip.Addr
+	nameservers []string
+
+	cacheEnabled bool
+
+	minTtl      time.Duration
+	maxTtl      time.Duration
+	negativeTtl time.Duration
+
+	mutex sync.Mutex
+
+	cache    map[string]*dnsCacheEntry
+	inflight map[string]*dnsLookup
+
+	// err records the first invalid configuration value; it is reported when the resolver is installed.
+	err error
+}
+
+// NewResolver returns a caching [Resolver] that uses the system resolver.
+func NewResolver() *Resolver {
+	return &Resolver{
+		overrides: make(map[string][]netip.Addr),
+
+		cacheEnabled: true,
+
+		maxTtl:      DefaultDnsMaxTtl,
+		negativeTtl: DefaultDnsNegativeTtl,
+
+		cache:    make(map[string]*dnsCacheEntry),
+		inflight: make(map[string]*dnsLookup),
+	}
+}
+
+// WithOverride resolves host to addrs without querying DNS. host may carry a port ("api.example.com:443") to
+// override only that port, as with curl --resolve; an entry with a port takes precedence over one without.
+func (p *Resolver) WithOverride(host string, addrs ...string) *Resolver {
+	parsed := make([]netip.Addr, 0, len(addrs))
+
+	for _, addr := range addrs {
+		ip, err := netip.ParseAddr(strings.Trim(addr, "[]"))
+		if err != nil {
+			if p.err == nil {
+				p.err = fmt.Errorf("thttp: invalid resolver override address %q for %s", addr, host)
+			}
+
+			continue
+		}
+
+		parsed = append(parsed, ip.Unmap())
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.overrides[strings.ToLower(host)] = parsed
+
+	return p
+}
+
+// WithNameservers queries the given DNS servers ("ip" or "ip:port", port 53 by default), in order, instead of the
+// system resolver.
+func (p *Resolver) WithNameservers(nameservers ...string) *Resolver {
+	p.nameservers = make([]string, 0, len(nameservers))
+
+	for _, nameserver := range nameservers {
+		_, _, err := net.SplitHostPort(nameserver)
+		if err != nil {
+			nameserver = net.JoinHostPort(strings.Trim(nameserver, "[]"), "53")
+		}
+
+		p.nameservers = append(p.nameservers, nameserver)
+	}
+
+	return p
+}
+
+// WithCache enables or disables caching (enabled by default).
+func (p *Resolver) WithCache(enabled bool) *Resolver {
+	p.cacheEnabled = enabled
+
+	return p
+}
+
+// WithCacheTtl clamps the TTL of cached answers to [minTtl, maxTtl] (default [0, [DefaultDnsMaxTtl]]); a zero
+// maxTtl removes the upper bound.
+func (p *Resolver) WithCacheTtl(minTtl time.Duration, maxTtl time.Duration) *Resolver {
+	p.minTtl = minTtl
+	p.maxTtl = maxTtl
+
+	return p
+}
+
+// WithNegativeTtl sets how long failed lookups (no such host, no addresses) are cached when the server gives no SOA
+// negative TTL (default [DefaultDnsNegativeTtl]); 0 disables negative caching.
+func (p *Resolver) WithNegativeTtl(negativeTtl time.Duration) *Resolver {
+	p.negativeTtl = negativeTtl
+
+	return p
+}
+
+// ClearCache drops all cached answers.
+func (p *Resolver) ClearCache() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.cache = make(map[string]*dnsCacheEntry)
+}
+
+// clampTtl applies the configured TTL bounds.
+func (p *Resolver) clampTtl(ttl time.Duration) time.Duration {
+	if ttl < p.minTtl {
+		ttl = p.minTtl
+	}
+
+	if p.maxTtl > 0 && ttl > p.maxTtl {
+		ttl = p.maxTtl
+	}
+
+	return ttl
+}
+
+// override returns the static addresses for host and port, if any.
+func (p *Resolver) override(host string, port string) ([]netip.Addr, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	host = strings.ToLower(host)
+
+	addrs, ok := p.overrides[net.JoinHostPort(host, port)]
+	if ok == true {
+		return addrs, true
+	}
+
+	addrs, ok = p.overrides[host]
+
+	return addrs, ok
+}
+
+// LookupNetIP returns the addresses of host, IPv4 first, from the overrides, the cache, or DNS.
+func (p *Resolver) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	return p.resolve(ctx, host, "")
+}
+
+// resolve looks up host for a connection to port.
+func (p *Resolver) resolve(ctx context.Context, host string, port string) ([]netip.Addr, error) {
+	ip, err := netip.ParseAddr(host)
+	if err == nil {
+		return []netip.Addr{ip.Unmap()}, nil
+	}
+
+	addrs, ok := p.override(host, port)
+	if ok == true {
+		return addrs, nil
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+
+	addrs, coalesced, err := p.lookupCached(ctx, host)
+
+	if trace != nil && trace.DNSDone != nil {
+		ipAddrs := make([]net.IPAddr, 0, len(addrs))
+		for _, addr := range addrs {
+			ipAddrs = append(ipAddrs, net.IPAddr{IP: addr.AsSlice(), Zone: addr.Zone()})
+		}
+
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: ipAddrs, Err: err, Coalesced: coalesced})
+	}
+
+	return addrs, err
+}
+
+// lookupCached serves host from the cache or joins or starts a lookup; coalesced reports whether the answer did not
+// come from a new lookup.
+func (p *Resolver) lookupCached(ctx context.Context, host string) ([]netip.Addr, bool, error) {
+	p.mutex.Lock()
+
+	entry, ok := p.cache[host]
+	if ok == true && time.Now().Before(entry.expires) {
+		p.mutex.Unlock()
+
+		return entry.addrs, true, entry.err
+	}
+
+	lookup, ok := p.inflight[host]
+	if ok == false {
+		lookup = &dnsLookup{done: make(chan struct{})}
+		p.inflight[host] = lookup
+
+		// the lookup outlives a caller that gives up, so later callers can still use its answer
+		go p.runLookup(context.WithoutCancel(ctx), host, lookup)
+	}
+
+	p.mutex.Unlock()
+
+	select {
+	case <-lookup.done:
+		return lookup.addrs, ok, lookup.err
+	case <-ctx.Done():
+		return nil, ok, ctx.Err()
+	}
+}
+
+// runLookup performs a lookup, caches its answer, and releases the waiting callers.
+func (p *Resolver) runLookup(ctx context.Context, host string, lookup *dnsLookup) {
+	// query on a fresh context so the caller's trace hooks do not see the nameserver connections
+	lookupCtx, cancel := context.WithTimeout(context.Background(), 2*dnsQueryTimeout)
+	defer cancel()
+
+	addrs, ttl, err := p.lookup(lookupCtx, host)
+
+	if err == nil {
+		tlog.D(ctx).Detailf("dns.host: %s", host).Detailf("dns.addrs: %v", addrs).
+			Detailf("dns.ttl: %s", ttl).Msg("thttp resolved host")
+	}
+
+	p.mutex.Lock()
+
+	if p.cacheEnabled && ttl > 0 {
+		p.cache[host] = &dnsCacheEntry{
+			addrs: addrs,
+			err:   err,
+
+			expires: time.Now().Add(ttl),
+		}
+	}
+
+	delete(p.inflight, host)
+
+	p.mutex.Unlock()
+
+	lookup.addrs = addrs
+	lookup.err = err
+
+	close(lookup.done)
+}
+
+// lookup resolves host and returns the answer with its cache TTL (0 when it must not be cached).
+func (p *Resolver) lookup(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	if len(p.nameservers) == 0 {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			var dnsError *net.DNSError
+			if errors.As(err, &dnsError) && dnsError.IsNotFound {
+				return nil, p.negativeTtl, err
+			}
+
+			return nil, 0, err
+		}
+
+		return sortAddrs(addrs), p.clampTtl(DefaultDnsCacheTtl), nil
+	}
+
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: "invalid host name", Name: host}
+	}
+
+	type result struct {
+		addrs []netip.Addr
+		ttl   time.Duration
+		err   error
+	}
+
+	results := make(chan result, 2)
+
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		go func(qtype dnsmessage.Type) {
+			addrs, ttl, err := p.query(ctx, name, qtype)
+
+			results <- result{addrs: addrs, ttl: ttl, err: err}
+		}(qtype)
+	}
+
+	addrs := make([]netip.Addr, 0)
+
+	ttl := time.Duration(math.MaxInt64)
+	negativeTtl := time.Duration(math.MaxInt64)
+
+	var errs []error
+
+	notFound := 0
+
+	for i := 0; i < 2; i++ {
+		result := <-results
+
+		if result.err != nil {
+			var dnsError *net.DNSError
+			if errors.As(result.err, &dnsError) && dnsError.IsNotFound {
+				notFound++
+
+				negativeTtl = min(negativeTtl, result.ttl)
+			}
+
+			errs = append(errs, result.err)
+
+			continue
+		}
+
+		addrs = append(addrs, result.addrs...)
+
+		ttl = min(ttl, result.ttl)
+	}
+
+	if len(addrs) > 0 {
+		return sortAddrs(addrs), p.clampTtl(ttl), nil
+	}
+
+	if notFound == 2 {
+		// prefer the SOA negative TTL from the server, bounded like positive answers
+		if p.negativeTtl <= 0 {
+			negativeTtl = 0
+		} else if negativeTtl == 0 {
+			negativeTtl = p.negativeTtl
+		} else if p.maxTtl > 0 && negativeTtl > p.maxTtl {
+			negativeTtl = p.maxTtl
+		}
+
+		return nil, negativeTtl, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return nil, 0, errors.Join(errs...)
+}
+
+// sortAddrs orders IPv4 addresses before IPv6 ones, keeping the order within each family.
+func sortAddrs(addrs []netip.Addr) []netip.Addr {
+	sorted := make([]netip.Addr, 0, len(addrs))
+
+	for _, addr := range addrs {
+		if addr.Unmap().Is4() {
+			sorted = append(sorted, addr.Unmap())
+		}
+	}
+
+	for _, addr := range addrs {
+		if addr.Unmap().Is4() == false {
+			sorted = append(sorted, addr)
+		}
+	}
+
+	return sorted
+}
+
+// query asks the nameservers in order for qtype records of name. A missing name or record type is returned as a
+// not-found [net.DNSError] with the negative TTL from the SOA record, or 0 when there is none.
+func (p *Resolver) query(ctx context.Context, name dnsmessage.Name, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	var lastErr error
+
+	for _, nameserver := range p.nameservers {
+		msg, err := dnsExchange(ctx, nameserver, name, qtype)
+		if err != nil {
+			lastErr = err
+
+			continue
+		}
+
+		return dnsAnswer(msg, name, qtype)
+	}
+
+	return nil, 0, lastErr
+}
+
+// dnsAnswer extracts the addresses (following CNAMEs) and TTL from msg.
+func dnsAnswer(msg *dnsmessage.Message, name dnsmessage.Name, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	host := strings.TrimSuffix(name.String(), ".")
+
+	if msg.RCode != dnsmessage.RCodeSuccess && msg.RCode != dnsmessage.RCodeNameError {
+		return nil, 0, &net.DNSError{Err: "server returned " + msg.RCode.String(), Name: host, IsTemporary: true}
+	}
+
+	names := map[string]bool{strings.ToLower(name.String()): true}
+
+	addrs := make([]netip.Addr, 0)
+
+	ttl := uint32(math.MaxUint32)
+
+	if msg.RCode == dnsmessage.RCodeSuccess {
+		for _, answer := range msg.Answers {
+			if names[strings.ToLower(answer.Header.Name.String())] == false {
+				continue
+			}
+
+			switch body := answer.Body.(type) {
+			case *dnsmessage.CNAMEResource:
+				names[strings.ToLower(body.CNAME.String())] = true
+			case *dnsmessage.AResource:
+				if qtype != dnsmessage.TypeA {
+					continue
+				}
+
+				addrs = append(addrs, netip.AddrFrom4(body.A))
+			case *dnsmessage.AAAAResource:
+				if qtype != dnsmessage.TypeAAAA {
+					continue
+				}
+
+				addrs = append(addrs, netip.AddrFrom16(body.AAAA))
+			default:
+				continue
+			}
+
+			ttl = min(ttl, answer.Header.TTL)
+		}
+	}
+
+	if len(addrs) > 0 {
+		return addrs, time.Duration(ttl) * time.Second, nil
+	}
+
+	// RFC 2308: the negative TTL is the smaller of the SOA TTL and its MINIMUM field
+	negativeTtl := time.Duration(0)
+
+	for _, authority := range msg.Authorities {
+		soa, ok := authority.Body.(*dnsmessage.SOAResource)
+		if ok == true {
+			negativeTtl = time.Duration(min(authority.Header.TTL, soa.MinTTL)) * time.Second
+		}
+	}
+
+	return nil, negativeTtl, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// dnsExchange sends one query to nameserver over UDP, retrying over TCP when the answer is truncated.
+func dnsExchange(ctx context.Context, nameserver string, name dnsmessage.Name, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
+	defer cancel()
+
+	var id [2]byte
+
+	_, err := rand.Read(id[:])
+	if err != nil {
+		return nil, err
+	}
+
+	question := dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}
+
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID: binary.BigEndian.Uint16(id[:]),
+
+			RecursionDesired: true,
+		},
+		Questions: []dnsmessage.Question{question},
+	}
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := dnsRoundTrip(ctx, "udp", nameserver, packed, query.Header.ID, question)
+	if err == nil && msg.Truncated == false {
+		return msg, nil
+	}
+
+	if err != nil && errors.Is(err, errDnsTruncated) == false {
+		return nil, err
+	}
+
+	return dnsRoundTrip(ctx, "tcp", nameserver, packed, query.Header.ID, question)
+}
+
+// errDnsTruncated is not returned to callers; it triggers the TCP retry.
+var errDnsTruncated = errors.New("thttp: truncated DNS response")
+
+// dnsRoundTrip writes packed to nameserver over network and reads the matching response.
+func dnsRoundTrip(ctx context.Context, network string, nameserver string, packed []byte, id uint16, question dnsmessage.Question) (*dnsmessage.Message, error) {
+	dialer := &net.Dialer{}
+
+	conn, err := dialer.DialContext(ctx, network, nameserver)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if ok == true {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		framed := make([]byte, 2+len(packed))
+		binary.BigEndian.PutUint16(framed, uint16(len(packed)))
+		copy(framed[2:], packed)
+
+		_, err = conn.Write(framed)
+		if err != nil {
+			return nil, err
+		}
+
+		var length [2]byte
+
+		_, err = io.ReadFull(conn, length[:])
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, binary.BigEndian.Uint16(length[:]))
+
+		_, err = io.ReadFull(conn, data)
+		if err != nil {
+			return nil, err
+		}
+
+		return parseDnsResponse(data, id, question)
+	}
+
+	_, err = conn.Write(packed)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, dnsUdpSize)
+
+	// skip stray datagrams until the matching response arrives or the deadline passes
+	for {
+		n, err := conn.Read(data)
+		if err != nil {
+			return nil, err
+		}
+
+		msg, err := parseDnsResponse(data[:n], id, question)
+		if err == nil && msg.Truncated == true {
+			return nil, errDnsTruncated
+		}
+
+		if err == nil {
+			return msg, nil
+		}
+	}
+}
+
+// parseDnsResponse unpacks data and checks that it answers the query with id and question.
+func parseDnsResponse(data []byte, id uint16, question dnsmessage.Question) (*dnsmessage.Message, error) {
+	msg := &dnsmessage.Message{}
+
+	err := msg.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.ID != id || msg.Response == false || len(msg.Questions) != 1 ||
+		strings.EqualFold(msg.Questions[0].Name.String(), question.Name.String()) == false ||
+		msg.Questions[0].Type != question.Type {
+		return nil, errors.New("thttp: mismatched DNS response")
+	}
+
+	return msg, nil
+}
+
+// dialContext wraps dial so host names are resolved by the resolver. For "tcp", when the host has addresses of both
+// families, the family of the first address is dialed and the other is raced against it after [dnsFallbackDelay] or
+// as soon as the first fails (Happy Eyeballs, RFC 8305); within a family, addresses are tried in order.
+func (p *Resolver) dialContext(dial func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, err := p.resolve(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates := make([]netip.Addr, 0, len(addrs))
+		for _, addr := range addrs {
+			if (network == "tcp4" && addr.Is4() == false) || (network == "tcp6" && addr.Is6() == false) {
+				continue
+			}
+
+			candidates = append(candidates, addr)
+		}
+
+		if len(candidates) == 0 {
+			return nil, &net.DNSError{Err: "no suitable address", Name: host, IsNotFound: true}
+		}
+
+		if network == "tcp" {
+			primaries := make([]netip.Addr, 0, len(candidates))
+			fallbacks := make([]netip.Addr, 0)
+
+			for _, addr := range candidates {
+				if addr.Is4() == candidates[0].Is4() {
+					primaries = append(primaries, addr)
+				} else {
+					fallbacks = append(fallbacks, addr)
+				}
+			}
+
+			if len(fallbacks) > 0 {
+				return dialParallel(ctx, dial, network, port, primaries, fallbacks)
+			}
+		}
+
+		return dialSerial(ctx, dial, network, port, candidates)
+	}
+}
+
+// dialSerial dials addrs in order and returns the first connection, or the last error.
+func dialSerial(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error), network string,
+	port string, addrs []netip.Addr) (net.Conn, error) {
+	var lastErr error
+
+	for _, addr := range addrs {
+		conn, err := dial(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+
+		lastErr = err
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// dialParallel dials primaries and, after [dnsFallbackDelay] or once primaries fail, fallbacks concurrently. It
+// returns the first connection, closing one the other race may still establish, or the error of the primaries.
+func dialParallel(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error), network string,
+	port string, primaries []netip.Addr, fallbacks []netip.Addr) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+
+	// buffered so the losing race never blocks
+	results := make(chan dialResult, 2)
+
+	race := func(addrs []netip.Addr, primary bool) {
+		conn, err := dialSerial(ctx, dial, network, port, addrs)
+
+		results <- dialResult{conn: conn, err: err, primary: primary}
+	}
+
+	go race(primaries, true)
+
+	timer := time.NewTimer(dnsFallbackDelay)
+	defer timer.Stop()
+
+	var primaryErr, fallbackErr error
+
+	started := false
+	pending := 1
+
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if started == false {
+				started = true
+				pending++
+
+				go race(fallbacks, false)
+			}
+		case result := <-results:
+			pending--
+
+			if result.err == nil {
+				if pending > 0 {
+					go func() {
+						loser := <-results
+						if loser.conn != nil {
+							_ = loser.conn.Close()
+						}
+					}()
+				}
+
+				return result.conn, nil
+			}
+
+			if result.primary == true {
+				primaryErr = result.err
+			} else {
+				fallbackErr = result.err
+			}
+
+			if result.primary == true && started == false {
+				started = true
+				pending++
+
+				go race(fallbacks, false)
+			}
+		}
+	}
+
+	if primaryErr != nil {
+		return nil, primaryErr
+	}
+
+	return nil, fallbackErr
+}
@@ -0,0 +1,654 @@
+package thttp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsStubRecord is what [dnsStub] answers for one name.
+type dnsStubRecord struct {
+	a     []string
+	aaaa  []string
+	cname string
+	ttl   uint32
+
+	// nx answers NXDOMAIN; nx and empty records carry an SOA with soaTtl and soaMin.
+	nx     bool
+	soaTtl uint32
+	soaMin uint32
+
+	// truncate sets the TC bit on UDP answers, so the full answer is only available over TCP.
+	truncate bool
+	delay    time.Duration
+}
+
+// dnsStub is a local DNS server answering A and AAAA queries over UDP and TCP on the same port from a fixed set of
+// records, and counting the queries it receives.
+type dnsStub struct {
+	addr    string
+	records map[string]*dnsStubRecord
+
+	mutex   sync.Mutex
+	queries map[string]int
+}
+
+// newDnsStub starts a DNS stub serving records, keyed by lowercase name without the trailing dot.
+func newDnsStub(t *testing.T, records map[string]*dnsStubRecord) *dnsStub {
+	t.Helper()
+
+	stub := &dnsStub{
+		records: records,
+		queries: make(map[string]int),
+	}
+
+	var udpConn net.PacketConn
+	var tcpListener net.Listener
+
+	for i := 0; i < 10 && tcpListener == nil; i++ {
+		var err error
+
+		udpConn, err = net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("ListenPacket: %v", err)
+		}
+
+		tcpListener, err = net.Listen("tcp", udpConn.LocalAddr().String())
+		if err != nil {
+			_ = udpConn.Close()
+		}
+	}
+
+	if tcpListener == nil {
+		t.Fatalf("no port free for both UDP and TCP")
+	}
+
+	stub.addr = udpConn.LocalAddr().String()
+
+	t.Cleanup(func() {
+		_ = udpConn.Close()
+		_ = tcpListener.Close()
+	})
+
+	go func() {
+		data := make([]byte, 512)
+
+		for {
+			n, addr, err := udpConn.ReadFrom(data)
+			if err != nil {
+				return
+			}
+
+			query := append([]byte(nil), data[:n]...)
+
+			go func() {
+				reply := stub.reply(query, "udp")
+				if reply != nil {
+					_, _ = udpConn.WriteTo(reply, addr)
+				}
+			}()
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := tcpListener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				var length [2]byte
+
+				_, err := io.ReadFull(conn, length[:])
+				if err != nil {
+					return
+				}
+
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+
+				_, err = io.ReadFull(conn, query)
+				if err != nil {
+					return
+				}
+
+				reply := stub.reply(query, "tcp")
+
+				framed := binary.BigEndian.AppendUint16(nil, uint16(len(reply)))
+				_, _ = conn.Write(append(framed, reply...))
+			}()
+		}
+	}()
+
+	return stub
+}
+
+// count returns the number of queries received over network for qtype records of name.
+func (p *dnsStub) count(network string, qtype dnsmessage.Type, name string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.queries[network+" "+qtype.String()+" "+name]
+}
+
+// reply answers the packed query received over network.
+func (p *dnsStub) reply(packed []byte, network string) []byte {
+	query := &dnsmessage.Message{}
+
+	err := query.Unpack(packed)
+	if err != nil || len(query.Questions) != 1 {
+		return nil
+	}
+
+	question := query.Questions[0]
+	name := strings.ToLower(strings.TrimSuffix(question.Name.String(), "."))
+
+	p.mutex.Lock()
+	p.queries[network+" "+question.Type.String()+" "+name]++
+	p.mutex.Unlock()
+
+	msg := &dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:       query.ID,
+			Response: true,
+
+			Authoritative: true,
+		},
+		Questions: query.Questions,
+	}
+
+	record, ok := p.records[name]
+	if ok == true && record.delay > 0 {
+		time.Sleep(record.delay)
+	}
+
+	switch {
+	case ok == false || record.nx == true:
+		msg.RCode = dnsmessage.RCodeNameError
+	case record.truncate == true && network == "udp":
+		msg.Truncated = true
+	default:
+		owner := question.Name
+
+		if record.cname != "" {
+			target := dnsmessage.MustNewName(record.cname + ".")
+
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: owner, Class: dnsmessage.ClassINET, TTL: record.ttl},
+				Body:   &dnsmessage.CNAMEResource{CNAME: target},
+			})
+
+			owner, record = target, p.records[record.cname]
+		}
+
+		for _, ip := range record.a {
+			if question.Type == dnsmessage.TypeA {
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: owner, Class: dnsmessage.ClassINET, TTL: record.ttl},
+					Body:   &dnsmessage.AResource{A: netip.MustParseAddr(ip).As4()},
+				})
+			}
+		}
+
+		for _, ip := range record.aaaa {
+			if question.Type == dnsmessage.TypeAAAA {
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: owner, Class: dnsmessage.ClassINET, TTL: record.ttl},
+					Body:   &dnsmessage.AAAAResource{AAAA: netip.MustParseAddr(ip).As16()},
+				})
+			}
+		}
+	}
+
+	if len(msg.Answers) == 0 && msg.Truncated == false && record != nil && record.soaTtl > 0 {
+		msg.Authorities = append(msg.Authorities, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("test."), Class: dnsmessage.ClassINET, TTL: record.soaTtl},
+			Body: &dnsmessage.SOAResource{
+				NS:     dnsmessage.MustNewName("ns.test."),
+				MBox:   dnsmessage.MustNewName("admin.test."),
+				MinTTL: record.soaMin,
+			},
+		})
+	}
+
+	reply, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+
+	return reply
+}
+
+// cacheExpiry returns how long the answer for host cached by resolver remains valid, or 0 when it is not cached.
+func cacheExpiry(resolver *Resolver, host string) time.Duration {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+
+	entry, ok := resolver.cache[host]
+	if ok == false {
+		return 0
+	}
+
+	return time.Until(entry.expires)
+}
+
+func TestResolverOverride(t *testing.T) {
+	resolver := NewResolver().
+		WithOverride("API.test", "10.0.0.1", "10.0.0.2").
+		WithOverride("api.test:8443", "[::1]").
+		WithOverride("mapped.test", "::ffff:10.0.0.3")
+
+	tests := map[string]struct {
+		host string
+		port string
+		want string
+	}{
+		"without port":   {host: "api.test", port: "443", want: "[10.0.0.1 10.0.0.2]"},
+		"with port":      {host: "api.test", port: "8443", want: "[::1]"},
+		"case":           {host: "API.TEST", port: "80", want: "[10.0.0.1 10.0.0.2]"},
+		"ipv4-mapped":    {host: "mapped.test", port: "80", want: "[10.0.0.3]"},
+		"ip literal":     {host: "192.0.2.1", port: "80", want: "[192.0.2.1]"},
+		"lookup no port": {host: "api.test", want: "[10.0.0.1 10.0.0.2]"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addrs, err := resolver.resolve(context.Background(), test.host, test.port)
+			if err != nil || fmt.Sprint(addrs) != test.want {
+				t.Errorf("resolve(%s, %s) = %v, %v, want %s", test.host, test.port, addrs, err, test.want)
+			}
+		})
+	}
+
+	server := newContentServer(t, http.StatusOK, ContentTypeTextPlain, []byte("ok"))
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	client := NewHttpClient().WithResolver(NewResolver().WithOverride("svc.test", "127.0.0.1"))
+
+	text, err := getText(client, "http://svc.test:"+port)
+	if err != nil || text != "ok" {
+		t.Errorf("Get through an override = %q, %v", text, err)
+	}
+
+	_, err = getText(NewHttpClient().WithResolver(NewResolver().WithOverride("svc.test", "localhost")), server.URL)
+	if err == nil || strings.Contains(err.Error(), "thttp: invalid resolver override address") == false {
+		t.Errorf("Get with an invalid override = %v, want the resolver error", err)
+	}
+}
+
+func TestResolverNameservers(t *testing.T) {
+	stub := newDnsStub(t, map[string]*dnsStubRecord{
+		"dual.test": {a: []string{"192.0.2.1", "192.0.2.2"}, aaaa: []string{"2001:db8::1"}, ttl: 60},
+		"www.test":  {cname: "edge.test", ttl: 60},
+		"edge.test": {a: []string{"192.0.2.9"}, ttl: 30},
+		"big.test":  {a: []string{"192.0.2.3"}, ttl: 60, truncate: true},
+	})
+
+	resolver := NewResolver().WithNameservers(stub.addr)
+
+	tests := map[string]string{
+		"dual.test": "[192.0.2.1 192.0.2.2 2001:db8::1]",
+		"www.test":  "[192.0.2.9]",
+		"big.test":  "[192.0.2.3]",
+	}
+
+	for host, want := range tests {
+		addrs, err := resolver.LookupNetIP(context.Background(), host)
+		if err != nil || fmt.Sprint(addrs) != want {
+			t.Errorf("LookupNetIP(%s) = %v, %v, want %s", host, addrs, err, want)
+		}
+	}
+
+	// a truncated UDP answer is retried over TCP
+	if stub.count("udp", dnsmessage.TypeA, "big.test") != 1 || stub.count("tcp", dnsmessage.TypeA, "big.test") != 1 {
+		t.Errorf("big.test queries = %d over UDP, %d over TCP, want 1 each", stub.count("udp", dnsmessage.TypeA, "big.test"),
+			stub.count("tcp", dnsmessage.TypeA, "big.test"))
+	}
+
+	// the CNAME chain is answered with the smallest TTL
+	expiry := cacheExpiry(resolver, "www.test")
+	if expiry <= 25*time.Second || expiry > 30*time.Second {
+		t.Errorf("www.test cache expiry = %s, want 30s", expiry)
+	}
+
+	// cached answers are served without querying again
+	_, _ = resolver.LookupNetIP(context.Background(), "DUAL.test.")
+
+	if stub.count("udp", dnsmessage.TypeA, "dual.test") != 1 {
+		t.Errorf("dual.test queries = %d, want 1", stub.count("udp", dnsmessage.TypeA, "dual.test"))
+	}
+
+	resolver.ClearCache()
+
+	_, _ = resolver.LookupNetIP(context.Background(), "dual.test")
+
+	if stub.count("udp", dnsmessage.TypeA, "dual.test") != 2 {
+		t.Errorf("dual.test queries after ClearCache = %d, want 2", stub.count("udp", dnsmessage.TypeA, "dual.test"))
+	}
+}
+
+func TestResolverTtl(t *testing.T) {
+	stub := newDnsStub(t, map[string]*dnsStubRecord{
+		"short.test":  {a: []string{"192.0.2.1"}, ttl: 1},
+		"long.test":   {a: []string{"192.0.2.2"}, ttl: 86400},
+		"nx.test":     {nx: true, soaTtl: 600, soaMin: 120},
+		"nodata.test": {soaTtl: 40, soaMin: 300},
+		"nosoa.test":  {nx: true},
+	})
+
+	resolver := NewResolver().WithNameservers(stub.addr).WithCacheTtl(time.Minute, time.Hour)
+
+	tests := map[string]struct {
+		min      time.Duration
+		max      time.Duration
+		notFound bool
+	}{
+		"short.test":  {min: 59 * time.Second, max: time.Minute},
+		"long.test":   {min: 59 * time.Minute, max: time.Hour},
+		"nx.test":     {min: 119 * time.Second, max: 120 * time.Second, notFound: true},
+		"nodata.test": {min: 39 * time.Second, max: 40 * time.Second, notFound: true},
+		"nosoa.test":  {min: DefaultDnsNegativeTtl - time.Second, max: DefaultDnsNegativeTtl, notFound: true},
+	}
+
+	for host, test := range tests {
+		t.Run(host, func(t *testing.T) {
+			_, err := resolver.LookupNetIP(context.Background(), host)
+
+			var dnsErr *net.DNSError
+			if test.notFound == true && (errors.As(err, &dnsErr) == false || dnsErr.IsNotFound == false) {
+				t.Errorf("LookupNetIP = %v, want a not-found error", err)
+			}
+
+			expiry := cacheExpiry(resolver, host)
+			if expiry < test.min || expiry > test.max {
+				t.Errorf("cache expiry = %s, want between %s and %s", expiry, test.min, test.max)
+			}
+
+			// negative answers are served from the cache too
+			_, cachedErr := resolver.LookupNetIP(context.Background(), host)
+			if stub.count("udp", dnsmessage.TypeA, host) != 1 || fmt.Sprint(cachedErr) != fmt.Sprint(err) {
+				t.Errorf("second lookup = %v after %d queries, want the cached answer", cachedErr, stub.count("udp", dnsmessage.TypeA, host))
+			}
+		})
+	}
+
+	// without negative caching failures are looked up again
+	resolver = NewResolver().WithNameservers(stub.addr).WithNegativeTtl(0)
+
+	for i := 0; i < 2; i++ {
+		_, _ = resolver.LookupNetIP(context.Background(), "nosoa.test")
+	}
+
+	if stub.count("udp", dnsmessage.TypeA, "nosoa.test") != 3 {
+		t.Errorf("nosoa.test queries = %d, want 3", stub.count("udp", dnsmessage.TypeA, "nosoa.test"))
+	}
+
+	resolver = NewResolver().WithNameservers(stub.addr).WithCache(false)
+
+	for i := 0; i < 2; i++ {
+		_, _ = resolver.LookupNetIP(context.Background(), "short.test")
+	}
+
+	if stub.count("udp", dnsmessage.TypeA, "short.test") != 3 {
+		t.Errorf("short.test queries without a cache = %d, want 3", stub.count("udp", dnsmessage.TypeA, "short.test"))
+	}
+}
+
+func TestResolverSingleFlight(t *testing.T) {
+	stub := newDnsStub(t, map[string]*dnsStubRecord{
+		"slow.test": {a: []string{"192.0.2.1"}, ttl: 60, delay: 100 * time.Millisecond},
+	})
+
+	resolver := NewResolver().WithNameservers(stub.addr)
+
+	var coalesced atomic.Int32
+
+	trace := &httptrace.ClientTrace{
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Coalesced == true {
+				coalesced.Add(1)
+			}
+		},
+	}
+
+	ctx := httptrace.WithClientTrace(context.Background(), trace)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			addrs, err := resolver.resolve(ctx, "slow.test", "80")
+			if err != nil || fmt.Sprint(addrs) != "[192.0.2.1]" {
+				t.Errorf("resolve = %v, %v", addrs, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if stub.count("udp", dnsmessage.TypeA, "slow.test") != 1 || coalesced.Load() != 9 {
+		t.Errorf("queries = %d, coalesced = %d, want 1 and 9", stub.count("udp", dnsmessage.TypeA, "slow.test"), coalesced.Load())
+	}
+
+	// a caller that gives up does not cancel the shared lookup
+	resolver.ClearCache()
+
+	canceledCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := resolver.LookupNetIP(canceledCtx, "slow.test")
+	if errors.Is(err, context.DeadlineExceeded) == false {
+		t.Errorf("LookupNetIP = %v, want the context error", err)
+	}
+
+	addrs, err := resolver.LookupNetIP(context.Background(), "slow.test")
+	if err != nil || len(addrs) != 1 || stub.count("udp", dnsmessage.TypeA, "slow.test") != 2 {
+		t.Errorf("LookupNetIP = %v, %v after %d queries, want the shared answer", addrs, err, stub.count("udp", dnsmessage.TypeA, "slow.test"))
+	}
+}
+
+func TestDnsAnswer(t *testing.T) {
+	name := dnsmessage.MustNewName("www.Test.")
+
+	resource := func(owner string, ttl uint32, body dnsmessage.ResourceBody) dnsmessage.Resource {
+		return dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(owner), Class: dnsmessage.ClassINET, TTL: ttl},
+			Body:   body,
+		}
+	}
+
+	msg := &dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true},
+		Answers: []dnsmessage.Resource{
+			resource("www.test.", 300, &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("cdn.test.")}),
+			resource("cdn.test.", 200, &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("EDGE.test.")}),
+			resource("edge.test.", 100, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}),
+			resource("edge.test.", 100, &dnsmessage.AAAAResource{AAAA: netip.MustParseAddr("2001:db8::1").As16()}),
+			resource("other.test.", 10, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 66}}),
+		},
+	}
+
+	addrs, ttl, err := dnsAnswer(msg, name, dnsmessage.TypeA)
+	if err != nil || fmt.Sprint(addrs) != "[192.0.2.1]" || ttl != 100*time.Second {
+		t.Errorf("dnsAnswer = %v, %s, %v, want the A record at the end of the chain", addrs, ttl, err)
+	}
+
+	msg.RCode = dnsmessage.RCodeServerFailure
+
+	_, _, err = dnsAnswer(msg, name, dnsmessage.TypeA)
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) == false || dnsErr.IsTemporary == false {
+		t.Errorf("dnsAnswer of SERVFAIL = %v, want a temporary error", err)
+	}
+}
+
+// fakeDial returns a dial function that answers each address after its delay, with a connection, or with an error
+// when the address is listed in failures. Addresses without a delay block until the context ends. Every dial and
+// connection is recorded in dials and conns.
+func fakeDial(delays map[string]time.Duration, failures map[string]bool, dials chan<- string, conns chan<- *dialTestConn) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		dials <- address
+
+		delay, ok := delays[address]
+		if ok == false {
+			<-ctx.Done()
+
+			return nil, ctx.Err()
+		}
+
+		time.Sleep(delay)
+
+		if failures[address] == true {
+			return nil, fmt.Errorf("dial %s refused", address)
+		}
+
+		conn := &dialTestConn{addr: address}
+		conns <- conn
+
+		return conn, nil
+	}
+}
+
+func TestDialParallel(t *testing.T) {
+	primaries := []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")}
+	fallbacks := []netip.Addr{netip.MustParseAddr("192.0.2.1")}
+
+	tests := map[string]struct {
+		delays   map[string]time.Duration
+		failures map[string]bool
+		want     string
+		wantErr  string
+		minTime  time.Duration
+		maxTime  time.Duration
+	}{
+		"primary wins": {
+			delays: map[string]time.Duration{"[2001:db8::1]:443": 0, "192.0.2.1:443": 0},
+			want:   "[2001:db8::1]:443", maxTime: dnsFallbackDelay / 2,
+		},
+		"second primary": {
+			delays:   map[string]time.Duration{"[2001:db8::1]:443": 0, "[2001:db8::2]:443": 0},
+			failures: map[string]bool{"[2001:db8::1]:443": true},
+			want:     "[2001:db8::2]:443", maxTime: dnsFallbackDelay / 2,
+		},
+		"stalled primaries": {
+			delays: map[string]time.Duration{"192.0.2.1:443": 0},
+			want:   "192.0.2.1:443", minTime: dnsFallbackDelay, maxTime: 2 * dnsFallbackDelay,
+		},
+		"failed primaries": {
+			delays:   map[string]time.Duration{"[2001:db8::1]:443": 0, "[2001:db8::2]:443": 0, "192.0.2.1:443": 0},
+			failures: map[string]bool{"[2001:db8::1]:443": true, "[2001:db8::2]:443": true},
+			want:     "192.0.2.1:443", maxTime: dnsFallbackDelay / 2,
+		},
+		"all failed": {
+			delays:   map[string]time.Duration{"[2001:db8::1]:443": 0, "[2001:db8::2]:443": 0, "192.0.2.1:443": 0},
+			failures: map[string]bool{"[2001:db8::1]:443": true, "[2001:db8::2]:443": true, "192.0.2.1:443": true},
+			wantErr:  "dial [2001:db8::2]:443 refused",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dials := make(chan string, 10)
+			conns := make(chan *dialTestConn, 10)
+
+			start := time.Now()
+
+			conn, err := dialParallel(context.Background(), fakeDial(test.delays, test.failures, dials, conns), "tcp", "443", primaries, fallbacks)
+
+			elapsed := time.Since(start)
+
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("dialParallel = %v, want %q", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil || conn.(*dialTestConn).addr != test.want {
+				t.Fatalf("dialParallel = %v, %v, want %s", conn, err, test.want)
+			}
+
+			if elapsed < test.minTime || elapsed > test.maxTime {
+				t.Errorf("dialParallel took %s, want between %s and %s", elapsed, test.minTime, test.maxTime)
+			}
+
+			// the primary family is always dialed first
+			if first := <-dials; first != "[2001:db8::1]:443" {
+				t.Errorf("first dial = %s, want the first primary", first)
+			}
+		})
+	}
+}
+
+func TestDialParallelClosesLoser(t *testing.T) {
+	primaries := []netip.Addr{netip.MustParseAddr("2001:db8::1")}
+	fallbacks := []netip.Addr{netip.MustParseAddr("192.0.2.1")}
+
+	// the primary connects just after the fallback race has started, and the fallback shortly after that
+	delays := map[string]time.Duration{
+		"[2001:db8::1]:443": dnsFallbackDelay + 20*time.Millisecond,
+		"192.0.2.1:443":     50 * time.Millisecond,
+	}
+
+	dials := make(chan string, 10)
+	conns := make(chan *dialTestConn, 10)
+
+	conn, err := dialParallel(context.Background(), fakeDial(delays, nil, dials, conns), "tcp", "443", primaries, fallbacks)
+	if err != nil || conn.(*dialTestConn).addr != "[2001:db8::1]:443" {
+		t.Fatalf("dialParallel = %v, %v, want the primary", conn, err)
+	}
+
+	<-conns
+
+	var loser *dialTestConn
+
+	select {
+	case loser = <-conns:
+	case <-time.After(time.Second):
+		t.Fatalf("the fallback did not connect")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for loser.closed.Load() == false && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if loser.closed.Load() == false || conn.(*dialTestConn).closed.Load() == true {
+		t.Errorf("loser closed = %t, winner closed = %t, want only the loser closed", loser.closed.Load(),
+			conn.(*dialTestConn).closed.Load())
+	}
+}
+
+func TestResolverDialFallsBackToOtherFamily(t *testing.T) {
+	server := newContentServer(t, http.StatusOK, ContentTypeTextPlain, []byte("ok"))
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// nothing listens on the IPv6 address, so the IPv4 race connects
+	client := NewHttpClient().WithResolver(NewResolver().WithOverride("dual.test", "::1", "127.0.0.1"))
+
+	text, err := getText(client, "http://dual.test:"+port)
+	if err != nil || text != "ok" {
+		t.Errorf("Get = %q, %v, want ok", text, err)
+	}
+}
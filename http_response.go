@@ -1,8 +1,6 @@
 package thttp
 
 import (
-	"compress/flate"
-	"compress/gzip"
 	"errors"
 	"io"
 	"net/http"
@@ -11,42 +9,72 @@ import (
 // Response wraps [http.Response] with helpers to read the body.
 type Response struct {
 	*http.Response
+
+	// maxDecodedResponseSize bounds bodies decoded by the read helpers ([OptMaxDecodedResponseSize]); 0 means unlimited.
+	maxDecodedResponseSize int64
+
+	// decompressTransOption is the [OptTransDecompress] codec set; when set, the read helpers leave codings it
+	// disables encoded, as the decompression layer does.
+	decompressTransOption *DecompressTransOption
 }
 
-// ToBytes reads and decompresses the response body when Content-Encoding is gzip or deflate,
-// returns the HTTP status code, raw body bytes, and any read or decompression error.
-func (p *Response) ToBytes() (int, []byte, error) {
+// openBody returns a reader over the response body that decodes Content-Encoding gzip, deflate, br, or zstd (stacked
+// encodings are decoded in reverse order of application) and enforces [OptMaxDecodedResponseSize] on the decoded
+// bytes. Bodies already decoded by [OptTransDecompress], with an unknown coding, or with a coding disabled in the
+// [OptTransDecompress] codec set are returned undecoded. Closing the returned reader closes the response body.
+func (p *Response) openBody() (io.ReadCloser, error) {
 	if p == nil || p.Response == nil {
-		return 0, nil, errors.New("thttp: response object is unavailable")
+		return nil, errors.New("thttp: response object is unavailable")
 	}
 
-	statusCode := p.StatusCode
-
 	if p.Body == nil {
-		return statusCode, nil, errors.New("thttp: response body is unavailable")
+		return nil, errors.New("thttp: response body is unavailable")
 	}
 
-	defer p.Body.Close()
+	encodings := parseContentEncoding(p.Header)
+	if len(encodings) == 0 || supportsContentEncodings(encodings) == false {
+		return p.Body, nil
+	}
 
-	var reader io.ReadCloser
-	var err error
+	if p.decompressTransOption != nil && p.decompressTransOption.supportsAll(encodings) == false {
+		return p.Body, nil
+	}
 
-	switch p.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err = gzip.NewReader(p.Body)
-		if err != nil {
-			return statusCode, nil, err
-		}
-	case "deflate":
-		reader = flate.NewReader(p.Body)
-	default:
-		reader = p.Body
+	reader, err := newDecodeChain(p.Body, encodings)
+	if err != nil {
+		return nil, err
 	}
 
-	if reader != p.Body {
-		defer reader.Close()
+	if p.maxDecodedResponseSize > 0 {
+		reader = newLimitedReadCloser(reader, p.maxDecodedResponseSize, true)
 	}
 
+	return reader, nil
+}
+
+// ToBytes reads the response body and decodes it when Content-Encoding lists gzip, deflate, br, or zstd (stacked
+// encodings are decoded in reverse order of application). It returns the HTTP status code, body bytes, and any read
+// or decompression error. Bodies already decoded by [OptTransDecompress] are returned as-is, and bodies with an
+// unknown coding or a coding disabled in the [OptTransDecompress] codec set are returned undecoded. Reads beyond [OptMaxResponseSize] or [OptMaxDecodedResponseSize] fail with
+// [*ResponseSizeLimitError].
+func (p *Response) ToBytes() (int, []byte, error) {
+	if p == nil || p.Response == nil {
+		return 0, nil, errors.New("thttp: response object is unavailable")
+	}
+
+	statusCode := p.StatusCode
+
+	reader, err := p.openBody()
+	if err != nil {
+		if p.Body != nil {
+			_ = p.Body.Close()
+		}
+
+		return statusCode, nil, err
+	}
+
+	defer reader.Close()
+
 	body, err := io.ReadAll(reader)
 	if err != nil {
 		return statusCode, nil, err
@@ -55,12 +83,42 @@ func (p *Response) ToBytes() (int, []byte, error) {
 	return statusCode, body, nil
 }
 
-// ToString returns the response body as a UTF-8 string via [Response.ToBytes].
+// supportsContentEncodings reports whether every coding in encodings can be decoded by [newDecodeReader].
+func supportsContentEncodings(encodings []string) bool {
+	for _, encoding := range encodings {
+		switch encoding {
+		case ContentEncodingGzip, "x-gzip", ContentEncodingDeflate, ContentEncodingBrotli, ContentEncodingZstd:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ToString returns the response body as a UTF-8 string via [Response.ToBytes], subject to the same size limits.
+// Bodies in a declared or sniffed charset (for example GBK or Shift_JIS) are transcoded leniently, replacing
+// undecodable bytes; see [Response.ToText] to fail instead.
 func (p *Response) ToString() (int, string, error) {
+	return p.ToText(false)
+}
+
+// ToText reads the body via [Response.ToBytes] and transcodes it to UTF-8 using the Content-Type charset parameter,
+// or a byte order mark, HTML meta tag, or XML declaration when no charset is declared. Valid UTF-8 without any
+// charset information is returned unchanged; other undeclared bodies are decoded as detected from their content,
+// falling back to windows-1252 (which cannot identify GBK or Shift_JIS, so such bodies need a declared charset).
+// When strict is true, undeclared invalid UTF-8, unknown charsets, and undecodable input return a [*CharsetError]
+// instead of being guessed or silently replaced.
+func (p *Response) ToText(strict bool) (int, string, error) {
 	statusCode, bytes, err := p.ToBytes()
 	if err != nil {
 		return statusCode, "", err
 	}
 
-	return statusCode, string(bytes), nil
+	text, err := decodeText(p.Header.Get("Content-Type"), bytes, strict)
+	if err != nil {
+		return statusCode, "", err
+	}
+
+	return statusCode, text, nil
 }
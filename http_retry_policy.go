@@ -29,6 +29,18 @@ var (
 // baseRetryPolicy implements the default retry eligibility rules shared by higher-level policies.
 func baseRetryPolicy(resp *http.Response, err error) (bool, error) {
 	if err != nil {
+		// Don't retry if the server certificate violated a pin.
+		var pinViolationError *PinViolationError
+		if errors.As(err, &pinViolationError) {
+			return false, err
+		}
+
+		// Don't retry if a dial guard refused the destination.
+		var blockedDestinationError *BlockedDestinationError
+		if errors.As(err, &blockedDestinationError) {
+			return false, err
+		}
+
 		var val *url.Error
 		if errors.As(err, &val) {
 			// Don't retry if the error was due to too many redirects.
@@ -0,0 +1,91 @@
+package thttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// RequestSigner signs outgoing requests. It is installed with [OptTransSign] and applied by the signing transport to
+// a fresh copy of the request on every attempt, so signatures (and their timestamps) are recomputed when the retry
+// transport replays a request. Implementations must be safe for concurrent use.
+type RequestSigner interface {
+	// SignRequest adds signature headers (or query parameters) to req. It may replace req.Body, for example after
+	// reading it to hash the payload.
+	SignRequest(req *http.Request) error
+}
+
+// readRequestBody returns the body of req without consuming it: replayable bodies are read through
+// [http.Request.GetBody], and others are read once and replaced (together with GetBody) by an in-memory copy.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		defer body.Close()
+
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	return data, nil
+}
+
+// signTransport applies a [RequestSigner] to a copy of each request. Requests to hosts outside the
+// [credentialScope], such as cross-host redirect targets, are sent unsigned.
+type signTransport struct {
+	transport http.RoundTripper
+
+	signer RequestSigner
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (p *signTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if inCredentialScope(req) == false {
+		return p.transport.RoundTrip(req)
+	}
+
+	signReq := req.Clone(req.Context())
+
+	err := p.signer.SignRequest(signReq)
+	if err != nil {
+		if signReq.Body != nil {
+			_ = signReq.Body.Close()
+		}
+
+		return nil, fmt.Errorf("thttp: request signing failed: %w", err)
+	}
+
+	return p.transport.RoundTrip(signReq)
+}
+
+// wrapSignTransport returns a signing decorator around transport, or [http.DefaultTransport] when transport is nil.
+func wrapSignTransport(transport http.RoundTripper, signer RequestSigner) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	signTransport := &signTransport{
+		transport: transport,
+		signer:    signer,
+	}
+
+	return signTransport
+}
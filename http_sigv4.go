@@ -0,0 +1,372 @@
+package thttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	_url "net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SigV4UnsignedPayload is the payload hash sent when the body is not hashed, for streams and presigned URLs.
+	SigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// sigV4Algorithm is the SigV4 signing algorithm identifier.
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4DateFormat = "20060102"
+
+	// sigV4MaxPresignExpiry is the longest validity AWS accepts for a presigned URL.
+	sigV4MaxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// AwsCredentials are AWS-style access keys.
+type AwsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+
+	// SessionToken is set for temporary credentials and sent as X-Amz-Security-Token.
+	SessionToken string
+}
+
+// CredentialsProvider supplies the credentials used for each signature, so rotating or temporary credentials are
+// picked up without rebuilding the signer. Implementations must be safe for concurrent use.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (*AwsCredentials, error)
+}
+
+// StaticCredentialsProvider always returns the same credentials.
+type StaticCredentialsProvider struct {
+	credentials AwsCredentials
+}
+
+// NewStaticCredentialsProvider returns a provider for fixed keys; sessionToken may be empty.
+func NewStaticCredentialsProvider(accessKeyId string, secretAccessKey string, sessionToken string) *StaticCredentialsProvider {
+	return &StaticCredentialsProvider{
+		credentials: AwsCredentials{
+			AccessKeyId:     accessKeyId,
+			SecretAccessKey: secretAccessKey,
+
+			SessionToken: sessionToken,
+		},
+	}
+}
+
+// Credentials implements [CredentialsProvider].
+func (p *StaticCredentialsProvider) Credentials(ctx context.Context) (*AwsCredentials, error) {
+	credentials := p.credentials
+
+	return &credentials, nil
+}
+
+// EnvCredentialsProvider reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN on every call.
+type EnvCredentialsProvider struct{}
+
+// Credentials implements [CredentialsProvider].
+func (EnvCredentialsProvider) Credentials(ctx context.Context) (*AwsCredentials, error) {
+	credentials := &AwsCredentials{
+		AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if credentials.AccessKeyId == "" || credentials.SecretAccessKey == "" {
+		return nil, errors.New("thttp: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	return credentials, nil
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4. It implements [RequestSigner]; install it with
+// [HttpClient.WithRequestSigner] so every attempt, including retries, is signed with a fresh timestamp.
+type SigV4Signer struct {
+	provider CredentialsProvider
+
+	region  string
+	service string
+
+	unsignedPayload bool
+
+	// now returns the signing time; it is replaceable for deterministic signatures.
+	now func() time.Time
+}
+
+// NewSigV4Signer returns a signer for service (for example "s3" or "execute-api") in region. Payloads are hashed
+// unless [SigV4Signer.WithUnsignedPayload] is set.
+func NewSigV4Signer(provider CredentialsProvider, region string, service string) *SigV4Signer {
+	return &SigV4Signer{
+		provider: provider,
+
+		region:  region,
+		service: service,
+
+		now: time.Now,
+	}
+}
+
+// WithUnsignedPayload sends [SigV4UnsignedPayload] instead of hashing the body, so streaming uploads are neither
+// buffered nor read twice. The service must accept unsigned payloads (S3 does over HTTPS).
+func (p *SigV4Signer) WithUnsignedPayload(unsignedPayload bool) *SigV4Signer {
+	p.unsignedPayload = unsignedPayload
+
+	return p
+}
+
+// sigV4Escape percent-encodes every byte except the RFC 3986 unreserved characters, as SigV4 requires.
+func sigV4Escape(value string) string {
+	builder := strings.Builder{}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+
+	return builder.String()
+}
+
+// canonicalUri encodes each path segment; services other than S3 expect the encoded path to be encoded again.
+func (p *SigV4Signer) canonicalUri(u *_url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+
+	// split the escaped path so encoded slashes stay inside their segment
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		unescaped, err := _url.PathUnescape(segment)
+		if err == nil {
+			segment = unescaped
+		}
+
+		segments[i] = sigV4Escape(segment)
+
+		if p.service != "s3" {
+			segments[i] = sigV4Escape(segments[i])
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts and encodes the query parameters.
+func canonicalQuery(query _url.Values) string {
+	pairs := make([]string, 0, len(query))
+
+	for key, vals := range query {
+		for _, val := range vals {
+			pairs = append(pairs, sigV4Escape(key)+"="+sigV4Escape(val))
+		}
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// isSigV4SignedHeader reports whether a request header is included in the signature. Headers the transport may
+// add or rewrite (User-Agent, Accept-Encoding) are left out.
+func isSigV4SignedHeader(key string) bool {
+	key = strings.ToLower(key)
+
+	switch key {
+	case "host", "content-type", "content-md5", "content-encoding", "range":
+		return true
+	default:
+		return strings.HasPrefix(key, "x-amz-")
+	}
+}
+
+// canonicalHeaders returns the canonical header block and the signed header list for header and host.
+func canonicalHeaders(header http.Header, host string) (string, string) {
+	values := map[string]string{"host": host}
+
+	for key, vals := range header {
+		if isSigV4SignedHeader(key) == false {
+			continue
+		}
+
+		trimmed := make([]string, 0, len(vals))
+		for _, val := range vals {
+			trimmed = append(trimmed, strings.Join(strings.Fields(val), " "))
+		}
+
+		values[strings.ToLower(key)] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	builder := strings.Builder{}
+	for _, name := range names {
+		builder.WriteString(name + ":" + values[name] + "\n")
+	}
+
+	return builder.String(), strings.Join(names, ";")
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// signature computes the credential scope and signature of canonicalRequest at signTime.
+func (p *SigV4Signer) signature(credentials *AwsCredentials, signTime time.Time, canonicalRequest string) (string, string) {
+	date := signTime.Format(sigV4DateFormat)
+	scope := date + "/" + p.region + "/" + p.service + "/aws4_request"
+
+	stringToSign := sigV4Algorithm + "\n" + signTime.Format(sigV4TimeFormat) + "\n" + scope + "\n" +
+		sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSha256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSha256(key, p.region)
+	key = hmacSha256(key, p.service)
+	key = hmacSha256(key, "aws4_request")
+
+	return scope, hex.EncodeToString(hmacSha256(key, stringToSign))
+}
+
+// requestHost returns the Host header value the transport will send.
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+
+	return req.URL.Host
+}
+
+// SignRequest implements [RequestSigner] by setting X-Amz-Date, X-Amz-Content-Sha256, X-Amz-Security-Token (for
+// temporary credentials), and Authorization. It fails rather than replace an Authorization header that is already
+// set, for example by an [Authenticator].
+func (p *SigV4Signer) SignRequest(req *http.Request) error {
+	if req.Header.Get("Authorization") != "" {
+		return errors.New("thttp: SigV4 signing refused: request already has an Authorization header")
+	}
+
+	credentials, err := p.provider.Credentials(req.Context())
+	if err != nil {
+		return err
+	}
+
+	payloadHash := SigV4UnsignedPayload
+
+	if p.unsignedPayload == false {
+		body, err := readRequestBody(req)
+		if err != nil {
+			return err
+		}
+
+		payloadHash = sha256Hex(body)
+	}
+
+	signTime := p.now().UTC()
+
+	req.Header.Set("X-Amz-Date", signTime.Format(sigV4TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+
+	headers, signedHeaders := canonicalHeaders(req.Header, requestHost(req))
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		p.canonicalUri(req.URL),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope, signature := p.signature(credentials, signTime, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, credentials.AccessKeyId, scope, signedHeaders, signature))
+
+	return nil
+}
+
+// Presign returns url with SigV4 query authentication valid for expires (at most 7 days), for handing to clients
+// that cannot sign, such as browsers downloading from S3. header lists extra headers the eventual request must send
+// with the same values (it may be nil); the payload is always [SigV4UnsignedPayload].
+func (p *SigV4Signer) Presign(ctx context.Context, method string, url string, header http.Header, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > sigV4MaxPresignExpiry {
+		return "", fmt.Errorf("thttp: invalid presign expiry %s: want (0, %s]", expires, sigV4MaxPresignExpiry)
+	}
+
+	u, err := _url.Parse(url)
+	if err != nil {
+		return "", err
+	}
+
+	credentials, err := p.provider.Credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	signTime := p.now().UTC()
+
+	if header == nil {
+		header = http.Header{}
+	}
+
+	headers, signedHeaders := canonicalHeaders(header, u.Host)
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", credentials.AccessKeyId+"/"+signTime.Format(sigV4DateFormat)+"/"+p.region+"/"+
+		p.service+"/aws4_request")
+	query.Set("X-Amz-Date", signTime.Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+
+	if credentials.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		p.canonicalUri(u),
+		canonicalQuery(query),
+		headers,
+		signedHeaders,
+		SigV4UnsignedPayload,
+	}, "\n")
+
+	_, signature := p.signature(credentials, signTime, canonicalRequest)
+
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+
+	return u.String(), nil
+}
@@ -0,0 +1,301 @@
+package thttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSseRetryDelay is the reconnection delay used until the server sends a retry field.
+	DefaultSseRetryDelay = 3 * time.Second
+
+	// sseMaxLineSize bounds a single line of the event stream.
+	sseMaxLineSize = 1 << 20
+)
+
+// SseEvent is one event dispatched from a Server-Sent Events stream.
+type SseEvent struct {
+	// Id is the last event ID in effect when the event was dispatched (sent as Last-Event-ID on reconnect).
+	Id string
+	// Event is the event type; it defaults to "message".
+	Event string
+	// Data is the event payload, with multi-line data joined by "\n".
+	Data string
+
+	// Retry is the reconnection delay announced in this event's block, or 0 when none was sent.
+	Retry time.Duration
+}
+
+// SseResponseError is returned by [HttpClient.Sse] when the server answers with a status or content type that
+// does not carry an event stream. The stream is not reconnected after it.
+type SseResponseError struct {
+	StatusCode  int
+	ContentType string
+}
+
+// Error implements [error].
+func (p *SseResponseError) Error() string {
+	return fmt.Sprintf("thttp: unexpected event stream response (status %d, content type %q)", p.StatusCode, p.ContentType)
+}
+
+type streamRequestKey struct{}
+
+// withStreamRequest marks ctx as carrying a long-lived stream, so the logging transport skips the slow-request log.
+func withStreamRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamRequestKey{}, true)
+}
+
+// isStreamRequest reports whether ctx was marked by [withStreamRequest].
+func isStreamRequest(ctx context.Context) bool {
+	stream, _ := ctx.Value(streamRequestKey{}).(bool)
+
+	return stream
+}
+
+// scanSseLines is a [bufio.SplitFunc] splitting on CRLF, LF, or a lone CR, as the event stream format requires.
+func scanSseLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	index := bytes.IndexAny(data, "\r\n")
+	if index >= 0 {
+		if data[index] == '\r' {
+			if index+1 < len(data) {
+				if data[index+1] == '\n' {
+					return index + 2, data[:index], nil
+				}
+
+				return index + 1, data[:index], nil
+			}
+
+			// a trailing CR may be the first half of a CRLF
+			if atEOF == false {
+				return 0, nil, nil
+			}
+		}
+
+		return index + 1, data[:index], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// sseParser accumulates fields between blank lines following the WHATWG event stream interpretation rules.
+type sseParser struct {
+	lastEventId string
+	retryDelay  time.Duration
+
+	eventType string
+	data      strings.Builder
+	hasData   bool
+	retry     time.Duration
+}
+
+// processLine handles one line and returns the event dispatched by a blank line, or nil.
+func (p *sseParser) processLine(line string) *SseEvent {
+	if line == "" {
+		return p.dispatch()
+	}
+
+	if strings.HasPrefix(line, ":") {
+		return nil
+	}
+
+	field, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+
+	switch field {
+	case "event":
+		p.eventType = value
+	case "data":
+		p.data.WriteString(value)
+		p.data.WriteByte('\n')
+		p.hasData = true
+	case "id":
+		if strings.ContainsRune(value, 0) == false {
+			p.lastEventId = value
+		}
+	case "retry":
+		millis, err := strconv.ParseUint(value, 10, 63)
+		if err == nil {
+			p.retry = time.Duration(millis) * time.Millisecond
+			p.retryDelay = p.retry
+		}
+	}
+
+	return nil
+}
+
+// dispatch builds the pending event and resets the per-event buffers. Blocks without data dispatch nothing.
+func (p *sseParser) dispatch() *SseEvent {
+	defer func() {
+		p.eventType = ""
+		p.data.Reset()
+		p.hasData = false
+		p.retry = 0
+	}()
+
+	if p.hasData == false {
+		return nil
+	}
+
+	event := &SseEvent{
+		Id:    p.lastEventId,
+		Event: p.eventType,
+		Data:  strings.TrimSuffix(p.data.String(), "\n"),
+
+		Retry: p.retry,
+	}
+
+	if event.Event == "" {
+		event.Event = "message"
+	}
+
+	return event
+}
+
+// connectSse opens one event stream connection, sending Last-Event-ID when the parser holds one.
+func (p *HttpClient) connectSse(ctx context.Context, url string, requestOption *RequestOption, parser *sseParser) (*Response, error) {
+	streamOption := requestOption.clone()
+
+	// the stream lives until either side closes it, so the total request timeout does not apply
+	streamOption.WithTimeout(0)
+	streamOption.WithHeader("Accept", ContentTypeTextEventStream)
+	streamOption.WithHeader("Cache-Control", "no-cache")
+
+	if parser.lastEventId != "" {
+		streamOption.WithHeader("Last-Event-ID", parser.lastEventId)
+	}
+
+	resp, err := p.Do(withStreamRequest(ctx), "GET", url, streamOption, nil)
+	if err != nil {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK || mediaType != ContentTypeTextEventStream {
+		_ = resp.Body.Close()
+
+		return nil, &SseResponseError{StatusCode: resp.StatusCode, ContentType: resp.Header.Get("Content-Type")}
+	}
+
+	return resp, nil
+}
+
+// Sse subscribes to a Server-Sent Events stream with GET, applying the client's headers, hooks, logging, retry, and
+// other options as [HttpClient.Do] does (the total timeout is disabled for the stream). Events are yielded as they
+// are dispatched. When the connection fails or drops, Sse yields the error (a clean end of stream yields nothing),
+// waits for the server-announced retry delay (initially [DefaultSseRetryDelay]), and reconnects with Last-Event-ID.
+// A [*SseResponseError] (non-200 status or non-event-stream content type) ends the iteration, as does HTTP 204,
+// cancelling ctx, or breaking out of the loop; the response body is always closed.
+//
+//	for event, err := range client.Sse(ctx, url, nil) {
+//		if err != nil {
+//			continue // transient; break to stop reconnecting
+//		}
+//		...
+//	}
+func (p *HttpClient) Sse(ctx context.Context, url string, requestOption *RequestOption) iter.Seq2[*SseEvent, error] {
+	return func(yield func(*SseEvent, error) bool) {
+		parser := &sseParser{
+			retryDelay: DefaultSseRetryDelay,
+		}
+
+		for {
+			resp, err := p.connectSse(ctx, url, requestOption, parser)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				var sseResponseErr *SseResponseError
+				if errors.As(err, &sseResponseErr) {
+					if sseResponseErr.StatusCode != http.StatusNoContent {
+						yield(nil, err)
+					}
+
+					return
+				}
+
+				if yield(nil, err) == false {
+					return
+				}
+			} else {
+				ok := p.readSse(resp, parser, yield)
+				if ok == false {
+					return
+				}
+			}
+
+			timer := time.NewTimer(parser.retryDelay)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+
+				return
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// readSse yields the events of one connection and reports whether the caller should reconnect. A read error is
+// yielded unless ctx was cancelled; a pending, undispatched event is discarded when the stream ends.
+func (p *HttpClient) readSse(resp *Response, parser *sseParser, yield func(*SseEvent, error) bool) bool {
+	reader, err := resp.openBody()
+	if err != nil {
+		_ = resp.Body.Close()
+
+		return yield(nil, err)
+	}
+
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 4096), sseMaxLineSize)
+	scanner.Split(scanSseLines)
+
+	first := true
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first == true {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+
+		event := parser.processLine(line)
+		if event != nil && yield(event, nil) == false {
+			return false
+		}
+	}
+
+	// an event still pending when the stream ends is discarded
+	parser.dispatch()
+
+	err = scanner.Err()
+	if err != nil && resp.Request.Context().Err() == nil {
+		return yield(nil, err)
+	}
+
+	return resp.Request.Context().Err() == nil
+}
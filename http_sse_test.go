@@ -0,0 +1,205 @@
+package thttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sseServer serves one scripted event stream per connection and records the Last-Event-ID of each.
+type sseServer struct {
+	*httptest.Server
+
+	streams []string
+
+	lastEventIds []string
+
+	sync.Mutex
+}
+
+// newSseServer returns a started [sseServer]; connections beyond the scripted streams get HTTP 204.
+func newSseServer(t *testing.T, streams ...string) *sseServer {
+	t.Helper()
+
+	server := &sseServer{streams: streams}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != ContentTypeTextEventStream {
+			t.Errorf("Accept = %q", r.Header.Get("Accept"))
+		}
+
+		server.Lock()
+		server.lastEventIds = append(server.lastEventIds, r.Header.Get("Last-Event-ID"))
+		index := len(server.lastEventIds) - 1
+		server.Unlock()
+
+		if index >= len(server.streams) {
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentTypeTextEventStream)
+		_, _ = w.Write([]byte(server.streams[index]))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// received returns the Last-Event-ID header of every connection.
+func (p *sseServer) received() []string {
+	p.Lock()
+	defer p.Unlock()
+
+	return append([]string(nil), p.lastEventIds...)
+}
+
+// collectSse gathers events and errors until the iteration ends.
+func collectSse(ctx context.Context, client *HttpClient, url string) ([]*SseEvent, []error) {
+	events := make([]*SseEvent, 0)
+	errs := make([]error, 0)
+
+	for event, err := range client.Sse(ctx, url, nil) {
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	return events, errs
+}
+
+func TestSseParsing(t *testing.T) {
+	stream := "\ufeff: comment\n" +
+		"data: first\n\n" +
+		"event: update\r\nid: 7\r\ndata: line one\r\ndata:line two\r\n\r\n" +
+		"data: lone cr\r\r" +
+		"id\n" +
+		"data\n\n" +
+		"retry: 5\nretry: oops\n\n" +
+		"id: 8\ndata: never dispatched"
+
+	server := newSseServer(t, stream)
+
+	events, errs := collectSse(context.Background(), NewHttpClient(), server.URL)
+	if len(errs) != 0 {
+		t.Fatalf("errors = %v", errs)
+	}
+
+	want := []*SseEvent{
+		{Event: "message", Data: "first"},
+		{Id: "7", Event: "update", Data: "line one\nline two"},
+		{Id: "7", Event: "message", Data: "lone cr"},
+		{Event: "message", Data: ""},
+	}
+
+	if reflect.DeepEqual(events, want) == false {
+		for _, event := range events {
+			t.Logf("got %+v", event)
+		}
+
+		t.Fatalf("events differ from %d expected events", len(want))
+	}
+
+	// the stream ended cleanly, so the client reconnected once with the last dispatched id and got HTTP 204
+	lastEventIds := server.received()
+	if reflect.DeepEqual(lastEventIds, []string{"", "8"}) == false {
+		t.Errorf("Last-Event-ID per connection = %q", lastEventIds)
+	}
+}
+
+func TestSseReconnect(t *testing.T) {
+	server := newSseServer(t,
+		"retry: 10\nid: 1\ndata: a\n\n",
+		"id: 2\ndata: b\n\n",
+	)
+
+	startAt := time.Now()
+
+	events, errs := collectSse(context.Background(), NewHttpClient(), server.URL)
+	if len(errs) != 0 || len(events) != 2 || events[0].Retry != 10*time.Millisecond || events[1].Data != "b" {
+		t.Fatalf("events = %v, errors = %v", events, errs)
+	}
+
+	// the announced retry delay replaces the 3s default
+	if time.Since(startAt) > DefaultSseRetryDelay {
+		t.Errorf("reconnecting took %s", time.Since(startAt))
+	}
+
+	lastEventIds := server.received()
+	if reflect.DeepEqual(lastEventIds, []string{"", "1", "2"}) == false {
+		t.Errorf("Last-Event-ID per connection = %q", lastEventIds)
+	}
+}
+
+func TestSseResponseError(t *testing.T) {
+	tests := map[string]http.HandlerFunc{
+		"status": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ContentTypeTextEventStream)
+			w.WriteHeader(http.StatusNotFound)
+		},
+		"content type": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ContentTypeApplicationJson)
+			_, _ = w.Write([]byte(`{}`))
+		},
+	}
+
+	for name, handler := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			events, errs := collectSse(context.Background(), NewHttpClient(), server.URL)
+
+			var sseResponseErr *SseResponseError
+			if len(events) != 0 || len(errs) != 1 || errors.As(errs[0], &sseResponseErr) == false {
+				t.Errorf("events = %v, errors = %v, want a single *SseResponseError", events, errs)
+			}
+		})
+	}
+}
+
+func TestSseBreakAndCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeTextEventStream)
+
+		for i := 0; r.Context().Err() == nil; i++ {
+			_, _ = w.Write([]byte("data: tick\n\n"))
+			w.(http.Flusher).Flush()
+
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	count := 0
+
+	for _, err := range NewHttpClient().Sse(context.Background(), server.URL, nil) {
+		if err != nil {
+			t.Fatalf("Sse: %v", err)
+		}
+
+		count++
+		if count == 3 {
+			break
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	events, errs := collectSse(ctx, NewHttpClient(), server.URL)
+	if len(events) == 0 || len(errs) != 0 {
+		t.Errorf("events = %d, errors = %v, want events and a silent end on cancel", len(events), errs)
+	}
+}
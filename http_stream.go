@@ -0,0 +1,147 @@
+package thttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// openJsonStream opens the (decompressed) body of resp and returns a JSON decoder configured from option.
+func openJsonStream(resp *Response, option *DecodeOption) (io.ReadCloser, *json.Decoder, error) {
+	if resp == nil || resp.Response == nil {
+		return nil, nil, errors.New("thttp: response object is unavailable")
+	}
+
+	if option == nil {
+		option = NewDecodeOption()
+	}
+
+	reader, err := resp.openBody()
+	if err != nil {
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+
+		return nil, nil, err
+	}
+
+	decoder := json.NewDecoder(reader)
+
+	if option.disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if option.useNumber {
+		decoder.UseNumber()
+	}
+
+	return reader, decoder, nil
+}
+
+// streamDecodeError wraps err in a [*DecodeError] carrying the status and content type of resp.
+func streamDecodeError(resp *Response, err error) error {
+	return &DecodeError{StatusCode: resp.StatusCode, ContentType: resp.Header.Get("Content-Type"), Err: err}
+}
+
+// StreamNdjson decodes a newline-delimited JSON (application/x-ndjson, JSON Lines) response one value at a time,
+// decompressing it as [Response.ToBytes] does and honoring option like [Response.ToJson]; blank lines are skipped.
+// The first error is yielded as a [*DecodeError] and ends the iteration. The body is closed when the iteration ends,
+// including on an early break, so the sequence can be ranged over only once.
+//
+//	for record, err := range thttp.StreamNdjson[Record](resp, nil) {
+//		...
+//	}
+func StreamNdjson[T any](resp *Response, option *DecodeOption) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		reader, decoder, err := openJsonStream(resp, option)
+		if err != nil {
+			if resp != nil && resp.Response != nil {
+				err = streamDecodeError(resp, err)
+			}
+
+			yield(zero, err)
+
+			return
+		}
+
+		defer reader.Close()
+
+		for {
+			var item T
+
+			err := decoder.Decode(&item)
+			if err == io.EOF {
+				return
+			}
+
+			if err != nil {
+				yield(zero, streamDecodeError(resp, err))
+
+				return
+			}
+
+			if yield(item, nil) == false {
+				return
+			}
+		}
+	}
+}
+
+// StreamJsonArray decodes the elements of a top-level JSON array response one at a time, without buffering the
+// whole array. It behaves like [StreamNdjson]; a body that is not a JSON array yields a [*DecodeError].
+func StreamJsonArray[T any](resp *Response, option *DecodeOption) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		reader, decoder, err := openJsonStream(resp, option)
+		if err != nil {
+			if resp != nil && resp.Response != nil {
+				err = streamDecodeError(resp, err)
+			}
+
+			yield(zero, err)
+
+			return
+		}
+
+		defer reader.Close()
+
+		token, err := decoder.Token()
+		if err != nil {
+			yield(zero, streamDecodeError(resp, err))
+
+			return
+		}
+
+		delim, ok := token.(json.Delim)
+		if ok == false || delim != '[' {
+			yield(zero, streamDecodeError(resp, fmt.Errorf("want JSON array, got %v", token)))
+
+			return
+		}
+
+		for decoder.More() {
+			var item T
+
+			err := decoder.Decode(&item)
+			if err != nil {
+				yield(zero, streamDecodeError(resp, err))
+
+				return
+			}
+
+			if yield(item, nil) == false {
+				return
+			}
+		}
+
+		_, err = decoder.Token()
+		if err != nil {
+			yield(zero, streamDecodeError(resp, err))
+		}
+	}
+}
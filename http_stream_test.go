@@ -0,0 +1,126 @@
+package thttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type streamTestRecord struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// getStream sends a GET to a server answering with body and returns the response.
+func getStream(t *testing.T, body string) *Response {
+	t.Helper()
+
+	server := newContentServer(t, http.StatusOK, "application/x-ndjson", []byte(body))
+
+	return getContent(t, server)
+}
+
+// collectStream gathers the values and the first error of seq.
+func collectStream[T any](seq func(yield func(T, error) bool)) ([]T, error) {
+	values := make([]T, 0)
+
+	for value, err := range seq {
+		if err != nil {
+			return values, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func TestStreamNdjson(t *testing.T) {
+	resp := getStream(t, "{\"id\":1,\"name\":\"a\"}\n\n{\"id\":2,\"name\":\"b\"}\r\n{\"id\":3}")
+
+	records, err := collectStream(StreamNdjson[streamTestRecord](resp, nil))
+	if err != nil {
+		t.Fatalf("StreamNdjson: %v", err)
+	}
+
+	want := []streamTestRecord{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}, {Id: 3}}
+	if reflect.DeepEqual(records, want) == false {
+		t.Errorf("records = %+v, want %+v", records, want)
+	}
+}
+
+func TestStreamNdjsonError(t *testing.T) {
+	resp := getStream(t, "{\"id\":1}\n{\"id\":\"two\"}\n{\"id\":3}\n")
+
+	records, err := collectStream(StreamNdjson[streamTestRecord](resp, nil))
+
+	var decodeErr *DecodeError
+	if len(records) != 1 || errors.As(err, &decodeErr) == false || decodeErr.StatusCode != http.StatusOK {
+		t.Errorf("records = %+v, err = %v, want one record and a *DecodeError", records, err)
+	}
+
+	resp = getStream(t, "{\"id\":1,\"extra\":true}\n")
+
+	_, err = collectStream(StreamNdjson[streamTestRecord](resp, NewDecodeOption().WithDisallowUnknownFields(true)))
+	if errors.As(err, &decodeErr) == false {
+		t.Errorf("err = %v, want the unknown field rejected", err)
+	}
+}
+
+func TestStreamJsonArray(t *testing.T) {
+	resp := getStream(t, ` [ {"id":1,"name":"a"}, {"id":2} ] `)
+
+	records, err := collectStream(StreamJsonArray[streamTestRecord](resp, nil))
+	if err != nil || reflect.DeepEqual(records, []streamTestRecord{{Id: 1, Name: "a"}, {Id: 2}}) == false {
+		t.Errorf("StreamJsonArray = %+v, %v", records, err)
+	}
+
+	for name, body := range map[string]string{
+		"object":    `{"id":1}`,
+		"truncated": `[{"id":1},`,
+		"empty":     ``,
+	} {
+		_, err := collectStream(StreamJsonArray[streamTestRecord](getStream(t, body), nil))
+
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) == false {
+			t.Errorf("%s: err = %v, want *DecodeError", name, err)
+		}
+	}
+}
+
+func TestStreamClosesBodyOnBreak(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeApplicationJson)
+		_, _ = w.Write([]byte("["))
+
+		// keep streaming until the client goes away
+		for i := 0; r.Context().Err() == nil && i < 100000; i++ {
+			_, _ = w.Write([]byte(`{"id":1},`))
+		}
+	}))
+	defer server.Close()
+
+	resp := getContent(t, server)
+
+	count := 0
+
+	for _, err := range StreamJsonArray[streamTestRecord](resp, nil) {
+		if err != nil {
+			t.Fatalf("StreamJsonArray: %v", err)
+		}
+
+		count++
+		if count == 2 {
+			break
+		}
+	}
+
+	// the body was closed, so reading it again fails
+	_, err := resp.Body.Read(make([]byte, 1))
+	if err == nil {
+		t.Errorf("body is still open after breaking out of the stream")
+	}
+}
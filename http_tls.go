@@ -0,0 +1,671 @@
+package thttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/choveylee/tlog"
+)
+
+const (
+	// DefaultCertReloadInterval is how often [ClientCertReloader] checks its files for changes.
+	DefaultCertReloadInterval = time.Minute
+
+	// DefaultTlsSessionCacheSize is the number of TLS sessions a [TlsProfile] keeps for resumption.
+	DefaultTlsSessionCacheSize = 64
+)
+
+// TlsProfile is a named TLS policy: protocol versions, TLS 1.2 cipher suites, key exchange groups, and the session
+// resumption cache size, applied together with [HttpClient.WithTlsProfile]. TLS 1.3 cipher suites are not
+// configurable in crypto/tls and always use its secure defaults.
+type TlsProfile struct {
+	name string
+
+	minVersion uint16
+	maxVersion uint16
+
+	cipherSuites     []uint16
+	curvePreferences []tls.CurveID
+
+	sessionCacheSize int
+}
+
+// NewModernTlsProfile returns a TLS 1.3 only profile for servers under your control, modeled on the Mozilla
+// "modern" configuration.
+func NewModernTlsProfile() *TlsProfile {
+	return &TlsProfile{
+		name: "modern",
+
+		minVersion: tls.VersionTLS13,
+
+		curvePreferences: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384},
+
+		sessionCacheSize: DefaultTlsSessionCacheSize,
+	}
+}
+
+// NewIntermediateTlsProfile returns a TLS 1.2+ profile limited to forward-secret AEAD cipher suites, modeled on
+// the Mozilla "intermediate" configuration; it suits general use with third-party servers.
+func NewIntermediateTlsProfile() *TlsProfile {
+	return &TlsProfile{
+		name: "intermediate",
+
+		minVersion: tls.VersionTLS12,
+
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		curvePreferences: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384},
+
+		sessionCacheSize: DefaultTlsSessionCacheSize,
+	}
+}
+
+// NewFipsTlsProfile returns a FIPS-like TLS 1.2+ profile restricted to AES-GCM cipher suites and NIST curves. It
+// only narrows the negotiated parameters; it does not make the binary a validated module (see the GOFIPS140 build
+// setting for that).
+func NewFipsTlsProfile() *TlsProfile {
+	return &TlsProfile{
+		name: "fips",
+
+		minVersion: tls.VersionTLS12,
+
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		},
+		curvePreferences: []tls.CurveID{tls.CurveP256, tls.CurveP384},
+
+		sessionCacheSize: DefaultTlsSessionCacheSize,
+	}
+}
+
+// Name returns the profile name, such as "modern".
+func (p *TlsProfile) Name() string {
+	return p.name
+}
+
+// WithMaxVersion caps the protocol version (for example [tls.VersionTLS12]); 0 uses the crypto/tls maximum.
+func (p *TlsProfile) WithMaxVersion(maxVersion uint16) *TlsProfile {
+	p.maxVersion = maxVersion
+
+	return p
+}
+
+// WithSessionCacheSize sets how many sessions are cached for resumption (default [DefaultTlsSessionCacheSize]);
+// 0 disables session resumption.
+func (p *TlsProfile) WithSessionCacheSize(sessionCacheSize int) *TlsProfile {
+	p.sessionCacheSize = sessionCacheSize
+
+	return p
+}
+
+// apply sets the profile parameters on config.
+func (p *TlsProfile) apply(config *tls.Config) {
+	config.MinVersion = p.minVersion
+	config.MaxVersion = p.maxVersion
+
+	config.CipherSuites = p.cipherSuites
+	config.CurvePreferences = p.curvePreferences
+}
+
+// tlsSettings holds the TLS options of an [HttpClient] separately so that each option can change without discarding
+// the others; [tlsSettings.build] composes them into the transport [tls.Config].
+type tlsSettings struct {
+	// base is the [OptTransTlsConfig] value; it is cloned, never modified.
+	base *tls.Config
+
+	// unsafe is the [OptTransUnsafeTls] value, or nil to keep base.InsecureSkipVerify.
+	unsafe *bool
+
+	// profile is the [OptTransTlsProfile] value; sessionCache is created when it is set so rebuilt configurations
+	// keep resuming sessions.
+	profile      *TlsProfile
+	sessionCache tls.ClientSessionCache
+
+	// keyLogWriter is the [OptTransKeyLogWriter] value.
+	keyLogWriter io.Writer
+
+	// rootCAs is the pool built from [OptTransRootCAs], or nil for the base (or system) roots.
+	rootCAs *x509.CertPool
+
+	clientCert *ClientCertReloader
+
+	// pins maps hosts to base64 SPKI pins ([OptTransPins]).
+	pins map[string][]string
+}
+
+// build returns a new [tls.Config] combining the settings.
+func (p *tlsSettings) build() *tls.Config {
+	config := &tls.Config{}
+	if p.base != nil {
+		config = p.base.Clone()
+	}
+
+	if p.profile != nil {
+		p.profile.apply(config)
+
+		config.ClientSessionCache = p.sessionCache
+	}
+
+	if p.keyLogWriter != nil {
+		config.KeyLogWriter = p.keyLogWriter
+	}
+
+	if p.unsafe != nil {
+		config.InsecureSkipVerify = *p.unsafe
+	}
+
+	if p.rootCAs != nil {
+		config.RootCAs = p.rootCAs
+	}
+
+	verifiers := make([]func(tls.ConnectionState) error, 0)
+
+	if p.clientCert != nil {
+		config.Certificates = nil
+		config.GetClientCertificate = p.clientCert.GetClientCertificate
+
+		// the reloaded CA bundle cannot be placed in RootCAs, which is fixed once the transport clones the config,
+		// so the server chain is verified against the current bundle, or the configured roots, in VerifyConnection
+		if p.clientCert.caPath != "" && config.InsecureSkipVerify == false {
+			config.InsecureSkipVerify = true
+
+			verifiers = append(verifiers, p.clientCert.serverVerifier(config.RootCAs))
+		}
+	}
+
+	if len(p.pins) > 0 {
+		pins := make(map[string][]string, len(p.pins))
+		for host, vals := range p.pins {
+			pins[host] = vals
+		}
+
+		verifiers = append(verifiers, pinVerifier(pins))
+	}
+
+	if len(verifiers) > 0 {
+		if config.VerifyConnection != nil {
+			verifiers = append(verifiers, config.VerifyConnection)
+		}
+
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, verifier := range verifiers {
+				err := verifier(state)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	}
+
+	return config
+}
+
+// setProfile stores profile and creates its session cache.
+func (p *tlsSettings) setProfile(profile *TlsProfile) {
+	p.profile = profile
+	p.sessionCache = nil
+
+	if profile != nil && profile.sessionCacheSize > 0 {
+		p.sessionCache = tls.NewLRUClientSessionCache(profile.sessionCacheSize)
+	}
+}
+
+// keyLogFile is a key log file opened by [HttpClient.WithKeyLogFile] and owned by the client.
+type keyLogFile struct {
+	*os.File
+}
+
+// setKeyLogWriter stores writer, closing a key log file the client opened earlier, and warns when key logging is
+// enabled.
+func (p *tlsSettings) setKeyLogWriter(writer io.Writer) {
+	ownedFile, ok := p.keyLogWriter.(*keyLogFile)
+	if ok == true && ownedFile != writer {
+		_ = ownedFile.Close()
+	}
+
+	p.keyLogWriter = writer
+
+	if writer != nil {
+		target := "a custom writer"
+
+		switch file := writer.(type) {
+		case *keyLogFile:
+			target = file.Name()
+		case *os.File:
+			target = file.Name()
+		}
+
+		tlog.W(context.Background()).Msgf("thttp TLS KEY LOGGING IS ENABLED: session secrets are written to %s and "+
+			"anyone holding them can decrypt captured traffic; never enable this in production", target)
+	}
+}
+
+// warnRootCAsMerge logs a warning when both a reloaded CA bundle and [OptTransRootCAs] are set, as servers are then
+// accepted when either verifies them.
+func (p *tlsSettings) warnRootCAsMerge() {
+	if p.clientCert == nil || p.clientCert.caPath == "" || p.rootCAs == nil {
+		return
+	}
+
+	tlog.W(context.Background()).Msgf("thttp both a client certificate CA bundle (%s) and root CAs are set: servers "+
+		"verified by either are trusted", p.clientCert.caPath)
+}
+
+// setPins merges pins into the pinned hosts, validating and normalizing every pin; hosts with no pins are removed.
+// IP literal hosts are rejected: their connections carry no server name to match, so such pins would never apply.
+func (p *tlsSettings) setPins(pins map[string][]string) error {
+	merged := make(map[string][]string, len(p.pins)+len(pins))
+	for host, vals := range p.pins {
+		merged[host] = vals
+	}
+
+	for host, vals := range pins {
+		host = strings.ToLower(host)
+
+		if net.ParseIP(strings.Trim(host, "[]")) != nil {
+			return fmt.Errorf("thttp: invalid pinned host %q: IP literals have no server name, pin \"*\" instead", host)
+		}
+
+		if len(vals) == 0 {
+			delete(merged, host)
+
+			continue
+		}
+
+		normalized := make([]string, 0, len(vals))
+		for _, val := range vals {
+			pin, err := normalizePin(val)
+			if err != nil {
+				return err
+			}
+
+			normalized = append(normalized, pin)
+		}
+
+		merged[host] = normalized
+	}
+
+	p.pins = merged
+
+	return nil
+}
+
+// RootCAsOption configures the CA certificates trusted for server verification ([OptTransRootCAs]). By default the
+// certificates are added to the system pool.
+type RootCAsOption struct {
+	pems  [][]byte
+	files []string
+
+	replaceSystem bool
+}
+
+// NewRootCAsOption returns an empty [RootCAsOption] that extends the system pool.
+func NewRootCAsOption() *RootCAsOption {
+	return &RootCAsOption{}
+}
+
+// WithPem adds the PEM-encoded certificates in data.
+func (p *RootCAsOption) WithPem(data []byte) *RootCAsOption {
+	p.pems = append(p.pems, data)
+
+	return p
+}
+
+// WithFiles adds the PEM-encoded certificates in the files at paths, which are read when the option is applied.
+func (p *RootCAsOption) WithFiles(paths ...string) *RootCAsOption {
+	p.files = append(p.files, paths...)
+
+	return p
+}
+
+// WithReplaceSystem trusts only the configured certificates instead of adding them to the system pool.
+func (p *RootCAsOption) WithReplaceSystem(replaceSystem bool) *RootCAsOption {
+	p.replaceSystem = replaceSystem
+
+	return p
+}
+
+// certPool builds the pool; every PEM source must contain at least one certificate.
+func (p *RootCAsOption) certPool() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	if p.replaceSystem == false {
+		systemPool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("thttp: loading system roots failed: %w", err)
+		}
+
+		pool = systemPool
+	}
+
+	for _, data := range p.pems {
+		_, err := certPoolFromPem(pool, data)
+		if err != nil {
+			return nil, fmt.Errorf("thttp: invalid root CA PEM: %w", err)
+		}
+	}
+
+	for _, path := range p.files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("thttp: reading root CA file failed: %w", err)
+		}
+
+		_, err = certPoolFromPem(pool, data)
+		if err != nil {
+			return nil, fmt.Errorf("thttp: invalid root CA file %s: %w", path, err)
+		}
+	}
+
+	return pool, nil
+}
+
+// verifyServerChain verifies the server certificate chain in state against roots, like the default verification
+// of crypto/tls.
+func verifyServerChain(state tls.ConnectionState, roots *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("thttp: server presented no certificate")
+	}
+
+	// an IP literal host is not sent as SNI, so its name is unavailable here and it could not be matched safely
+	if state.ServerName == "" {
+		return errors.New("thttp: server verification against a reloaded CA bundle requires a DNS host name")
+	}
+
+	options := x509.VerifyOptions{
+		DNSName:       state.ServerName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+
+	for _, cert := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(options)
+
+	return err
+}
+
+// ClientCertReloader serves a client certificate, and optionally a CA bundle for verifying servers, from PEM files
+// and reloads them when they change on disk, so rotated certificates are used for new connections without
+// rebuilding the client or dropping the transport. Files are checked lazily, at most once per interval, during TLS
+// handshakes. A failed reload is logged and counted in the http_client_tls_cert_reload_failures metric, and the
+// previously loaded files stay in use; the expiry of the loaded certificates is exported as
+// http_client_tls_cert_expiry_timestamp.
+type ClientCertReloader struct {
+	certPath string
+	keyPath  string
+	caPath   string
+
+	interval time.Duration
+
+	mutex sync.RWMutex
+
+	cert    *tls.Certificate
+	rootCAs *x509.CertPool
+
+	// modTimes holds the modification times of the loaded files, in path order.
+	modTimes  []time.Time
+	lastCheck time.Time
+}
+
+// NewClientCertReloader loads the key pair at certPath and keyPath and, when caPath is not empty, the CA bundle used
+// in place of the system roots to verify servers. It fails if the initial load fails.
+func NewClientCertReloader(certPath string, keyPath string, caPath string) (*ClientCertReloader, error) {
+	reloader := &ClientCertReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+		caPath:   caPath,
+
+		interval: DefaultCertReloadInterval,
+	}
+
+	err := reloader.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// WithReloadInterval sets how often the files are checked for changes (default [DefaultCertReloadInterval]).
+func (p *ClientCertReloader) WithReloadInterval(interval time.Duration) *ClientCertReloader {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.interval = interval
+
+	return p
+}
+
+// paths returns the files watched by the reloader.
+func (p *ClientCertReloader) paths() []string {
+	if p.caPath == "" {
+		return []string{p.certPath, p.keyPath}
+	}
+
+	return []string{p.certPath, p.keyPath, p.caPath}
+}
+
+// statFiles returns the modification times of the watched files.
+func (p *ClientCertReloader) statFiles() ([]time.Time, error) {
+	modTimes := make([]time.Time, 0, 3)
+
+	for _, path := range p.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	return modTimes, nil
+}
+
+// Reload reads the files now, replacing the served certificate and CA bundle only if all of them load.
+func (p *ClientCertReloader) Reload() error {
+	err := p.reload()
+	if err != nil {
+		httpClientTlsCertReloadFailureCounter.Inc(p.certPath)
+
+		tlog.E(context.Background()).Err(err).Msgf("thttp client certificate reload failed: %v", err)
+	}
+
+	return err
+}
+
+func (p *ClientCertReloader) reload() error {
+	modTimes, err := p.statFiles()
+	if err != nil {
+		return fmt.Errorf("thttp: client certificate reload failed: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(p.certPath, p.keyPath)
+	if err != nil {
+		return fmt.Errorf("thttp: client certificate reload failed: %w", err)
+	}
+
+	var rootCAs *x509.CertPool
+	var caExpiry time.Time
+
+	if p.caPath != "" {
+		data, err := os.ReadFile(p.caPath)
+		if err != nil {
+			return fmt.Errorf("thttp: CA bundle reload failed: %w", err)
+		}
+
+		rootCAs, err = certPoolFromPem(nil, data)
+		if err != nil {
+			return fmt.Errorf("thttp: CA bundle reload failed: %w", err)
+		}
+
+		caExpiry, _ = earliestExpiry(data)
+	}
+
+	httpClientTlsCertExpiryGauge.Set(float64(cert.Leaf.NotAfter.Unix()), p.certPath)
+
+	if caExpiry.IsZero() == false {
+		httpClientTlsCertExpiryGauge.Set(float64(caExpiry.Unix()), p.caPath)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.cert = &cert
+	p.rootCAs = rootCAs
+
+	p.modTimes = modTimes
+	p.lastCheck = time.Now()
+
+	return nil
+}
+
+// reloadIfChanged reloads the files when the interval has passed and any of them has a new modification time.
+func (p *ClientCertReloader) reloadIfChanged() {
+	p.mutex.Lock()
+
+	if time.Since(p.lastCheck) < p.interval {
+		p.mutex.Unlock()
+
+		return
+	}
+
+	p.lastCheck = time.Now()
+
+	loadedModTimes := p.modTimes
+
+	p.mutex.Unlock()
+
+	modTimes, err := p.statFiles()
+	if err == nil {
+		changed := false
+		for i := range modTimes {
+			if modTimes[i].Equal(loadedModTimes[i]) == false {
+				changed = true
+			}
+		}
+
+		if changed == false {
+			return
+		}
+	}
+
+	_ = p.Reload()
+}
+
+// Certificate returns the client certificate currently served.
+func (p *ClientCertReloader) Certificate() *tls.Certificate {
+	p.reloadIfChanged()
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.cert
+}
+
+// RootCAs returns the CA bundle currently used to verify servers, or nil when no CA path was given.
+func (p *ClientCertReloader) RootCAs() *x509.CertPool {
+	p.reloadIfChanged()
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.rootCAs
+}
+
+// GetClientCertificate implements [tls.Config.GetClientCertificate].
+func (p *ClientCertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return p.Certificate(), nil
+}
+
+// serverVerifier returns a function verifying the server chain against the current CA bundle and, when that fails
+// and rootCAs is not nil, against rootCAs.
+func (p *ClientCertReloader) serverVerifier(rootCAs *x509.CertPool) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		err := verifyServerChain(state, p.RootCAs())
+		if err != nil && rootCAs != nil && len(state.PeerCertificates) > 0 && state.ServerName != "" {
+			if verifyServerChain(state, rootCAs) == nil {
+				return nil
+			}
+		}
+
+		return err
+	}
+}
+
+// certPoolFromPem appends the certificates in data to pool, or to a new pool when pool is nil. It fails if data
+// contains no certificate.
+func certPoolFromPem(pool *x509.CertPool, data []byte) (*x509.CertPool, error) {
+	if pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if pool.AppendCertsFromPEM(data) == false {
+		return nil, errors.New("no PEM certificates found")
+	}
+
+	return pool, nil
+}
+
+// earliestExpiry returns the earliest NotAfter of the certificates in PEM data.
+func earliestExpiry(data []byte) (time.Time, error) {
+	var notAfter time.Time
+
+	for _, cert := range parsePemCertificates(data) {
+		if notAfter.IsZero() || cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+
+	if notAfter.IsZero() {
+		return notAfter, errors.New("no PEM certificates found")
+	}
+
+	return notAfter, nil
+}
+
+// parsePemCertificates returns the parseable certificates in PEM data.
+func parsePemCertificates(data []byte) []*x509.Certificate {
+	certs := make([]*x509.Certificate, 0)
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
@@ -0,0 +1,636 @@
+package thttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCa is a throwaway certificate authority issuing certificates for the TLS tests.
+type testCa struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPem []byte
+	serial  atomic.Int64
+}
+
+// newTestCa returns a self-signed CA named name.
+func newTestCa(t *testing.T, name string) *testCa {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+
+	ca := &testCa{
+		cert:    cert,
+		key:     key,
+		certPem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+	ca.serial.Store(1)
+
+	return ca
+}
+
+// pool returns a certificate pool holding only the CA.
+func (p *testCa) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(p.cert)
+
+	return pool
+}
+
+// issue returns a PEM certificate and key named commonName, valid for localhost and 127.0.0.1 as a server
+// certificate, or as a client certificate when client is set.
+func (p *testCa) issue(t *testing.T, commonName string, client bool) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(p.serial.Add(1)),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	if client == true {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, p.cert, &key.PublicKey, p.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// keyPair returns a server key pair issued by the CA.
+func (p *testCa) keyPair(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	certPem, keyPem := p.issue(t, "server", false)
+
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		t.Fatalf("X509KeyPair: %v", err)
+	}
+
+	return cert
+}
+
+// writeTestFile writes data to name in dir, with its modification time set to modTime, and returns the path.
+func writeTestFile(t *testing.T, dir string, name string, data []byte, modTime time.Time) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+
+	err := os.WriteFile(path, data, 0o600)
+	if err == nil {
+		err = os.Chtimes(path, modTime, modTime)
+	}
+
+	if err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+
+	return path
+}
+
+// newMtlsServer returns a TLS server presenting a certificate of serverCa that requires a client certificate of
+// clientCa, answers with the client certificate's common name, and closes every connection so that each request
+// performs a new handshake.
+func newMtlsServer(t *testing.T, serverCa *testCa, clientCa *testCa) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCa.keyPair(t)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCa.pool(),
+	}
+
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// localhostUrl returns the URL of server with its IP address replaced by localhost.
+func localhostUrl(server *httptest.Server) string {
+	return strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+}
+
+// getText sends a GET to url with client and returns the response body.
+func getText(client *HttpClient, url string) (string, error) {
+	resp, err := client.Get(context.Background(), url, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	_, text, err := resp.ToString()
+
+	return text, err
+}
+
+// clientCertFiles writes a client certificate of ca named commonName, and the CA bundle of bundleCa, to dir and
+// returns their paths.
+func clientCertFiles(t *testing.T, dir string, ca *testCa, commonName string, bundleCa *testCa, modTime time.Time) (string, string, string) {
+	t.Helper()
+
+	certPem, keyPem := ca.issue(t, commonName, true)
+
+	return writeTestFile(t, dir, "client.pem", certPem, modTime),
+		writeTestFile(t, dir, "client.key", keyPem, modTime),
+		writeTestFile(t, dir, "ca.pem", bundleCa.certPem, modTime)
+}
+
+func TestClientCertFiles(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	clientCa := newTestCa(t, "client ca")
+
+	server := newMtlsServer(t, serverCa, clientCa)
+
+	certPath, keyPath, caPath := clientCertFiles(t, t.TempDir(), clientCa, "client-1", serverCa, time.Now())
+
+	client := NewHttpClient().WithClientCertFiles(certPath, keyPath, caPath)
+
+	text, err := getText(client, localhostUrl(server))
+	if err != nil || text != "client-1" {
+		t.Fatalf("Get = %q, %v, want client-1", text, err)
+	}
+
+	// the CA bundle is checked against the host the client dialed
+	_, err = getText(client, server.URL)
+	if err == nil || strings.Contains(err.Error(), "requires a DNS host name") == false {
+		t.Errorf("Get by IP address = %v, want an error", err)
+	}
+
+	// a certificate without a key is a load error returned from Do
+	client = NewHttpClient().WithClientCertFiles(certPath, caPath, caPath)
+
+	_, err = getText(client, localhostUrl(server))
+	if err == nil || strings.Contains(err.Error(), "client certificate reload failed") == false {
+		t.Errorf("Get with a broken key = %v, want the load error", err)
+	}
+}
+
+func TestClientCertFilesUntrustedServer(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	clientCa := newTestCa(t, "client ca")
+	otherCa := newTestCa(t, "other ca")
+
+	server := newMtlsServer(t, serverCa, clientCa)
+
+	certPath, keyPath, caPath := clientCertFiles(t, t.TempDir(), clientCa, "client-1", otherCa, time.Now())
+
+	_, err := getText(NewHttpClient().WithClientCertFiles(certPath, keyPath, caPath), localhostUrl(server))
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	if err == nil || strings.Contains(err.Error(), unknownAuthorityErr.Error()) == false {
+		t.Errorf("Get = %v, want an unknown authority error", err)
+	}
+}
+
+func TestClientCertReloader(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	clientCa := newTestCa(t, "client ca")
+
+	server := newMtlsServer(t, serverCa, clientCa)
+
+	dir := t.TempDir()
+	loaded := time.Now().Add(-time.Minute)
+
+	certPath, keyPath, caPath := clientCertFiles(t, dir, clientCa, "client-1", serverCa, loaded)
+
+	reloader, err := NewClientCertReloader(certPath, keyPath, caPath)
+	if err != nil {
+		t.Fatalf("NewClientCertReloader: %v", err)
+	}
+
+	reloader.WithReloadInterval(0)
+
+	client := NewHttpClient().WithClientCertReloader(reloader)
+
+	text, err := getText(client, localhostUrl(server))
+	if err != nil || text != "client-1" {
+		t.Fatalf("Get = %q, %v, want client-1", text, err)
+	}
+
+	// rotated files are served on the next handshake once their modification time changes
+	clientCertFiles(t, dir, clientCa, "client-2", serverCa, loaded.Add(time.Second))
+
+	text, err = getText(client, localhostUrl(server))
+	if err != nil || text != "client-2" {
+		t.Fatalf("Get after rotation = %q, %v, want client-2", text, err)
+	}
+
+	// a broken rotation keeps the loaded certificate
+	writeTestFile(t, dir, "client.pem", []byte("not a certificate"), loaded.Add(2*time.Second))
+
+	err = reloader.Reload()
+	if err == nil {
+		t.Errorf("Reload of a broken certificate succeeded")
+	}
+
+	text, err = getText(client, localhostUrl(server))
+	if err != nil || text != "client-2" {
+		t.Errorf("Get after a failed reload = %q, %v, want client-2", text, err)
+	}
+}
+
+func TestClientCertReloaderInterval(t *testing.T) {
+	clientCa := newTestCa(t, "client ca")
+
+	dir := t.TempDir()
+	loaded := time.Now().Add(-time.Minute)
+
+	certPath, keyPath, _ := clientCertFiles(t, dir, clientCa, "client-1", clientCa, loaded)
+
+	reloader, err := NewClientCertReloader(certPath, keyPath, "")
+	if err != nil {
+		t.Fatalf("NewClientCertReloader: %v", err)
+	}
+
+	if reloader.RootCAs() != nil {
+		t.Errorf("RootCAs = non-nil, want nil without a CA path")
+	}
+
+	first := reloader.Certificate()
+
+	// changes are not picked up before the interval has passed
+	clientCertFiles(t, dir, clientCa, "client-2", clientCa, loaded.Add(time.Second))
+
+	if reloader.Certificate() != first {
+		t.Errorf("Certificate changed before the reload interval")
+	}
+
+	reloader.WithReloadInterval(0)
+
+	if reloader.Certificate() == first || reloader.Certificate().Leaf.Subject.CommonName != "client-2" {
+		t.Errorf("Certificate = %v, want the rotated certificate", reloader.Certificate().Leaf.Subject)
+	}
+
+	// a failed load is reported by the constructor
+	_, err = NewClientCertReloader(filepath.Join(dir, "missing.pem"), keyPath, "")
+	if err == nil {
+		t.Errorf("NewClientCertReloader with a missing file succeeded")
+	}
+}
+
+// newTlsServer returns a TLS server presenting a certificate of ca that answers "ok".
+func newTlsServer(t *testing.T, ca *testCa) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{ca.keyPair(t)}}
+
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRootCAs(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	otherCa := newTestCa(t, "other ca")
+
+	server := newTlsServer(t, serverCa)
+
+	caPath := writeTestFile(t, t.TempDir(), "ca.pem", serverCa.certPem, time.Now())
+
+	tests := map[string]struct {
+		option  *RootCAsOption
+		wantErr string
+	}{
+		"pem":            {option: NewRootCAsOption().WithPem(serverCa.certPem)},
+		"file":           {option: NewRootCAsOption().WithFiles(caPath).WithReplaceSystem(true)},
+		"system roots":   {wantErr: "certificate signed by unknown authority"},
+		"other ca":       {option: NewRootCAsOption().WithPem(otherCa.certPem).WithReplaceSystem(true), wantErr: "certificate signed by unknown authority"},
+		"invalid pem":    {option: NewRootCAsOption().WithPem([]byte("garbage")), wantErr: "thttp: invalid root CA PEM"},
+		"missing file":   {option: NewRootCAsOption().WithFiles(caPath + ".missing"), wantErr: "no such file"},
+		"tls config too": {option: NewRootCAsOption().WithPem(serverCa.certPem).WithReplaceSystem(true)},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewHttpClient().WithOption(OptTransTlsConfig, &tls.Config{RootCAs: otherCa.pool()}).WithRootCAs(test.option)
+			if name != "tls config too" {
+				client = NewHttpClient().WithRootCAs(test.option)
+			}
+
+			text, err := getText(client, server.URL)
+			if test.wantErr != "" {
+				if err == nil || strings.Contains(err.Error(), test.wantErr) == false {
+					t.Errorf("Get = %v, want %q", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil || text != "ok" {
+				t.Errorf("Get = %q, %v, want ok", text, err)
+			}
+		})
+	}
+}
+
+// newProfileServer returns a TLS server of ca, limited to maxVersion when it is not 0, that answers with the
+// negotiated version, cipher suite, and whether the session was resumed, and closes every connection.
+func newProfileServer(t *testing.T, ca *testCa, maxVersion uint16) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+		_, _ = fmt.Fprintf(w, "%s %s %t", tls.VersionName(r.TLS.Version), tls.CipherSuiteName(r.TLS.CipherSuite), r.TLS.DidResume)
+	}))
+
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{ca.keyPair(t)},
+		MaxVersion:   maxVersion,
+	}
+
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestTlsProfile(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+
+	tls12Server := newProfileServer(t, serverCa, tls.VersionTLS12)
+	tls13Server := newProfileServer(t, serverCa, 0)
+
+	tests := map[string]struct {
+		profile *TlsProfile
+		server  *httptest.Server
+		want    string
+		wantErr string
+	}{
+		"modern":                 {profile: NewModernTlsProfile(), server: tls13Server, want: "TLS 1.3 TLS_AES_128_GCM_SHA256"},
+		"modern against tls 1.2": {profile: NewModernTlsProfile(), server: tls12Server, wantErr: "protocol version"},
+		"intermediate":           {profile: NewIntermediateTlsProfile(), server: tls12Server, want: "TLS 1.2 TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		"max version":            {profile: NewIntermediateTlsProfile().WithMaxVersion(tls.VersionTLS12), server: tls13Server, want: "TLS 1.2 TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		"fips":                   {profile: NewFipsTlsProfile(), server: tls12Server, want: "TLS 1.2 TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		"overrides base config":  {profile: NewModernTlsProfile(), server: tls13Server, want: "TLS 1.3"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewHttpClient()
+			if name == "overrides base config" {
+				client.WithOption(OptTransTlsConfig, &tls.Config{MaxVersion: tls.VersionTLS12})
+			}
+
+			client.WithTlsProfile(test.profile).WithRootCAs(NewRootCAsOption().WithPem(serverCa.certPem))
+
+			text, err := getText(client, test.server.URL)
+			if test.wantErr != "" {
+				if err == nil || strings.Contains(err.Error(), test.wantErr) == false {
+					t.Errorf("Get = %v, want %q", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil || strings.HasPrefix(text, test.want) == false {
+				t.Errorf("Get = %q, %v, want %q", text, err, test.want)
+			}
+		})
+	}
+
+	if NewFipsTlsProfile().Name() != "fips" {
+		t.Errorf("Name = %q, want fips", NewFipsTlsProfile().Name())
+	}
+}
+
+func TestTlsProfileSessionResumption(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	server := newProfileServer(t, serverCa, 0)
+
+	tests := map[string]struct {
+		profile    *TlsProfile
+		wantResume bool
+	}{
+		"cached":    {profile: NewModernTlsProfile(), wantResume: true},
+		"no cache":  {profile: NewModernTlsProfile().WithSessionCacheSize(0)},
+		"rebuilt":   {profile: NewIntermediateTlsProfile(), wantResume: true},
+		"tls 1.2":   {profile: NewIntermediateTlsProfile().WithMaxVersion(tls.VersionTLS12), wantResume: true},
+		"no option": {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewHttpClient().WithRootCAs(NewRootCAsOption().WithPem(serverCa.certPem))
+			if test.profile != nil {
+				client.WithTlsProfile(test.profile)
+			}
+
+			_, err := getText(client, server.URL)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+
+			// the session cache survives rebuilding the configuration for another TLS option
+			if name == "rebuilt" {
+				client.WithUnsafeTls(false)
+			}
+
+			text, err := getText(client, server.URL)
+			if err != nil || strings.HasSuffix(text, fmt.Sprint(test.wantResume)) == false {
+				t.Errorf("second Get = %q, %v, want resumed %t", text, err, test.wantResume)
+			}
+		})
+	}
+}
+
+// keyLogBuffer is a key log writer safe for use by concurrent handshakes.
+type keyLogBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (p *keyLogBuffer) Write(data []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.buf.Write(data)
+}
+
+func (p *keyLogBuffer) String() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.buf.String()
+}
+
+func TestKeyLogWriter(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	server := newProfileServer(t, serverCa, 0)
+
+	keyLog := &keyLogBuffer{}
+
+	client := NewHttpClient().WithKeyLogWriter(keyLog).WithRootCAs(NewRootCAsOption().WithPem(serverCa.certPem))
+
+	_, err := getText(client, server.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if strings.Contains(keyLog.String(), "CLIENT_TRAFFIC_SECRET_0 ") == false {
+		t.Errorf("key log = %q, want TLS 1.3 secrets", keyLog.String())
+	}
+
+	written := keyLog.String()
+
+	client.WithKeyLogWriter(nil)
+
+	_, err = getText(client, server.URL)
+	if err != nil || keyLog.String() != written {
+		t.Errorf("Get after disabling = %v, key log grew = %t", err, keyLog.String() != written)
+	}
+
+	_, err = getText(NewHttpClient().WithOption(OptTransKeyLogWriter, "stdout"), server.URL)
+	if err == nil || strings.Contains(err.Error(), "thttp: invalid OptTransKeyLogWriter value") == false {
+		t.Errorf("OptTransKeyLogWriter with a string = %v, want an error", err)
+	}
+}
+
+func TestKeyLogFile(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	server := newProfileServer(t, serverCa, tls.VersionTLS12)
+
+	path := filepath.Join(t.TempDir(), "keys.log")
+	t.Setenv("SSLKEYLOGFILE", path)
+
+	client := NewHttpClient().WithKeyLogFile("").WithRootCAs(NewRootCAsOption().WithPem(serverCa.certPem))
+
+	_, err := getText(client, server.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	client.WithKeyLogWriter(nil)
+
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.HasPrefix(data, []byte("CLIENT_RANDOM ")) == false {
+		t.Errorf("key log file = %q, %v, want TLS 1.2 secrets", data, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key log file mode = %v, %v, want 0600", info.Mode(), err)
+	}
+
+	t.Setenv("SSLKEYLOGFILE", "")
+
+	_, err = getText(NewHttpClient().WithKeyLogFile(""), server.URL)
+	if err == nil || strings.Contains(err.Error(), "SSLKEYLOGFILE is not set") == false {
+		t.Errorf("Get without a key log path = %v, want the open error", err)
+	}
+}
+
+func TestClientCertFilesMergesRootCAs(t *testing.T) {
+	serverCa := newTestCa(t, "server ca")
+	clientCa := newTestCa(t, "client ca")
+	otherCa := newTestCa(t, "other ca")
+
+	server := newMtlsServer(t, serverCa, clientCa)
+
+	certPath, keyPath, caPath := clientCertFiles(t, t.TempDir(), clientCa, "client-1", otherCa, time.Now())
+
+	tests := map[string]*HttpClient{
+		"root CAs": NewHttpClient().
+			WithRootCAs(NewRootCAsOption().WithPem(serverCa.certPem).WithReplaceSystem(true)).
+			WithClientCertFiles(certPath, keyPath, caPath),
+		"tls config": NewHttpClient().
+			WithOption(OptTransTlsConfig, &tls.Config{RootCAs: serverCa.pool()}).
+			WithClientCertFiles(certPath, keyPath, caPath),
+	}
+
+	for name, client := range tests {
+		t.Run(name, func(t *testing.T) {
+			text, err := getText(client, localhostUrl(server))
+			if err != nil || text != "client-1" {
+				t.Errorf("Get = %q, %v, want the server verified by the root CAs", text, err)
+			}
+
+			_, err = getText(client, server.URL)
+			if err == nil {
+				t.Errorf("Get by IP address succeeded, want an error")
+			}
+		})
+	}
+
+	// neither the bundle nor the root CAs trust the server
+	client := NewHttpClient().
+		WithRootCAs(NewRootCAsOption().WithPem(otherCa.certPem).WithReplaceSystem(true)).
+		WithClientCertFiles(certPath, keyPath, caPath)
+
+	_, err := getText(client, localhostUrl(server))
+	if err == nil {
+		t.Errorf("Get with untrusted roots succeeded, want an error")
+	}
+}
@@ -16,3 +16,26 @@ var (
 		},
 	)
 )
+
+// httpClientTlsCertReloadFailureCounter counts failed client certificate reloads by certificate path.
+var (
+	httpClientTlsCertReloadFailureCounter, _ = tmetric.NewCounterVec(
+		"http_client_tls_cert_reload_failures",
+		"number of failed client certificate or CA bundle reloads",
+		[]string{
+			"http_client_cert_path",
+		},
+	)
+)
+
+// httpClientTlsCertExpiryGauge exports the NotAfter time (Unix seconds) of loaded certificates by path; for a CA
+// bundle it is the earliest expiry in the bundle.
+var (
+	httpClientTlsCertExpiryGauge, _ = tmetric.NewGaugeVec(
+		"http_client_tls_cert_expiry_timestamp",
+		"expiry time in unix seconds of the loaded client certificate or earliest-expiring CA certificate",
+		[]string{
+			"http_client_cert_path",
+		},
+	)
+)